@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"gopark/config"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// newConfigCmd builds the config command group. Configuration is loaded and
+// validated before these commands run, so invalid files exit early
+func newConfigCmd(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the effective configuration",
+	}
+
+	var format string
+	show := &cobra.Command{
+		Use:   "show",
+		Short: "Print the merged configuration with the source of each value",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return a.loader.Show(cmd.OutOrStdout(), a.cfg, format)
+		},
+	}
+	show.Flags().StringVar(&format, "format", config.FormatYAML, "Output format: yaml or json")
+	show.RegisterFlagCompletionFunc("format", completeFormats(config.FormatYAML, config.FormatJSON))
+
+	validate := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the configuration without starting the server",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fmt.Fprintf(cmd.OutOrStdout(), "configuration is valid (%s)\n", strings.Join(a.loader.Files(), ", "))
+			return nil
+		},
+	}
+
+	cmd.AddCommand(show, validate)
+	return cmd
+}
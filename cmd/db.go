@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"gopark/internal/backup"  // Import database backup package
+	"gopark/internal/db"      // Import database package
+	"gopark/internal/replica" // Import WAL replication package
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// newDBCmd builds the db maintenance command group
+func newDBCmd(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "db",
+		Short: "Database maintenance",
+	}
+
+	backupCmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Take a hot backup; safe to run while the server is up",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbConn, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			defer dbConn.Close()
+
+			created, err := backup.NewManager(dbConn, a.cfg.Backup, a.log).Create(context.Background())
+			if err != nil {
+				return fmt.Errorf("backup failed: %w", err)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), created.Path)
+			return nil
+		},
+	}
+
+	var to string
+	restoreCmd := &cobra.Command{
+		Use:   "restore [backup-file]",
+		Short: "Replace the database with a backup, or with the replica state at a point in time; stop the server first",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 && to != "" {
+				return fmt.Errorf("--to only applies to replica restores")
+			}
+			return runRestore(a, args, to)
+		},
+	}
+	restoreCmd.Flags().StringVar(&to, "to", "", "Restore the replica as of this RFC 3339 timestamp (default: latest)")
+
+	vacuumCmd := &cobra.Command{
+		Use:   "vacuum",
+		Short: "Rebuild the database file and refresh planner statistics",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbConn, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			defer dbConn.Close()
+
+			ctx := context.Background()
+			if err := dbConn.Vacuum(ctx); err != nil {
+				return err
+			}
+			return dbConn.Analyze(ctx)
+		},
+	}
+
+	checkCmd := &cobra.Command{
+		Use:   "check",
+		Short: "Verify database integrity and that its schema matches this build",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			path := a.cfg.Database.Path
+			if err := db.CheckIntegrity(ctx, path); err != nil {
+				return err
+			}
+			if err := backup.CheckSchemaVersion(ctx, path, migrationsDir); err != nil {
+				return err
+			}
+
+			applied, err := db.AppliedMigrations(ctx, path)
+			if err != nil {
+				return err
+			}
+			available, err := db.AvailableMigrations(migrationsDir)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s: integrity ok, %d of %d migrations applied\n", path, len(applied), len(available))
+			return nil
+		},
+	}
+
+	cmd.AddCommand(backupCmd, restoreCmd, vacuumCmd, checkCmd)
+	return cmd
+}
+
+// runRestore replaces the configured database with a backup file, or with the
+// replica state at target (latest when empty) when no file is given
+func runRestore(a *app, args []string, to string) error {
+	cfg, log := a.cfg, a.log
+	ctx := context.Background()
+	if len(args) == 1 {
+		return backup.Restore(ctx, args[0], cfg.Database.Path, migrationsDir, log)
+	}
+
+	var target time.Time
+	if to != "" {
+		var err error
+		if target, err = time.Parse(time.RFC3339, to); err != nil {
+			return fmt.Errorf("invalid --to timestamp: %w", err)
+		}
+	}
+
+	storage, err := replica.NewLocalStorage(cfg.Replication.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open replica storage: %w", err)
+	}
+
+	// Rebuild next to the database, then swap it in with the backup restore checks
+	staged := cfg.Database.Path + ".replica"
+	os.Remove(staged)
+	defer os.Remove(staged)
+	if err := replica.Restore(ctx, storage, target, staged, log); err != nil {
+		return fmt.Errorf("replica restore failed: %w", err)
+	}
+	return backup.Restore(ctx, staged, cfg.Database.Path, migrationsDir, log)
+}
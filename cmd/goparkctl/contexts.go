@@ -0,0 +1,255 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// configEnvVar overrides the location of the contexts file
+const configEnvVar = "GOPARKCTL_CONFIG"
+
+// Context names a GoPark server and the credentials used to reach it
+type Context struct {
+	Name     string `yaml:"name" json:"name"`
+	Server   string `yaml:"server" json:"server"`
+	Token    string `yaml:"token,omitempty" json:"-"`
+	Username string `yaml:"username,omitempty" json:"username,omitempty"`
+	Password string `yaml:"password,omitempty" json:"-"`
+}
+
+// contextListing is a context as shown by "context list", describing its
+// credentials without revealing them
+type contextListing struct {
+	Name    string `yaml:"name" json:"name"`
+	Server  string `yaml:"server" json:"server"`
+	Auth    string `yaml:"auth" json:"auth"`
+	Current bool   `yaml:"current" json:"current"`
+}
+
+// listContext describes ctx for "context list"
+func listContext(ctx Context, current bool) contextListing {
+	auth := "none"
+	switch {
+	case ctx.Token != "":
+		auth = "token"
+	case ctx.Username != "":
+		auth = "basic (" + ctx.Username + ")"
+	}
+	return contextListing{Name: ctx.Name, Server: ctx.Server, Auth: auth, Current: current}
+}
+
+// ctlConfig is the contents of the contexts file
+type ctlConfig struct {
+	CurrentContext string    `yaml:"current_context"`
+	Contexts       []Context `yaml:"contexts"`
+}
+
+// configPath returns the contexts file location
+func configPath() (string, error) {
+	if path := os.Getenv(configEnvVar); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "goparkctl", "config.yaml"), nil
+}
+
+// loadConfig reads the contexts file; a missing file is an empty config
+func loadConfig(path string) (*ctlConfig, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &ctlConfig{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cfg ctlConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid contexts file %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// save writes the contexts file readable only by its owner, since it holds credentials
+func (c *ctlConfig) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// find returns the context called name
+func (c *ctlConfig) find(name string) (*Context, bool) {
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == name {
+			return &c.Contexts[i], true
+		}
+	}
+	return nil, false
+}
+
+// set adds ctx or replaces the context with the same name
+func (c *ctlConfig) set(ctx Context) {
+	if existing, ok := c.find(ctx.Name); ok {
+		*existing = ctx
+		return
+	}
+	c.Contexts = append(c.Contexts, ctx)
+	sort.Slice(c.Contexts, func(i, j int) bool { return c.Contexts[i].Name < c.Contexts[j].Name })
+}
+
+// remove deletes the context called name
+func (c *ctlConfig) remove(name string) bool {
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == name {
+			c.Contexts = append(c.Contexts[:i], c.Contexts[i+1:]...)
+			if c.CurrentContext == name {
+				c.CurrentContext = ""
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// newContextCmd builds the context command group
+func newContextCmd(g *globals) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "context",
+		Aliases: []string{"ctx"},
+		Short:   "Manage server contexts",
+	}
+
+	var ctx Context
+	var use bool
+	set := &cobra.Command{
+		Use:   "set <name>",
+		Short: "Add or update a context",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if ctx.Server == "" {
+				return errors.New("--server is required")
+			}
+			ctx.Name = args[0]
+			return g.updateConfig(func(cfg *ctlConfig) error {
+				cfg.set(ctx)
+				if use || cfg.CurrentContext == "" {
+					cfg.CurrentContext = ctx.Name
+				}
+				return nil
+			})
+		},
+	}
+	set.Flags().StringVar(&ctx.Server, "server", "", "Server base URL, e.g. https://gopark.example.com")
+	set.Flags().StringVar(&ctx.Token, "token", "", "Bearer token")
+	set.Flags().StringVar(&ctx.Username, "username", "", "Basic auth user name")
+	set.Flags().StringVar(&ctx.Password, "password", "", "Basic auth password")
+	set.Flags().BoolVar(&use, "use", false, "Make this the current context")
+
+	useCmd := &cobra.Command{
+		Use:               "use <name>",
+		Short:             "Switch the current context",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: g.completeContexts,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return g.updateConfig(func(cfg *ctlConfig) error {
+				if _, ok := cfg.find(args[0]); !ok {
+					return fmt.Errorf("context %q not found", args[0])
+				}
+				cfg.CurrentContext = args[0]
+				return nil
+			})
+		},
+	}
+
+	del := &cobra.Command{
+		Use:               "delete <name>",
+		Short:             "Delete a context",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: g.completeContexts,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return g.updateConfig(func(cfg *ctlConfig) error {
+				if !cfg.remove(args[0]) {
+					return fmt.Errorf("context %q not found", args[0])
+				}
+				return nil
+			})
+		},
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List contexts",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := configPath()
+			if err != nil {
+				return err
+			}
+			cfg, err := loadConfig(path)
+			if err != nil {
+				return err
+			}
+			contexts := make([]contextListing, 0, len(cfg.Contexts))
+			for _, ctx := range cfg.Contexts {
+				contexts = append(contexts, listContext(ctx, ctx.Name == cfg.CurrentContext))
+			}
+			return printContexts(cmd.OutOrStdout(), g.output, contexts)
+		},
+	}
+
+	cmd.AddCommand(set, useCmd, del, list)
+	return cmd
+}
+
+// updateConfig loads the contexts file, applies fn and saves it
+func (g *globals) updateConfig(fn func(cfg *ctlConfig) error) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(cfg); err != nil {
+		return err
+	}
+	return cfg.save(path)
+}
+
+// completeContexts offers the context names from the contexts file
+func (g *globals) completeContexts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	path, err := configPath()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	names := make([]string, 0, len(cfg.Contexts))
+	for _, ctx := range cfg.Contexts {
+		names = append(names, ctx.Name)
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
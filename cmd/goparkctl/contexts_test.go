@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// execute runs goparkctl with args and returns its standard output
+func execute(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	root := newRootCmd()
+	root.SetOut(&out)
+	root.SetArgs(args)
+	err := root.Execute()
+	return out.String(), err
+}
+
+// TestContexts covers storing contexts and using them to reach a server
+func TestContexts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv(configEnvVar, path)
+	t.Setenv("GOPARKCTL_CONTEXT", "")
+
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		assert.Equal(t, "/api/v1/users", r.URL.Path)
+		json.NewEncoder(w).Encode(map[string]any{"id": 1, "name": "Ada", "mail": "ada@example.com"})
+	}))
+	defer srv.Close()
+
+	// Test case 1: No context configured
+	_, err := execute(t, "users", "get", "1")
+	assert.ErrorContains(t, err, "no server configured")
+
+	// Test case 2: Set a context, which becomes current with --use
+	_, err = execute(t, "context", "set", "local", "--server", srv.URL, "--token", "s3cret", "--use")
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Test case 3: The current context supplies the server and token
+	out, err := execute(t, "users", "get", "1", "-o", "json")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer s3cret", auth)
+	assert.JSONEq(t, `{"id":1,"name":"Ada","mail":"ada@example.com"}`, out)
+
+	// Test case 4: Listing contexts honours the output format and never
+	// prints credentials
+	out, err = execute(t, "context", "list", "-o", "json")
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"name":"local","server":"`+srv.URL+`","auth":"token","current":true}]`, out)
+	out, err = execute(t, "context", "list", "-o", "yaml")
+	require.NoError(t, err)
+	var listed []map[string]any
+	require.NoError(t, yaml.Unmarshal([]byte(out), &listed))
+	assert.Equal(t, []map[string]any{{"name": "local", "server": srv.URL, "auth": "token", "current": true}}, listed)
+	out, err = execute(t, "context", "list")
+	require.NoError(t, err)
+	assert.Regexp(t, `\*\s+local\s+`+regexp.QuoteMeta(srv.URL)+`\s+token`, out)
+	assert.NotContains(t, out, "s3cret")
+
+	// Test case 5: An unknown context is an error
+	_, err = execute(t, "users", "get", "1", "--context", "missing")
+	assert.ErrorContains(t, err, `context "missing" not found`)
+
+	// Test case 6: Deleting the current context clears it
+	_, err = execute(t, "context", "delete", "local")
+	require.NoError(t, err)
+	cfg, err := loadConfig(path)
+	require.NoError(t, err)
+	assert.Empty(t, cfg.CurrentContext)
+	assert.Empty(t, cfg.Contexts)
+}
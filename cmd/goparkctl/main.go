@@ -0,0 +1,100 @@
+// Command goparkctl manages users on a remote GoPark instance over its REST API
+package main
+
+import (
+	"errors"
+	"fmt"
+	"gopark/pkg/client"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// globals holds the flags shared by every command
+type globals struct {
+	context string
+	server  string
+	token   string
+	output  string
+	timeout time.Duration
+}
+
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// newRootCmd builds the goparkctl command tree
+func newRootCmd() *cobra.Command {
+	g := &globals{}
+	root := &cobra.Command{
+		Use:           "goparkctl",
+		Short:         "Manage a remote GoPark instance",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	flags := root.PersistentFlags()
+	flags.StringVar(&g.context, "context", os.Getenv("GOPARKCTL_CONTEXT"), "Context to use (default: the current context)")
+	flags.StringVar(&g.server, "server", "", "Server base URL, overriding the context")
+	flags.StringVar(&g.token, "token", "", "Bearer token, overriding the context")
+	flags.StringVarP(&g.output, "output", "o", formatTable, "Output format: table, json or yaml")
+	flags.DurationVar(&g.timeout, "timeout", 30*time.Second, "Per-request timeout")
+	root.RegisterFlagCompletionFunc("context", g.completeContexts)
+	root.RegisterFlagCompletionFunc("output", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return []string{formatTable, formatJSON, formatYAML}, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	root.AddCommand(newContextCmd(g), newUsersCmd(g))
+	return root
+}
+
+// client builds an API client from the selected context and overriding flags
+func (g *globals) client() (*client.Client, error) {
+	var ctx Context
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	name := g.context
+	if name == "" {
+		name = cfg.CurrentContext
+	}
+	if name != "" {
+		found, ok := cfg.find(name)
+		if !ok {
+			return nil, fmt.Errorf("context %q not found in %s", name, path)
+		}
+		ctx = *found
+	}
+
+	if g.server != "" {
+		ctx.Server = g.server
+	}
+	if g.token != "" {
+		ctx.Token, ctx.Username, ctx.Password = g.token, "", ""
+	}
+	if ctx.Server == "" {
+		return nil, errors.New("no server configured: run `goparkctl context set` or pass --server")
+	}
+
+	opts := []client.Option{
+		client.WithHTTPClient(&http.Client{Timeout: g.timeout}),
+		client.WithUserAgent("goparkctl"),
+	}
+	switch {
+	case ctx.Token != "":
+		opts = append(opts, client.WithToken(ctx.Token))
+	case ctx.Username != "":
+		opts = append(opts, client.WithBasicAuth(ctx.Username, ctx.Password))
+	}
+	return client.New(ctx.Server, opts...)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gopark/pkg/client"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+// clearScreen moves the cursor home and clears a terminal
+const clearScreen = "\033[H\033[2J"
+
+// encode writes v as an indented JSON or a YAML document
+func encode(w io.Writer, format string, v any) error {
+	if format == formatYAML {
+		return yaml.NewEncoder(w).Encode(v)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printUsers writes a single user or a list of users in format
+func printUsers(w io.Writer, format string, v any) error {
+	switch format {
+	case formatJSON, formatYAML:
+		return encode(w, format, v)
+	case formatTable:
+		var users []client.User
+		switch v := v.(type) {
+		case *client.User:
+			users = []client.User{*v}
+		case []client.User:
+			users = v
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tMAIL")
+		for _, user := range users {
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", user.ID, user.Name, user.Mail)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q (expected table, json or yaml)", format)
+	}
+}
+
+// printContexts writes the listed contexts in format
+func printContexts(w io.Writer, format string, contexts []contextListing) error {
+	switch format {
+	case formatJSON, formatYAML:
+		return encode(w, format, contexts)
+	case formatTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "CURRENT\tNAME\tSERVER\tAUTH")
+		for _, ctx := range contexts {
+			current := ""
+			if ctx.Current {
+				current = "*"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", current, ctx.Name, ctx.Server, ctx.Auth)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q (expected table, json or yaml)", format)
+	}
+}
+
+// watch polls fetch every interval and prints the result whenever it changes,
+// until interrupted. Tables redraw the terminal; JSON and YAML print a new
+// document per change so the stream can be piped
+func watch(w io.Writer, format string, interval time.Duration, fetch func(ctx context.Context) (any, error)) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last []byte
+	printed := false
+	for {
+		v, err := fetch(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		var buf bytes.Buffer
+		if err != nil {
+			fmt.Fprintf(&buf, "Error: %v\n", err) // Keep watching through transient failures
+		} else if err := printUsers(&buf, format, v); err != nil {
+			return err
+		}
+
+		if !printed || !bytes.Equal(buf.Bytes(), last) {
+			switch {
+			case format == formatTable && isTerminal(w):
+				fmt.Fprintf(w, "%sEvery %s: %s\n\n", clearScreen, interval, time.Now().Format(time.TimeOnly))
+			case format == formatYAML && printed:
+				fmt.Fprintln(w, "---")
+			}
+			w.Write(buf.Bytes())
+			last, printed = buf.Bytes(), true
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// isTerminal reports whether w is a character device such as a terminal
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"gopark/pkg/client"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// newUsersCmd builds the users command group
+func newUsersCmd(g *globals) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "users",
+		Aliases: []string{"user"},
+		Short:   "Manage users",
+	}
+
+	get := &cobra.Command{
+		Use:               "get <id>",
+		Short:             "Show a user",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: g.completeUserIDs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseID(args[0])
+			if err != nil {
+				return err
+			}
+			c, err := g.client()
+			if err != nil {
+				return err
+			}
+			user, err := c.Users().Get(cmd.Context(), id)
+			if err != nil {
+				return err
+			}
+			return printUsers(cmd.OutOrStdout(), g.output, user)
+		},
+	}
+
+	var opts client.ListOptions
+	var watchList bool
+	var interval time.Duration
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List users",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := g.client()
+			if err != nil {
+				return err
+			}
+			fetch := func(ctx context.Context) (any, error) { return c.Users().List(ctx, opts) }
+			return g.run(cmd, watchList, interval, fetch)
+		},
+	}
+	list.Flags().IntVar(&opts.Limit, "limit", 10, "Page size (at most 100)")
+	list.Flags().IntVar(&opts.Offset, "offset", 0, "Number of users to skip")
+	addWatchFlags(list, &watchList, &interval)
+
+	var watchSearch bool
+	search := &cobra.Command{
+		Use:   "search <name>",
+		Short: "Search users by name",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := g.client()
+			if err != nil {
+				return err
+			}
+			fetch := func(ctx context.Context) (any, error) { return c.Users().Search(ctx, args[0]) }
+			return g.run(cmd, watchSearch, interval, fetch)
+		},
+	}
+	addWatchFlags(search, &watchSearch, &interval)
+
+	var input client.UserInput
+	create := &cobra.Command{
+		Use:   "create",
+		Short: "Create a user",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := g.client()
+			if err != nil {
+				return err
+			}
+			user, err := c.Users().Create(cmd.Context(), input)
+			if err != nil {
+				return err
+			}
+			return printUsers(cmd.OutOrStdout(), g.output, user)
+		},
+	}
+	addUserInputFlags(create, &input)
+
+	update := &cobra.Command{
+		Use:               "update <id>",
+		Short:             "Replace a user's name and mail",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: g.completeUserIDs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseID(args[0])
+			if err != nil {
+				return err
+			}
+			c, err := g.client()
+			if err != nil {
+				return err
+			}
+			user, err := c.Users().Update(cmd.Context(), id, input)
+			if err != nil {
+				return err
+			}
+			return printUsers(cmd.OutOrStdout(), g.output, user)
+		},
+	}
+	addUserInputFlags(update, &input)
+
+	del := &cobra.Command{
+		Use:               "delete <id>",
+		Short:             "Delete a user",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: g.completeUserIDs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseID(args[0])
+			if err != nil {
+				return err
+			}
+			c, err := g.client()
+			if err != nil {
+				return err
+			}
+			if err := c.Users().Delete(cmd.Context(), id); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "User %d deleted\n", id)
+			return nil
+		},
+	}
+
+	cmd.AddCommand(get, list, search, create, update, del)
+	return cmd
+}
+
+// run prints the result of fetch once, or keeps printing changes with --watch
+func (g *globals) run(cmd *cobra.Command, watching bool, interval time.Duration, fetch func(ctx context.Context) (any, error)) error {
+	if watching {
+		return watch(cmd.OutOrStdout(), g.output, interval, fetch)
+	}
+	v, err := fetch(cmd.Context())
+	if err != nil {
+		return err
+	}
+	return printUsers(cmd.OutOrStdout(), g.output, v)
+}
+
+// addWatchFlags registers --watch and --interval on a list command
+func addWatchFlags(cmd *cobra.Command, watching *bool, interval *time.Duration) {
+	cmd.Flags().BoolVarP(watching, "watch", "w", false, "Keep polling and print the result whenever it changes")
+	cmd.Flags().DurationVar(interval, "interval", 2*time.Second, "Polling interval for --watch")
+}
+
+// addUserInputFlags registers the writable user fields
+func addUserInputFlags(cmd *cobra.Command, input *client.UserInput) {
+	cmd.Flags().StringVar(&input.Name, "name", "", "User name")
+	cmd.Flags().StringVar(&input.Mail, "mail", "", "User email address")
+	cmd.MarkFlagRequired("name")
+	cmd.MarkFlagRequired("mail")
+}
+
+// parseID parses a positive user ID argument
+func parseID(arg string) (uint, error) {
+	id, err := strconv.ParseUint(arg, 10, 32)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid user ID %q", arg)
+	}
+	return uint(id), nil
+}
+
+// completeUserIDs offers the IDs of the first page of users, described by name
+func (g *globals) completeUserIDs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	c, err := g.client()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	users, err := c.Users().List(cmd.Context(), client.ListOptions{Limit: 100})
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	ids := make([]string, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, fmt.Sprintf("%d\t%s", user.ID, user.Name))
+	}
+	return ids, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"gopark/internal/db"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// newMigrateCmd builds the migrate command group
+func newMigrateCmd(a *app) *cobra.Command {
+	var dir string
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage database schema migrations",
+	}
+	cmd.PersistentFlags().StringVar(&dir, "dir", migrationsDir, "Migrations directory")
+
+	// withManager opens the database and runs fn with a migration manager
+	withManager := func(fn func(ctx context.Context, m *db.MigrationManager) error) error {
+		dbConn, err := a.openDB()
+		if err != nil {
+			return err
+		}
+		defer dbConn.Close()
+		return fn(context.Background(), db.NewMigrationManager(dbConn, a.log))
+	}
+
+	up := &cobra.Command{
+		Use:   "up",
+		Short: "Apply all pending migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withManager(func(ctx context.Context, m *db.MigrationManager) error {
+				return m.RunMigrations(ctx, dir)
+			})
+		},
+	}
+
+	var steps int
+	down := &cobra.Command{
+		Use:   "down",
+		Short: "Revert the most recently applied migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withManager(func(ctx context.Context, m *db.MigrationManager) error {
+				reverted, err := m.Rollback(ctx, dir, steps)
+				for _, version := range reverted {
+					fmt.Fprintln(cmd.OutOrStdout(), version)
+				}
+				return err
+			})
+		},
+	}
+	down.Flags().IntVar(&steps, "steps", 1, "Number of migrations to revert")
+
+	status := &cobra.Command{
+		Use:   "status",
+		Short: "List applied and pending migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withManager(func(ctx context.Context, m *db.MigrationManager) error {
+				statuses, err := m.Status(ctx, dir)
+				if err != nil {
+					return err
+				}
+				w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "VERSION\tSTATUS\tAPPLIED AT\tREVERSIBLE")
+				for _, s := range statuses {
+					state, appliedAt := "pending", "-"
+					if s.Applied {
+						state, appliedAt = "applied", s.AppliedAt.Format(time.RFC3339)
+					}
+					if s.Missing {
+						state = "applied (missing file)"
+					}
+					fmt.Fprintf(w, "%s\t%s\t%s\t%t\n", s.Version, state, appliedAt, s.Reversible)
+				}
+				return w.Flush()
+			})
+		},
+	}
+
+	create := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create empty up and down migration files",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			upPath, downPath, err := db.CreateMigration(dir, args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), upPath)
+			fmt.Fprintln(cmd.OutOrStdout(), downPath)
+			return nil
+		},
+	}
+
+	cmd.AddCommand(up, down, status, create)
+	return cmd
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"gopark/config"
+	"gopark/internal/db"
+	"gopark/internal/logging"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// app carries the state shared by every command
+type app struct {
+	log    *logrus.Logger
+	loader *config.Loader
+	cfg    config.Config
+}
+
+// newRootCmd builds the gopark command tree; running it without a command serves
+func newRootCmd(a *app) *cobra.Command {
+	root := &cobra.Command{
+		Use:           "gopark",
+		Short:         "GoPark user management service",
+		SilenceUsage:  true,
+		SilenceErrors: true, // main reports errors through the logger
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return a.setup(cmd)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runServe(a, true)
+		},
+	}
+	config.RegisterFlags(root.PersistentFlags())
+	a.loader = config.NewLoader(config.WithSearchPaths("config"), config.WithFlags(root.PersistentFlags())) // Load from ./config directory
+
+	root.AddCommand(
+		newServeCmd(a),
+		newMigrateCmd(a),
+		newUserCmd(a),
+		newDBCmd(a),
+		newConfigCmd(a),
+		newSecretsCmd(a),
+	)
+	return root
+}
+
+// setup loads configuration and configures logging before any command runs
+func (a *app) setup(cmd *cobra.Command) error {
+	// Shell completion must work without a valid configuration
+	if cmd.Name() == "completion" || (cmd.HasParent() && cmd.Parent().Name() == "completion") ||
+		cmd.Name() == cobra.ShellCompRequestCmd || cmd.Name() == cobra.ShellCompNoDescRequestCmd {
+		return nil
+	}
+
+	// Only the server logs to stdout; other commands keep it for their output
+	if cmd.HasParent() && cmd.Name() != "serve" {
+		a.log.SetOutput(os.Stderr)
+	}
+
+	cfg, err := a.loader.Load()
+	if err != nil {
+		return err
+	}
+	a.cfg = cfg
+
+	// Configure logging based on debug flag
+	setLogLevel(a.log, cfg.Debug)
+	logging.Configure(a.log, cfg.Log)
+	if cfg.Debug {
+		gin.SetMode(gin.DebugMode)
+		a.log.Info("Debug mode enabled")
+	} else {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	a.log.Infof("Configuration loaded: AppName=%s, Port=%d", cfg.AppName, cfg.Port)
+	return nil
+}
+
+// openDB connects to the configured database
+func (a *app) openDB() (*db.DB, error) {
+	dbConn, err := db.NewDB(a.cfg, a.log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database connection: %w", err)
+	}
+	return dbConn, nil
+}
+
+// setLogLevel switches between debug and info logging
+func setLogLevel(log *logrus.Logger, debug bool) {
+	if debug {
+		log.SetLevel(logrus.DebugLevel)
+	} else {
+		log.SetLevel(logrus.InfoLevel)
+	}
+}
+
+// completeFormats offers the output formats for --format style flags
+func completeFormats(formats ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return formats, cobra.ShellCompDirectiveNoFileComp
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopark/config"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// newSecretsCmd builds the secrets command group
+func newSecretsCmd(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "Manage the encrypted secrets file",
+	}
+
+	seal := &cobra.Command{
+		Use:   "seal <secrets.json>",
+		Short: "Seal a JSON object of plaintext secrets into secrets.file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := a.cfg.Secrets
+			if cfg.File == "" {
+				return errors.New("secrets.file is not configured")
+			}
+
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to read secrets: %w", err)
+			}
+			var secrets map[string]string
+			if err := json.Unmarshal(data, &secrets); err != nil {
+				return fmt.Errorf("secrets must be a JSON object of strings: %w", err)
+			}
+
+			key, err := config.LoadSecretKey(cfg)
+			if err != nil {
+				return fmt.Errorf("failed to load secrets key: %w", err)
+			}
+			sealed, err := config.SealSecrets(secrets, key)
+			if err != nil {
+				return fmt.Errorf("failed to seal secrets: %w", err)
+			}
+			if err := os.WriteFile(cfg.File, sealed, 0600); err != nil {
+				return fmt.Errorf("failed to write secrets file: %w", err)
+			}
+			a.log.Infof("Sealed %d secrets into %s", len(secrets), cfg.File)
+			return nil
+		},
+	}
+
+	cmd.AddCommand(seal)
+	return cmd
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"gopark"
+	"gopark/config"
+	"gopark/internal/logging"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// newServeCmd builds the serve command
+func newServeCmd(a *app) *cobra.Command {
+	var noMigrate bool
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run the HTTP server and background workers",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runServe(a, !noMigrate)
+		},
+	}
+	cmd.Flags().BoolVar(&noMigrate, "no-migrate", false, "Do not apply pending migrations on startup")
+	return cmd
+}
+
+// runServe runs migrations and starts the HTTP server with its background workers
+func runServe(a *app, migrate bool) error {
+	cfg, log := a.cfg, a.log
+
+	migrations := gopark.WithMigrations(migrationsDir)
+	if !migrate {
+		migrations = gopark.WithMigrationCheck(migrationsDir)
+	}
+	application, err := gopark.New(
+		gopark.WithConfig(cfg),
+		gopark.WithLogger(log),
+		migrations,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Apply safe configuration changes on file edits and SIGHUP
+	reloader := config.NewReloader(a.loader, cfg, log)
+	reloader.Subscribe(func(old, new config.Config) {
+		setLogLevel(log, new.Debug) // Gin's mode is process-global and stays as started
+		logging.Configure(log, new.Log)
+		application.SetConfig(new)
+	})
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	if err := reloader.Watch(watchCtx); err != nil {
+		application.Stop(context.Background())
+		return fmt.Errorf("failed to watch configuration: %w", err)
+	}
+
+	if err := application.Start(context.Background()); err != nil {
+		return err
+	}
+
+	// Serve until SIGINT or SIGTERM, or until SIGUSR2 has handed the
+	// listeners to a new process, then drain for up to shutdown.drain_timeout
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR2)
+	defer signal.Stop(signals)
+	for sig := range signals {
+		if sig != syscall.SIGUSR2 {
+			break
+		}
+		if restart(application, reloader.Current(), log) {
+			break
+		}
+	}
+	stopWatch()
+
+	ctx, cancel := context.WithTimeout(context.Background(), reloader.Current().Shutdown.DrainTimeout)
+	defer cancel()
+	if err := application.Stop(ctx); err != nil {
+		return fmt.Errorf("server forced to shutdown: %w", err)
+	}
+	log.Info("Server exiting")
+	return nil
+}
+
+// restart starts the new binary on the current listeners and reports
+// whether it took over, in which case this process should drain and exit
+func restart(application *gopark.App, cfg config.Config, log *logrus.Logger) bool {
+	log.Info("Restarting: handing listeners over to a new process")
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.ReadyTimeout)
+	defer cancel()
+	pid, err := application.Restart(ctx)
+	if err != nil {
+		log.Errorf("Restart failed, continuing to serve: %v", err)
+		return false
+	}
+	log.Infof("Process %d is serving; draining", pid)
+	return true
+}
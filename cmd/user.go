@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopark/internal/models"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// newUserCmd builds the user command group. These commands act on the
+// database directly for operational emergencies: no events are published
+func newUserCmd(a *app) *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "user",
+		Short: "Manage users directly in the database",
+		Long:  "Manage users directly in the database, bypassing the API. No domain events are published.",
+	}
+	cmd.PersistentFlags().StringVarP(&output, "output", "o", "table", "Output format: table or json")
+	cmd.RegisterFlagCompletionFunc("output", completeFormats("table", "json"))
+
+	var name, mail string
+	create := &cobra.Command{
+		Use:   "create",
+		Short: "Create a user",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			user := &models.User{Name: name, Mail: mail}
+			if err := user.Validate(); err != nil {
+				return err
+			}
+			dbConn, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			defer dbConn.Close()
+			if err := dbConn.CreateUser(context.Background(), user); err != nil {
+				return err
+			}
+			return printUsers(cmd.OutOrStdout(), output, false, user)
+		},
+	}
+	create.Flags().StringVar(&name, "name", "", "User name")
+	create.Flags().StringVar(&mail, "mail", "", "User email address")
+	create.MarkFlagRequired("name")
+	create.MarkFlagRequired("mail")
+
+	get := &cobra.Command{
+		Use:   "get <id>",
+		Short: "Show a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseUserID(args[0])
+			if err != nil {
+				return err
+			}
+			dbConn, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			defer dbConn.Close()
+			user, err := dbConn.GetUserByID(context.Background(), id)
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("user %d not found", id)
+			}
+			if err != nil {
+				return err
+			}
+			return printUsers(cmd.OutOrStdout(), output, false, user)
+		},
+	}
+
+	var limit, offset int
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List users",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbConn, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			defer dbConn.Close()
+			users, err := dbConn.ListUsers(context.Background(), limit, offset)
+			if err != nil {
+				return err
+			}
+			return printUsers(cmd.OutOrStdout(), output, true, users...)
+		},
+	}
+	list.Flags().IntVar(&limit, "limit", 100, "Maximum number of users (at most 100)")
+	list.Flags().IntVar(&offset, "offset", 0, "Number of users to skip")
+
+	del := &cobra.Command{
+		Use:   "delete <id>",
+		Short: "Delete a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseUserID(args[0])
+			if err != nil {
+				return err
+			}
+			dbConn, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			defer dbConn.Close()
+			err = dbConn.DeleteUser(context.Background(), id)
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("user %d not found", id)
+			}
+			return err
+		},
+	}
+
+	importCmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Create users from a JSON array or a CSV file with name,mail columns",
+		Long:  "Create users from a JSON array of {\"name\", \"mail\"} objects, or a CSV file (.csv) with a name,mail header. Use - to read JSON from stdin.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			users, err := readUsers(args[0])
+			if err != nil {
+				return err
+			}
+			dbConn, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			defer dbConn.Close()
+
+			// Import what is valid and report every failure by its position
+			failed := 0
+			for i, user := range users {
+				if err := user.Validate(); err != nil {
+					a.log.Errorf("Record %d (%s): %v", i+1, user.Mail, err)
+					failed++
+					continue
+				}
+				if err := dbConn.CreateUser(context.Background(), user); err != nil {
+					a.log.Errorf("Record %d (%s): %v", i+1, user.Mail, err)
+					failed++
+				}
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Imported %d of %d users\n", len(users)-failed, len(users))
+			if failed > 0 {
+				return fmt.Errorf("%d users could not be imported", failed)
+			}
+			return nil
+		},
+	}
+
+	cmd.AddCommand(create, get, list, del, importCmd)
+	return cmd
+}
+
+// parseUserID parses a positive user ID argument
+func parseUserID(arg string) (uint, error) {
+	id, err := strconv.ParseUint(arg, 10, 32)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid user ID %q", arg)
+	}
+	return uint(id), nil
+}
+
+// readUsers parses users from a JSON array, stdin (-) or a CSV file
+func readUsers(path string) ([]*models.User, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		records, err := csv.NewReader(r).ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, nil
+		}
+		columns := make(map[string]int)
+		for i, column := range records[0] {
+			columns[strings.ToLower(strings.TrimSpace(column))] = i
+		}
+		nameCol, okName := columns["name"]
+		mailCol, okMail := columns["mail"]
+		if !okName || !okMail {
+			return nil, errors.New("CSV header must contain name and mail columns")
+		}
+		users := make([]*models.User, 0, len(records)-1)
+		for _, record := range records[1:] {
+			users = append(users, &models.User{Name: record[nameCol], Mail: record[mailCol]})
+		}
+		return users, nil
+	}
+
+	var users []*models.User
+	if err := json.NewDecoder(r).Decode(&users); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return users, nil
+}
+
+// printUsers writes users as an aligned table or as JSON: an array when
+// list is set, a single object otherwise
+func printUsers(w io.Writer, format string, list bool, users ...*models.User) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if !list && len(users) == 1 {
+			return enc.Encode(users[0])
+		}
+		if users == nil {
+			users = []*models.User{}
+		}
+		return enc.Encode(users)
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tMAIL")
+		for _, user := range users {
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", user.ID, user.Name, user.Mail)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q (expected table or json)", format)
+	}
+}
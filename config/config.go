@@ -1,48 +1,248 @@
 package config
 
 import (
-	"fmt"
+	"time"
 
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
 // Config defines application configuration
 type Config struct {
-	AppName  string `mapstructure:"appname"` // Use mapstructure tags for Viper
-	Port     int    `mapstructure:"port"`
-	Debug    bool   `mapstructure:"debug"`
-	Redis    string `mapstructure:"redis"`
-	Database struct {
+	AppName   string          `mapstructure:"appname"` // Use mapstructure tags for Viper
+	Port      int             `mapstructure:"port"`
+	Debug     bool            `mapstructure:"debug"`
+	Redis     string          `mapstructure:"redis"`
+	Log       LogConfig       `mapstructure:"log"`
+	RequestID RequestIDConfig `mapstructure:"request_id"`
+	CORS      CORSConfig      `mapstructure:"cors"`
+	Listeners ListenersConfig `mapstructure:"listeners"`
+	Shutdown  ShutdownConfig  `mapstructure:"shutdown"`
+	Health    HealthConfig    `mapstructure:"health"`
+	Tracing   TracingConfig   `mapstructure:"tracing"`
+	TLS       TLSConfig       `mapstructure:"tls"`
+	HTTP2     HTTP2Config     `mapstructure:"http2"`
+	Database  struct {
 		Type string `mapstructure:"type"` // Database type, e.g. sqlite
 		Path string `mapstructure:"path"` // SQLite database file path
 	} `mapstructure:"database"`
+	Events      EventsConfig      `mapstructure:"events"`
+	Jobs        JobsConfig        `mapstructure:"jobs"`
+	Scheduler   SchedulerConfig   `mapstructure:"scheduler"`
+	Backup      BackupConfig      `mapstructure:"backup"`
+	Replication ReplicationConfig `mapstructure:"replication"`
+	Secrets     SecretsConfig     `mapstructure:"secrets"`
+}
+
+// LogConfig controls the log output; the level follows debug
+type LogConfig struct {
+	Format   string            `mapstructure:"format"` // text or json
+	Sampling LogSamplingConfig `mapstructure:"sampling"`
+}
+
+// LogSamplingConfig limits repeated info and debug messages, such as the
+// access log; warnings and errors are always logged
+type LogSamplingConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	Initial    int           `mapstructure:"initial"`    // Occurrences of a message logged per tick before sampling starts
+	Thereafter int           `mapstructure:"thereafter"` // Then every Nth occurrence is logged; 0 drops the rest of the tick
+	Tick       time.Duration `mapstructure:"tick"`
+}
+
+// RequestIDConfig controls how request IDs are assigned
+type RequestIDConfig struct {
+	TrustedNetworks []string `mapstructure:"trusted_networks"` // IPs or CIDRs whose X-Request-ID or traceparent is kept; other callers get a new ID
+}
+
+// CORSConfig controls which cross-origin requests browsers may make to the
+// public routes
+type CORSConfig struct {
+	Default CORSPolicy  `mapstructure:"default"` // Applies to paths without a route override
+	Routes  []CORSRoute `mapstructure:"routes"`  // Overrides by path prefix; the longest matching prefix wins
+}
+
+// CORSPolicy describes the cross-origin requests allowed on a set of routes
+type CORSPolicy struct {
+	AllowedOrigins   []string      `mapstructure:"allowed_origins"`   // https://app.example.com, https://*.example.com, regex:<expression> or *; empty allows none
+	AllowedMethods   []string      `mapstructure:"allowed_methods"`   // Methods a preflight may ask for; empty allows GET, HEAD and POST
+	AllowedHeaders   []string      `mapstructure:"allowed_headers"`   // Request headers a preflight may ask for; * allows any without credentials
+	ExposedHeaders   []string      `mapstructure:"exposed_headers"`   // Response headers scripts may read besides the CORS-safelisted ones
+	AllowCredentials bool          `mapstructure:"allow_credentials"` // Allow cookies and Authorization; not with a * origin
+	MaxAge           time.Duration `mapstructure:"max_age"`           // How long browsers may cache a preflight; 0 leaves it to the browser
+}
+
+// CORSRoute overrides the default policy below a path prefix. Its fields
+// replace the default policy as a whole rather than being merged with it
+type CORSRoute struct {
+	Prefix     string `mapstructure:"prefix"` // e.g. /api/v1/users; matches whole path segments
+	CORSPolicy `mapstructure:",squash"`
+}
+
+// ListenersConfig configures the HTTP listeners
+type ListenersConfig struct {
+	Public ListenerConfig `mapstructure:"public"` // User API; the address defaults to :port
+	Admin  ListenerConfig `mapstructure:"admin"`  // Admin API and pprof; an empty address disables it
+}
+
+// ShutdownConfig controls stopping and restarting the server
+type ShutdownConfig struct {
+	DrainTimeout time.Duration `mapstructure:"drain_timeout"` // How long in-flight requests and jobs may finish on SIGTERM or after a restart
+	ReadyTimeout time.Duration `mapstructure:"ready_timeout"` // How long a SIGUSR2 restart waits for the new process to start serving
+
+	ReadinessDelay time.Duration `mapstructure:"readiness_delay"` // How long readiness fails before the listeners close, so load balancers stop routing first
+}
+
+// HealthConfig controls the liveness and readiness checks
+type HealthConfig struct {
+	CacheTTL      time.Duration `mapstructure:"cache_ttl"`        // How long a check result is reused; 0 runs checks on every probe
+	Timeout       time.Duration `mapstructure:"timeout"`          // Per-check timeout; 0 leaves only the request deadline
+	MinFreeDiskMB int           `mapstructure:"min_free_disk_mb"` // The service is unready below this free space in the database directory
+	MaxJobBacklog int           `mapstructure:"max_job_backlog"`  // More pending jobs are reported as a warning; 0 disables the check
+	StallAfter    time.Duration `mapstructure:"stall_after"`      // Liveness fails once the database has been unreachable this long; 0 disables the check
+}
+
+// TracingConfig controls OpenTelemetry tracing
+type TracingConfig struct {
+	Enabled     bool    `mapstructure:"enabled"`
+	Exporter    string  `mapstructure:"exporter"`     // otlp (OTLP/HTTP) or stdout
+	Endpoint    string  `mapstructure:"endpoint"`     // otlp: collector base URL; spans are posted to /v1/traces
+	SampleRatio float64 `mapstructure:"sample_ratio"` // Fraction of new traces recorded; a sampled incoming traceparent is always followed
+}
+
+// ListenerConfig configures one HTTP listener
+type ListenerConfig struct {
+	Address      string        `mapstructure:"address"` // host:port, unix:/path/to.sock, or systemd:name for socket activation
+	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout  time.Duration `mapstructure:"idle_timeout"`
+}
+
+// TLSConfig controls HTTPS on the public listener
+type TLSConfig struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	CertFile     string   `mapstructure:"cert_file"`      // PEM certificate chain; reloaded when the file changes
+	KeyFile      string   `mapstructure:"key_file"`       // PEM private key; reloaded together with cert_file
+	SelfSigned   bool     `mapstructure:"self_signed"`    // Development only: generate a certificate for localhost at startup
+	MinVersion   string   `mapstructure:"min_version"`    // 1.2 or 1.3
+	CipherSuites []string `mapstructure:"cipher_suites"`  // TLS 1.2 suites by Go name, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256; empty uses Go's defaults
+	ClientAuth   string   `mapstructure:"client_auth"`    // Client certificates: none, request or require
+	ClientCAFile string   `mapstructure:"client_ca_file"` // PEM bundle trusted to sign client certificates
+	IdentityFrom string   `mapstructure:"identity_from"`  // Client certificate field naming the caller: cn, dns, email or uri
+}
+
+// HTTP2Config controls HTTP/2 support
+type HTTP2Config struct {
+	Enabled bool `mapstructure:"enabled"` // Negotiate HTTP/2 over TLS
+	H2C     bool `mapstructure:"h2c"`     // Accept HTTP/2 without TLS (prior knowledge), for in-cluster traffic
+}
+
+// SecretsConfig selects the provider that resolves ${secret:name} references
+type SecretsConfig struct {
+	Provider  string `mapstructure:"provider"`   // env, file or encrypted
+	EnvPrefix string `mapstructure:"env_prefix"` // env provider: variable prefix, e.g. GOPARK_SECRET_
+	Dir       string `mapstructure:"dir"`        // file provider: one file per secret, e.g. /run/secrets
+	File      string `mapstructure:"file"`       // encrypted provider: sealed secrets file
+	KeyFile   string `mapstructure:"key_file"`   // encrypted provider: key file; GOPARK_SECRETS_KEY takes precedence
+}
+
+// BackupConfig controls online database backups
+type BackupConfig struct {
+	Dir      string        `mapstructure:"dir"`      // Directory backups are written to
+	Retain   int           `mapstructure:"retain"`   // Number of most recent backups to keep; 0 keeps all
+	MaxAge   time.Duration `mapstructure:"max_age"`  // Backups older than this are deleted; 0 keeps them
+	Compress bool          `mapstructure:"compress"` // Gzip backups after the integrity check
+}
+
+// JobsConfig controls the background job worker pool
+type JobsConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	Concurrency  int           `mapstructure:"concurrency"`   // Number of worker goroutines
+	PollInterval time.Duration `mapstructure:"poll_interval"` // Delay between polls when the queue is empty
+	MaxAttempts  int           `mapstructure:"max_attempts"`  // Default attempts before a job is marked failed
+	Timeout      time.Duration `mapstructure:"timeout"`       // Default per-job execution timeout
+	Backoff      time.Duration `mapstructure:"backoff"`       // Base delay for exponential retry backoff
+	MaxBackoff   time.Duration `mapstructure:"max_backoff"`
+	StaleAfter   time.Duration `mapstructure:"stale_after"` // Running jobs older than this are requeued on startup
+	Retention    time.Duration `mapstructure:"retention"`   // Finished jobs older than this are purged by maintenance
+}
+
+// ReplicationConfig controls continuous WAL shipping to a replica location
+type ReplicationConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`
+	Path             string        `mapstructure:"path"`              // Local replica directory
+	SyncInterval     time.Duration `mapstructure:"sync_interval"`     // How often new WAL frames are shipped
+	SnapshotInterval time.Duration `mapstructure:"snapshot_interval"` // How often a new generation starts with a full snapshot
+	Retention        time.Duration `mapstructure:"retention"`         // Point-in-time restore window
+	CheckpointFrames int           `mapstructure:"checkpoint_frames"` // Checkpoint once the WAL holds this many shipped frames
+}
+
+// SchedulerConfig controls periodic maintenance tasks
+type SchedulerConfig struct {
+	Enabled    bool            `mapstructure:"enabled"`
+	InstanceID string          `mapstructure:"instance_id"` // Lease holder name; defaults to hostname and PID
+	Jitter     time.Duration   `mapstructure:"jitter"`      // Maximum random delay added before each run
+	LeaseTTL   time.Duration   `mapstructure:"lease_ttl"`   // How long a run holds its lease before others may take over
+	Tasks      []ScheduledTask `mapstructure:"tasks"`
+}
+
+// ScheduledTask binds a registered task to a cron expression
+type ScheduledTask struct {
+	Name     string `mapstructure:"name"`
+	Schedule string `mapstructure:"schedule"` // Standard 5-field cron expression or descriptor such as @hourly
+	Disabled bool   `mapstructure:"disabled"`
+}
+
+// EventsConfig controls publishing of domain events to a message broker
+type EventsConfig struct {
+	Enabled bool       `mapstructure:"enabled"`
+	Driver  string     `mapstructure:"driver"` // Broker implementation, e.g. nats
+	NATS    NATSConfig `mapstructure:"nats"`
+}
+
+// NATSConfig configures the NATS JetStream event publisher
+type NATSConfig struct {
+	URL             string         `mapstructure:"url"`
+	Token           string         `mapstructure:"token" secret:"true"`
+	Stream          string         `mapstructure:"stream"`           // JetStream stream name
+	StreamSubjects  []string       `mapstructure:"stream_subjects"`  // Subjects bound to the stream when it is created
+	CreateStream    bool           `mapstructure:"create_stream"`    // Create or update the stream on startup
+	SubjectTemplate string         `mapstructure:"subject_template"` // Default subject template, e.g. gopark.{{.Type}}
+	Subjects        []SubjectRoute `mapstructure:"subjects"`         // Per event type subject templates
+	MaxRetries      int            `mapstructure:"max_retries"`
+	RetryBackoff    time.Duration  `mapstructure:"retry_backoff"`
+	AckTimeout      time.Duration  `mapstructure:"ack_timeout"`
+}
+
+// SubjectRoute overrides the subject template for a single event type
+type SubjectRoute struct {
+	Type     string `mapstructure:"type"`     // Event type, e.g. user.deleted
+	Template string `mapstructure:"template"` // Subject template, e.g. gopark.users.deleted
+}
+
+// EnvPrefix is prepended to environment variable overrides, e.g. GOPARK_DATABASE_PATH
+const EnvPrefix = "GOPARK"
+
+// ProfileEnvVar selects an optional profile file layered over config.yaml
+const ProfileEnvVar = EnvPrefix + "_ENV"
+
+// Default returns the built-in defaults without reading files, the
+// environment or flags; useful when embedding gopark or in tests
+func Default() Config {
+	v := viper.New()
+	setDefaults(v)
+	var cfg Config
+	v.Unmarshal(&cfg) // Defaults always decode
+	return cfg
 }
 
 // LoadConfig reads configuration and returns a Config
-func LoadConfig(path string) (config Config, err error) {
-	viper.SetConfigName("config") // Base name without extension
-	viper.SetConfigType("yaml")   // Config file format
-	viper.AddConfigPath(path)     // Search path
-
-	viper.AutomaticEnv()
-
-	err = viper.ReadInConfig()
-	if err != nil {
-		return Config{}, fmt.Errorf("error reading config file: %w", err)
-	}
-
-	err = viper.Unmarshal(&config)
-	if err != nil {
-		return Config{}, fmt.Errorf("unable to decode config into struct: %w", err)
-	}
-
-	// Apply defaults
-	if config.Database.Type == "" {
-		config.Database.Type = "sqlite"
-	}
-	if config.Database.Path == "" {
-		config.Database.Path = "./gopark.db"
-	}
-
-	return config, nil
+func LoadConfig(path string) (Config, error) {
+	return NewLoader(WithSearchPaths(path)).Load()
+}
+
+// LoadConfigWithFlags reads configuration from path with command-line
+// overrides from flags (registered with RegisterFlags; nil for none). See
+// Loader.Load for the precedence of each source
+func LoadConfigWithFlags(path string, flags *pflag.FlagSet) (Config, error) {
+	return NewLoader(WithSearchPaths(path), WithFlags(flags)).Load()
 }
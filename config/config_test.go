@@ -0,0 +1,151 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig writes a config file named name into dir
+func writeConfig(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+}
+
+// load loads dir with the given flag arguments
+func load(t *testing.T, dir string, args ...string) (Config, error) {
+	t.Helper()
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	RegisterFlags(flags)
+	require.NoError(t, flags.Parse(args))
+	return LoadConfigWithFlags(dir, flags)
+}
+
+// TestLoadConfigPrecedence verifies defaults < file < profile < env < flags
+func TestLoadConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "config.yaml", `
+appname: base
+port: 8080
+database:
+  path: ./base.db
+`)
+	writeConfig(t, dir, "config.staging.yaml", `
+port: 8081
+database:
+  path: ./staging.db
+`)
+
+	// Test case 1: defaults fill keys missing from the file
+	cfg, err := load(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, "base", cfg.AppName)
+	assert.Equal(t, 8080, cfg.Port)
+	assert.Equal(t, "./base.db", cfg.Database.Path)
+	assert.Equal(t, "sqlite", cfg.Database.Type)
+	assert.Equal(t, 4, cfg.Jobs.Concurrency)
+
+	// Test case 2: the profile selected by GOPARK_ENV overrides the base file
+	t.Setenv("GOPARK_ENV", "staging")
+	cfg, err = load(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, "base", cfg.AppName)
+	assert.Equal(t, 8081, cfg.Port)
+	assert.Equal(t, "./staging.db", cfg.Database.Path)
+
+	// Test case 3: prefixed environment variables override files, including nested keys
+	envPath := filepath.Join(dir, "env.db")
+	t.Setenv("GOPARK_DATABASE_PATH", envPath)
+	t.Setenv("GOPARK_JOBS_CONCURRENCY", "9")
+	t.Setenv("GOPARK_JOBS_TIMEOUT", "30s")
+	cfg, err = load(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 8081, cfg.Port)
+	assert.Equal(t, envPath, cfg.Database.Path)
+	assert.Equal(t, 9, cfg.Jobs.Concurrency)
+	assert.Equal(t, 30*time.Second, cfg.Jobs.Timeout)
+
+	// Test case 4: flags override the environment
+	flagPath := filepath.Join(dir, "flag.db")
+	cfg, err = load(t, dir, "--database.path", flagPath, "--port", "9090", "--set", "jobs.concurrency=2")
+	require.NoError(t, err)
+	assert.Equal(t, 9090, cfg.Port)
+	assert.Equal(t, flagPath, cfg.Database.Path)
+	assert.Equal(t, 2, cfg.Jobs.Concurrency)
+}
+
+// TestLoadConfigProfiles covers profile selection and errors
+func TestLoadConfigProfiles(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "config.yaml", "port: 8080\n")
+	writeConfig(t, dir, "config.dev.yaml", "port: 7000\n")
+	writeConfig(t, dir, "config.prod.yaml", "port: 80\n")
+
+	// Test case 1: --env takes precedence over GOPARK_ENV
+	t.Setenv("GOPARK_ENV", "prod")
+	cfg, err := load(t, dir, "--env", "dev")
+	require.NoError(t, err)
+	assert.Equal(t, 7000, cfg.Port)
+
+	// Test case 2: a missing profile file is an error
+	_, err = load(t, dir, "--env", "missing")
+	assert.Error(t, err)
+
+	// Test case 3: malformed --set values are rejected
+	_, err = load(t, dir, "--set", "port")
+	assert.Error(t, err)
+}
+
+// TestLoaderInMemory loads independent configs in parallel from in-memory YAML
+func TestLoaderInMemory(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/config.yaml":     {Data: []byte("appname: a\nport: 8001\n")},
+		"b/app.yaml":        {Data: []byte("appname: b\nport: 8002\n")},
+		"b/app.canary.yaml": {Data: []byte("port: 8003\n")},
+	}
+
+	// Test case 1: loaders do not share state
+	t.Run("First", func(t *testing.T) {
+		t.Parallel()
+		cfg, err := NewLoader(WithFS(fsys), WithSearchPaths("missing", "a")).Load()
+		require.NoError(t, err)
+		assert.Equal(t, "a", cfg.AppName)
+		assert.Equal(t, 8001, cfg.Port)
+	})
+
+	// Test case 2: file name, defaults and profile flags are honoured
+	t.Run("Second", func(t *testing.T) {
+		t.Parallel()
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		RegisterFlags(flags)
+		require.NoError(t, flags.Parse([]string{"--env", "canary"}))
+
+		loader := NewLoader(
+			WithFS(fsys),
+			WithSearchPaths("b"),
+			WithFileName("app"),
+			WithEnvPrefix("GOPARK_TEST_UNUSED"),
+			WithDefaults(map[string]any{"jobs.concurrency": 16}),
+			WithFlags(flags),
+		)
+		cfg, err := loader.Load()
+		require.NoError(t, err)
+		assert.Equal(t, "b", cfg.AppName)
+		assert.Equal(t, 8003, cfg.Port)
+		assert.Equal(t, 16, cfg.Jobs.Concurrency)
+		assert.Equal(t, []string{"b/app.yaml", "b/app.canary.yaml"}, loader.Files())
+	})
+
+	// Test case 3: a missing config file is an error
+	t.Run("Missing", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewLoader(WithFS(fsys), WithSearchPaths("nowhere")).Load()
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// setDefaults registers a default for every configuration key. Viper only
+// consults the environment for keys it knows about, so keys without a
+// meaningful default are registered with their zero value
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("appname", "gopark")
+	v.SetDefault("port", 8080)
+	v.SetDefault("debug", false)
+	v.SetDefault("redis", "")
+
+	v.SetDefault("log.format", "text")
+	v.SetDefault("log.sampling.enabled", false)
+	v.SetDefault("log.sampling.initial", 100)
+	v.SetDefault("log.sampling.thereafter", 100)
+	v.SetDefault("log.sampling.tick", time.Second)
+
+	v.SetDefault("request_id.trusted_networks", []string{"127.0.0.1/32", "::1/128"}) // A local reverse proxy
+
+	v.SetDefault("cors.default.allowed_origins", []string{"*"})
+	v.SetDefault("cors.default.allowed_methods", []string{"GET", "HEAD", "POST", "PUT", "DELETE"})
+	v.SetDefault("cors.default.allowed_headers", []string{"Accept", "Authorization", "Cache-Control", "Content-Type", "X-CSRF-Token", "X-Request-ID", "X-Requested-With"})
+	v.SetDefault("cors.default.exposed_headers", []string{"X-Request-ID"})
+	v.SetDefault("cors.default.allow_credentials", false)
+	v.SetDefault("cors.default.max_age", 10*time.Minute)
+	v.SetDefault("cors.routes", []CORSRoute{})
+
+	v.SetDefault("listeners.public.address", "")
+	v.SetDefault("listeners.public.read_timeout", 10*time.Second)
+	v.SetDefault("listeners.public.write_timeout", 10*time.Second)
+	v.SetDefault("listeners.public.idle_timeout", 120*time.Second)
+	v.SetDefault("listeners.admin.address", "127.0.0.1:8090")
+	v.SetDefault("listeners.admin.read_timeout", 10*time.Second)
+	v.SetDefault("listeners.admin.write_timeout", 60*time.Second) // Long enough for a 30s CPU profile
+	v.SetDefault("listeners.admin.idle_timeout", 120*time.Second)
+
+	v.SetDefault("shutdown.drain_timeout", 30*time.Second)
+	v.SetDefault("shutdown.ready_timeout", 30*time.Second)
+	v.SetDefault("shutdown.readiness_delay", 5*time.Second)
+
+	v.SetDefault("health.cache_ttl", time.Second)
+	v.SetDefault("health.timeout", 2*time.Second)
+	v.SetDefault("health.min_free_disk_mb", 100)
+	v.SetDefault("health.max_job_backlog", 1000)
+	v.SetDefault("health.stall_after", 2*time.Minute)
+
+	v.SetDefault("tracing.enabled", false)
+	v.SetDefault("tracing.exporter", "otlp")
+	v.SetDefault("tracing.endpoint", "http://localhost:4318")
+	v.SetDefault("tracing.sample_ratio", 1.0)
+
+	v.SetDefault("tls.enabled", false)
+	v.SetDefault("tls.cert_file", "")
+	v.SetDefault("tls.key_file", "")
+	v.SetDefault("tls.self_signed", false)
+	v.SetDefault("tls.min_version", "1.2")
+	v.SetDefault("tls.cipher_suites", []string{})
+	v.SetDefault("tls.client_auth", "none")
+	v.SetDefault("tls.client_ca_file", "")
+	v.SetDefault("tls.identity_from", "cn")
+
+	v.SetDefault("http2.enabled", true)
+	v.SetDefault("http2.h2c", false)
+
+	v.SetDefault("database.type", "sqlite")
+	v.SetDefault("database.path", "./gopark.db")
+
+	v.SetDefault("events.enabled", false)
+	v.SetDefault("events.driver", "nats")
+	v.SetDefault("events.nats.url", "nats://127.0.0.1:4222")
+	v.SetDefault("events.nats.token", "")
+	v.SetDefault("events.nats.stream", "GOPARK_EVENTS")
+	v.SetDefault("events.nats.stream_subjects", []string{"gopark.>"})
+	v.SetDefault("events.nats.create_stream", false)
+	v.SetDefault("events.nats.subject_template", "gopark.{{.Type}}")
+	v.SetDefault("events.nats.subjects", []SubjectRoute{})
+	v.SetDefault("events.nats.max_retries", 3)
+	v.SetDefault("events.nats.retry_backoff", 100*time.Millisecond)
+	v.SetDefault("events.nats.ack_timeout", 5*time.Second)
+
+	v.SetDefault("jobs.enabled", false)
+	v.SetDefault("jobs.concurrency", 4)
+	v.SetDefault("jobs.poll_interval", time.Second)
+	v.SetDefault("jobs.max_attempts", 5)
+	v.SetDefault("jobs.timeout", 5*time.Minute)
+	v.SetDefault("jobs.backoff", 10*time.Second)
+	v.SetDefault("jobs.max_backoff", 10*time.Minute)
+	v.SetDefault("jobs.stale_after", 15*time.Minute)
+	v.SetDefault("jobs.retention", 7*24*time.Hour)
+
+	v.SetDefault("scheduler.enabled", false)
+	v.SetDefault("scheduler.instance_id", "")
+	v.SetDefault("scheduler.jitter", time.Duration(0))
+	v.SetDefault("scheduler.lease_ttl", 10*time.Minute)
+	v.SetDefault("scheduler.tasks", []ScheduledTask{})
+
+	v.SetDefault("backup.dir", "./backups")
+	v.SetDefault("backup.retain", 7)
+	v.SetDefault("backup.max_age", time.Duration(0))
+	v.SetDefault("backup.compress", false)
+
+	v.SetDefault("replication.enabled", false)
+	v.SetDefault("replication.path", "./replica")
+	v.SetDefault("replication.sync_interval", time.Second)
+	v.SetDefault("replication.snapshot_interval", 24*time.Hour)
+	v.SetDefault("replication.retention", 72*time.Hour)
+	v.SetDefault("replication.checkpoint_frames", 1000)
+
+	v.SetDefault("secrets.provider", "env")
+	v.SetDefault("secrets.env_prefix", EnvPrefix+"_SECRET_")
+	v.SetDefault("secrets.dir", "/run/secrets")
+	v.SetDefault("secrets.file", "")
+	v.SetDefault("secrets.key_file", "")
+}
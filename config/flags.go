@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// keyFlags are configuration keys exposed as dedicated command-line flags
+var keyFlags = []struct {
+	key   string
+	usage string
+}{
+	{"appname", "Application name"},
+	{"port", "HTTP listen port"},
+	{"debug", "Enable debug logging and Gin debug mode"},
+	{"redis", "Redis address"},
+	{"database.path", "SQLite database file path"},
+}
+
+// RegisterFlags adds configuration override flags to fs
+func RegisterFlags(fs *pflag.FlagSet) {
+	fs.String("env", "", "Configuration profile layered over config.yaml (overrides "+ProfileEnvVar+")")
+	for _, f := range keyFlags {
+		switch f.key {
+		case "port":
+			fs.Int(f.key, 0, f.usage)
+		case "debug":
+			fs.Bool(f.key, false, f.usage)
+		default:
+			fs.String(f.key, "", f.usage)
+		}
+	}
+	fs.StringArray("set", nil, "Override any configuration key, e.g. --set jobs.concurrency=8 (repeatable)")
+}
+
+// bindFlags applies changed flags on top of the other configuration sources
+// and returns the keys they override
+func bindFlags(v *viper.Viper, fs *pflag.FlagSet) (map[string]bool, error) {
+	keys := make(map[string]bool)
+	for _, f := range keyFlags {
+		if flag := fs.Lookup(f.key); flag != nil && flag.Changed {
+			if err := v.BindPFlag(f.key, flag); err != nil {
+				return nil, err
+			}
+			keys[f.key] = true
+		}
+	}
+
+	overrides, err := fs.GetStringArray("set")
+	if err != nil {
+		return keys, nil // --set was not registered on this flag set
+	}
+	for _, override := range overrides {
+		key, value, ok := strings.Cut(override, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --set %q, expected key=value", override)
+		}
+		v.Set(key, value)
+		keys[key] = true
+	}
+	return keys, nil
+}
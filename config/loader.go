@@ -0,0 +1,263 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Loader builds a Config from defaults, files, environment variables and
+// flags. Each Loader owns its own viper instance, so several configurations
+// can be loaded side by side without sharing global state
+type Loader struct {
+	opts      []Option
+	v         *viper.Viper
+	paths     []string
+	name      string
+	envPrefix string
+	defaults  map[string]any
+	fsys      fs.FS
+	flags     *pflag.FlagSet
+	files     []string
+	secrets   map[string]bool
+	profile   *viper.Viper // Keys set by the profile file, for provenance
+	source    SourceFunc
+}
+
+// Option configures a Loader
+type Option func(*Loader)
+
+// WithSearchPaths sets the directories searched for the config file, in order
+func WithSearchPaths(paths ...string) Option {
+	return func(l *Loader) { l.paths = paths }
+}
+
+// WithFileName sets the config file base name without extension (default "config")
+func WithFileName(name string) Option {
+	return func(l *Loader) { l.name = name }
+}
+
+// WithEnvPrefix sets the environment variable prefix (default EnvPrefix)
+func WithEnvPrefix(prefix string) Option {
+	return func(l *Loader) { l.envPrefix = prefix }
+}
+
+// WithDefaults overrides built-in defaults, keyed by dotted key path
+func WithDefaults(defaults map[string]any) Option {
+	return func(l *Loader) { l.defaults = defaults }
+}
+
+// WithFS reads config files from fsys instead of the operating system.
+// Search paths are then slash-separated paths within fsys
+func WithFS(fsys fs.FS) Option {
+	return func(l *Loader) { l.fsys = fsys }
+}
+
+// WithFlags applies command-line overrides registered with RegisterFlags
+func WithFlags(flags *pflag.FlagSet) Option {
+	return func(l *Loader) { l.flags = flags }
+}
+
+// NewLoader creates a Loader that searches the current directory for
+// config.yaml unless configured otherwise
+func NewLoader(opts ...Option) *Loader {
+	l := &Loader{
+		opts:      opts,
+		v:         viper.New(),
+		paths:     []string{"."},
+		name:      "config",
+		envPrefix: EnvPrefix,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Viper returns the underlying viper instance, populated by Load
+func (l *Loader) Viper() *viper.Viper {
+	return l.v
+}
+
+// Files returns the config files read by Load, base file first
+func (l *Loader) Files() []string {
+	return l.files
+}
+
+// Source reports which layer supplied the value of key in the last Load:
+// SourceDefault, SourceFile, SourceProfile, SourceEnv or SourceFlag
+func (l *Loader) Source(key string) string {
+	if l.source == nil {
+		return SourceDefault
+	}
+	return l.source(key)
+}
+
+// SecretKeys returns the keys whose values Load resolved from a secret
+// reference or a _FILE variable, in addition to fields tagged secret:"true"
+func (l *Loader) SecretKeys() map[string]bool {
+	keys := make(map[string]bool, len(l.secrets))
+	for key := range l.secrets {
+		keys[key] = true
+	}
+	return keys
+}
+
+// Load reads configuration and returns a validated Config. Sources are
+// layered, with later entries taking precedence over earlier ones:
+//
+//  1. built-in defaults (setDefaults), then WithDefaults
+//  2. <name>.yaml from the first search path containing it
+//  3. <name>.<profile>.yaml from the same directory, where the profile comes
+//     from --env or <prefix>_ENV
+//  4. environment variables: the prefix, an underscore, and the key with dots
+//     replaced by underscores, e.g. GOPARK_DATABASE_PATH for database.path.
+//     Appending _FILE reads the value from the named file instead, e.g.
+//     GOPARK_EVENTS_NATS_TOKEN_FILE=/run/secrets/nats_token
+//  5. command-line flags, including --set key=value
+//
+// Any string value may then embed ${secret:name} references, resolved
+// through the provider selected by the secrets section
+func (l *Loader) Load() (config Config, err error) {
+	v := l.v
+	setDefaults(v)
+	for key, value := range l.defaults {
+		v.SetDefault(key, value)
+	}
+
+	// Map nested keys to prefixed variables: database.path -> GOPARK_DATABASE_PATH
+	v.SetEnvPrefix(l.envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	v.SetConfigType("yaml") // Config file format
+	dir, err := l.readFile(l.name+".yaml", v.ReadConfig)
+	if err != nil {
+		return Config{}, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	// Layer the profile file over the base file
+	profile := os.Getenv(l.envPrefix + "_ENV")
+	if l.flags != nil {
+		if f := l.flags.Lookup("env"); f != nil && f.Changed {
+			profile = f.Value.String()
+		}
+	}
+	if profile != "" {
+		l.profile = viper.New()
+		l.profile.SetConfigType("yaml")
+		merge := func(r io.Reader) error {
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			if err := l.profile.ReadConfig(bytes.NewReader(data)); err != nil {
+				return err
+			}
+			return v.MergeConfig(bytes.NewReader(data))
+		}
+		if _, err := l.readFileIn(dir, l.name+"."+profile+".yaml", merge); err != nil {
+			return Config{}, fmt.Errorf("error reading %s profile: %w", profile, err)
+		}
+	}
+
+	var flagKeys map[string]bool
+	if l.flags != nil {
+		if flagKeys, err = bindFlags(v, l.flags); err != nil {
+			return Config{}, err
+		}
+	}
+
+	secrets, fileKeys, err := l.resolveSecrets(v, flagKeys)
+	if err != nil {
+		return Config{}, fmt.Errorf("unable to resolve secrets: %w", err)
+	}
+	l.secrets = secrets
+
+	err = v.Unmarshal(&config)
+	if err != nil {
+		return Config{}, fmt.Errorf("unable to decode config into struct: %w", err)
+	}
+
+	// Report every invalid value at once, before anything is started
+	l.source = l.sourceOf(flagKeys, fileKeys)
+	if err := validate(config, l.source, l.isSecret); err != nil {
+		return Config{}, err
+	}
+
+	return config, nil
+}
+
+// readFile loads file from the first search path that contains it and
+// returns that directory
+func (l *Loader) readFile(file string, apply func(io.Reader) error) (string, error) {
+	for _, dir := range l.paths {
+		_, err := l.readFileIn(dir, file, apply)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		return dir, err
+	}
+	return "", fmt.Errorf("%s not found in %v: %w", file, l.paths, fs.ErrNotExist)
+}
+
+// readFileIn loads dir/file into viper through apply
+func (l *Loader) readFileIn(dir, file string, apply func(io.Reader) error) (string, error) {
+	var name string
+	var data []byte
+	var err error
+	if l.fsys != nil {
+		name = path.Join(dir, file)
+		data, err = fs.ReadFile(l.fsys, name)
+	} else {
+		name = filepath.Join(dir, file)
+		data, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := apply(bytes.NewReader(data)); err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	l.files = append(l.files, name)
+	return name, nil
+}
+
+// envVar returns the environment variable that overrides key
+func (l *Loader) envVar(key string) string {
+	return l.envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// sourceOf reports which layer supplied the value of key, following the
+// precedence documented on Load
+func (l *Loader) sourceOf(flagKeys, fileKeys map[string]bool) SourceFunc {
+	return func(key string) string {
+		if flagKeys[key] {
+			return SourceFlag
+		}
+		if _, ok := os.LookupEnv(l.envVar(key)); ok || fileKeys[key] {
+			return SourceEnv
+		}
+		if l.profile != nil && l.profile.InConfig(key) {
+			return SourceProfile
+		}
+		if l.v.InConfig(key) {
+			return SourceFile
+		}
+		return SourceDefault
+	}
+}
+
+// isSecret reports whether the value of key must be redacted
+func (l *Loader) isSecret(key string) bool {
+	return IsSecretKey(key) || l.secrets[key]
+}
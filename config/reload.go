@@ -0,0 +1,273 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+// reloadableKeys lists the keys that can change without a restart. A key
+// matches itself and every key nested below it; all other keys require a restart
+var reloadableKeys = []string{
+	"debug",
+	"log",
+	"cors",
+	"shutdown",
+	"backup.retain",
+	"backup.max_age",
+	"backup.compress",
+}
+
+// ErrRestartRequired is returned when a reload changes keys that are only
+// read at startup; the reload is rejected as a whole
+var ErrRestartRequired = errors.New("configuration change requires a restart")
+
+// reloadDebounce coalesces the burst of events editors emit on save
+const reloadDebounce = 100 * time.Millisecond
+
+// Change describes one changed configuration key
+type Change struct {
+	Key string
+	Old string
+	New string
+}
+
+// ChangeFunc is notified with the previous and new configuration after a
+// successful reload. Subscribers run synchronously and must not block; they
+// may call Current and Subscribe but not Reload
+type ChangeFunc func(old, new Config)
+
+// Reloader re-reads configuration on file changes or SIGHUP, validates it and
+// applies it when only reloadable keys changed
+type Reloader struct {
+	loader    *Loader
+	log       *logrus.Logger
+	reloading sync.Mutex // Serializes reloads so subscribers see changes in order
+	mu        sync.Mutex // Guards current, subs and nextID
+	current   Config
+	subs      map[int]ChangeFunc
+	nextID    int
+}
+
+// NewReloader creates a Reloader for the configuration loaded by loader,
+// which must have completed Load; current is the configuration it returned
+func NewReloader(loader *Loader, current Config, log *logrus.Logger) *Reloader {
+	return &Reloader{
+		loader:  loader,
+		log:     log,
+		current: current,
+		subs:    make(map[int]ChangeFunc),
+	}
+}
+
+// Current returns the configuration currently in effect
+func (r *Reloader) Current() Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Subscribe registers fn for future changes and returns a function that
+// removes the subscription
+func (r *Reloader) Subscribe(fn ChangeFunc) (unsubscribe func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := r.nextID
+	r.nextID++
+	r.subs[id] = fn
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.subs, id)
+	}
+}
+
+// Reload loads and validates the configuration again. Invalid configuration
+// and changes to restart-only keys are rejected and logged, leaving the
+// current configuration in place; otherwise every subscriber is notified
+func (r *Reloader) Reload() error {
+	r.reloading.Lock()
+	defer r.reloading.Unlock()
+
+	loader := NewLoader(r.loader.opts...)
+	next, err := loader.Load()
+	if err != nil {
+		r.log.Errorf("Configuration reload rejected: %v", err)
+		return err
+	}
+
+	secrets := loader.SecretKeys()
+	for key := range r.loader.SecretKeys() {
+		secrets[key] = true
+	}
+	changes := Diff(r.Current(), next, secrets)
+	if len(changes) == 0 {
+		r.log.Debug("Configuration reloaded without changes")
+		return nil
+	}
+
+	var restart []Change
+	for _, change := range changes {
+		if !isReloadable(change.Key) {
+			restart = append(restart, change)
+		}
+	}
+	if len(restart) > 0 {
+		for _, change := range restart {
+			r.log.WithFields(logrus.Fields{"key": change.Key, "old": change.Old, "new": change.New}).
+				Warn("Configuration key requires a restart")
+		}
+		r.log.Errorf("Configuration reload rejected: %d keys require a restart", len(restart))
+		return ErrRestartRequired
+	}
+
+	r.mu.Lock()
+	old := r.current
+	r.current = next
+	subs := make([]ChangeFunc, 0, len(r.subs))
+	for _, id := range sortedIDs(r.subs) {
+		subs = append(subs, r.subs[id])
+	}
+	r.mu.Unlock()
+
+	for _, change := range changes {
+		r.log.WithFields(logrus.Fields{"key": change.Key, "old": change.Old, "new": change.New}).
+			Info("Configuration key reloaded")
+	}
+	for _, fn := range subs {
+		fn(old, next)
+	}
+	return nil
+}
+
+// Watch reloads the configuration when one of its files changes or the
+// process receives SIGHUP, until ctx is canceled
+func (r *Reloader) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config watcher: %w", err)
+	}
+
+	// Watch directories rather than files so atomic saves (write and rename) are seen
+	files := make(map[string]bool)
+	for _, file := range r.loader.Files() {
+		if r.loader.fsys != nil {
+			continue // In-memory sources cannot change
+		}
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			watcher.Close()
+			return err
+		}
+		files[abs] = true
+		if err := watcher.Add(filepath.Dir(abs)); err != nil {
+			watcher.Close()
+			return fmt.Errorf("failed to watch %s: %w", file, err)
+		}
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		defer watcher.Close()
+		defer signal.Stop(hup)
+
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				r.log.Info("Received SIGHUP, reloading configuration")
+				r.Reload()
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				abs, _ := filepath.Abs(event.Name)
+				if files[abs] && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					debounce = time.After(reloadDebounce)
+				}
+			case <-debounce:
+				debounce = nil
+				r.log.Info("Configuration file changed, reloading")
+				r.Reload()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				r.log.Errorf("Config watcher error: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Diff returns the keys whose values differ between a and b, sorted by key.
+// Values of secret keys, and of keys in secrets, are reported as RedactedValue
+func Diff(a, b Config, secrets map[string]bool) []Change {
+	before, after := flatten(a), flatten(b)
+	var changes []Change
+	for key, value := range after {
+		if before[key] == value {
+			continue
+		}
+		change := Change{Key: key, Old: before[key], New: value}
+		if IsSecretKey(key) || secrets[key] {
+			change.Old, change.New = RedactedValue, RedactedValue
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// isReloadable reports whether key may change without a restart
+func isReloadable(key string) bool {
+	for _, safe := range reloadableKeys {
+		if key == safe || strings.HasPrefix(key, safe+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// flatten renders every leaf of cfg by its dotted mapstructure key
+func flatten(cfg Config) map[string]string {
+	out := make(map[string]string)
+	flattenValue("", reflect.ValueOf(cfg), out)
+	return out
+}
+
+func flattenValue(prefix string, v reflect.Value, out map[string]string) {
+	if v.Kind() != reflect.Struct {
+		out[prefix] = fmt.Sprintf("%v", v.Interface())
+		return
+	}
+	for i := 0; i < v.NumField(); i++ {
+		flattenValue(joinKey(prefix, v.Type().Field(i)), v.Field(i), out)
+	}
+}
+
+// sortedIDs returns subscription IDs in registration order
+func sortedIDs(subs map[int]ChangeFunc) []int {
+	ids := make([]int, 0, len(subs))
+	for id := range subs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestReloader loads content from a temporary directory and returns a Reloader for it
+func newTestReloader(t *testing.T, content string) (*Reloader, string) {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(bytes.NewBuffer(nil)) // Disable logging output
+
+	dir := t.TempDir()
+	writeConfig(t, dir, "config.yaml", content)
+	loader := NewLoader(WithSearchPaths(dir))
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	return NewReloader(loader, cfg, log), dir
+}
+
+// TestReload covers applying and rejecting configuration changes
+func TestReload(t *testing.T) {
+	reloader, dir := newTestReloader(t, "port: 8080\ndebug: false\n")
+
+	var calls int
+	var seen Config
+	unsubscribe := reloader.Subscribe(func(old, new Config) {
+		calls++
+		seen = new
+		assert.False(t, old.Debug)
+	})
+
+	// Test case 1: unchanged files do not notify subscribers
+	require.NoError(t, reloader.Reload())
+	assert.Equal(t, 0, calls)
+
+	// Test case 2: reloadable keys are applied and published
+	writeConfig(t, dir, "config.yaml", "port: 8080\ndebug: true\nbackup:\n  retain: 3\n"+
+		"cors:\n  routes:\n    - prefix: /api\n      allowed_origins: [https://app.example.com]\n")
+	require.NoError(t, reloader.Reload())
+	assert.Equal(t, 1, calls)
+	assert.True(t, seen.Debug)
+	assert.Equal(t, 3, reloader.Current().Backup.Retain)
+	require.Len(t, seen.CORS.Routes, 1)
+	assert.Equal(t, []string{"https://app.example.com"}, seen.CORS.Routes[0].AllowedOrigins)
+
+	// Test case 3: restart-only keys reject the whole reload
+	writeConfig(t, dir, "config.yaml", "port: 9090\ndebug: false\n")
+	assert.ErrorIs(t, reloader.Reload(), ErrRestartRequired)
+	assert.Equal(t, 8080, reloader.Current().Port)
+	assert.True(t, reloader.Current().Debug)
+
+	// Test case 4: invalid files are rejected
+	writeConfig(t, dir, "config.yaml", "port: 8080\ndebug: false\nbackup:\n  retain: -1\n")
+	assert.Error(t, reloader.Reload())
+	assert.Equal(t, 3, reloader.Current().Backup.Retain)
+
+	// Test case 5: unsubscribed functions are no longer called
+	unsubscribe()
+	writeConfig(t, dir, "config.yaml", "port: 8080\ndebug: false\n")
+	require.NoError(t, reloader.Reload())
+	assert.Equal(t, 1, calls)
+	assert.False(t, reloader.Current().Debug)
+
+	// Test case 6: subscribers may read the configuration and subscribe
+	var current Config
+	reloader.Subscribe(func(old, new Config) {
+		current = reloader.Current()
+		reloader.Subscribe(func(old, new Config) {})
+	})
+	writeConfig(t, dir, "config.yaml", "port: 8080\ndebug: true\n")
+	done := make(chan error, 1)
+	go func() { done <- reloader.Reload() }()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+		assert.True(t, current.Debug)
+	case <-time.After(5 * time.Second):
+		t.Fatal("reload deadlocked in a subscriber")
+	}
+}
+
+// TestReloaderWatch verifies file edits trigger a reload
+func TestReloaderWatch(t *testing.T) {
+	reloader, dir := newTestReloader(t, "debug: false\n")
+
+	var debug atomic.Bool
+	reloader.Subscribe(func(_, new Config) { debug.Store(new.Debug) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, reloader.Watch(ctx))
+
+	writeConfig(t, dir, "config.yaml", "debug: true\n")
+	assert.Eventually(t, debug.Load, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, filepath.Join(dir, "config.yaml"), reloader.loader.Files()[0])
+}
+
+// TestDiff checks that nested keys are reported by their dotted path
+func TestDiff(t *testing.T) {
+	var a, b Config
+	b.Database.Path = "other.db"
+	b.Replication.Retention = time.Hour
+
+	b.Events.NATS.Token = "s3cret"
+	b.Redis = "cache:6379"
+
+	changes := Diff(a, b, map[string]bool{"redis": true})
+	require.Len(t, changes, 4)
+	assert.Equal(t, "database.path", changes[0].Key)
+	assert.Equal(t, Change{Key: "events.nats.token", Old: RedactedValue, New: RedactedValue}, changes[1])
+	assert.Equal(t, RedactedValue, changes[2].New, "extra secret keys are redacted")
+	assert.Equal(t, "replication.retention", changes[3].Key)
+	assert.Equal(t, "1h0m0s", changes[3].New)
+}
@@ -0,0 +1,322 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// RedactedValue replaces secret values wherever configuration is logged or dumped
+const RedactedValue = "[REDACTED]"
+
+// SecretsKeyEnvVar holds the key for the encrypted secrets file
+const SecretsKeyEnvVar = EnvPrefix + "_SECRETS_KEY"
+
+// ErrSecretNotFound is returned by providers for unknown secret names
+var ErrSecretNotFound = errors.New("secret not found")
+
+// secretRef matches ${secret:name} references in string values
+var secretRef = regexp.MustCompile(`\$\{secret:([A-Za-z0-9_.-]+)\}`)
+
+// secretMagic prefixes sealed secrets files and authenticates the format version
+var secretMagic = []byte("GOPARKS1")
+
+// secretTagKeys holds the keys of fields tagged secret:"true"
+var secretTagKeys = collectSecretKeys(reflect.TypeOf(Config{}), "")
+
+// SecretProvider resolves named secrets referenced as ${secret:name}
+type SecretProvider interface {
+	Secret(name string) (string, error)
+}
+
+// EnvSecretProvider reads secrets from environment variables named by
+// Prefix and the upper-cased secret name, e.g. GOPARK_SECRET_NATS_TOKEN
+type EnvSecretProvider struct {
+	Prefix string
+}
+
+// Secret implements SecretProvider
+func (p EnvSecretProvider) Secret(name string) (string, error) {
+	key := p.Prefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(name))
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return "", fmt.Errorf("%w: %s (environment variable %s)", ErrSecretNotFound, name, key)
+	}
+	return value, nil
+}
+
+// FileSecretProvider reads each secret from a file named after it in Dir,
+// the layout used by Docker and Kubernetes secret mounts
+type FileSecretProvider struct {
+	Dir string
+}
+
+// Secret implements SecretProvider
+func (p FileSecretProvider) Secret(name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(p.Dir, name))
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("%w: %s (in %s)", ErrSecretNotFound, name, p.Dir)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// EncryptedFileSecretProvider serves secrets from a file sealed with SealSecrets
+type EncryptedFileSecretProvider struct {
+	secrets map[string]string
+}
+
+// NewEncryptedFileSecretProvider decrypts the sealed secrets file at path with key
+func NewEncryptedFileSecretProvider(path string, key []byte) (*EncryptedFileSecretProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+	secrets, err := OpenSecrets(data, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open secrets file %s: %w", path, err)
+	}
+	return &EncryptedFileSecretProvider{secrets: secrets}, nil
+}
+
+// Secret implements SecretProvider
+func (p *EncryptedFileSecretProvider) Secret(name string) (string, error) {
+	value, ok := p.secrets[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	}
+	return value, nil
+}
+
+// NewSecretProvider creates the provider selected by cfg
+func NewSecretProvider(cfg SecretsConfig) (SecretProvider, error) {
+	switch cfg.Provider {
+	case "", "env":
+		return EnvSecretProvider{Prefix: cfg.EnvPrefix}, nil
+	case "file":
+		return FileSecretProvider{Dir: cfg.Dir}, nil
+	case "encrypted":
+		key, err := LoadSecretKey(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return NewEncryptedFileSecretProvider(cfg.File, key)
+	default:
+		return nil, fmt.Errorf("unknown secrets provider %q (expected env, file or encrypted)", cfg.Provider)
+	}
+}
+
+// LoadSecretKey reads the encrypted provider key from SecretsKeyEnvVar or,
+// when unset, from cfg.KeyFile
+func LoadSecretKey(cfg SecretsConfig) ([]byte, error) {
+	encoded, ok := os.LookupEnv(SecretsKeyEnvVar)
+	if !ok && cfg.KeyFile != "" {
+		data, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secrets key: %w", err)
+		}
+		encoded = string(data)
+	}
+	if encoded == "" {
+		return nil, fmt.Errorf("encrypted secrets need %s or secrets.key_file", SecretsKeyEnvVar)
+	}
+	return ParseSecretKey(encoded)
+}
+
+// ParseSecretKey decodes a 32-byte AES-256 key given as hex or base64
+func ParseSecretKey(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	key, err := hex.DecodeString(encoded)
+	if err != nil {
+		key, err = base64.StdEncoding.DecodeString(encoded)
+	}
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("secrets key must be 32 bytes encoded as hex or base64")
+	}
+	return key, nil
+}
+
+// SealSecrets encrypts secrets with AES-256-GCM for use with the encrypted provider
+func SealSecrets(secrets map[string]string, key []byte) ([]byte, error) {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := append(append([]byte{}, secretMagic...), nonce...)
+	return aead.Seal(sealed, nonce, plaintext, secretMagic), nil
+}
+
+// OpenSecrets decrypts data produced by SealSecrets
+func OpenSecrets(data, key []byte) (map[string]string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	header := len(secretMagic) + aead.NonceSize()
+	if len(data) < header || string(data[:len(secretMagic)]) != string(secretMagic) {
+		return nil, errors.New("not a sealed secrets file")
+	}
+	plaintext, err := aead.Open(nil, data[len(secretMagic):header], data[header:], secretMagic)
+	if err != nil {
+		return nil, errors.New("wrong key or corrupted secrets file")
+	}
+	var secrets map[string]string
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// resolveSecrets applies <VAR>_FILE indirection and ${secret:name} references
+// to every key not overridden by a flag, returning the keys that hold secrets
+// and the keys whose value was read through a _FILE variable
+func (l *Loader) resolveSecrets(v *viper.Viper, flagKeys map[string]bool) (secrets, fileKeys map[string]bool, err error) {
+	secrets = make(map[string]bool)
+	fileKeys = make(map[string]bool)
+
+	for _, key := range v.AllKeys() {
+		if flagKeys[key] {
+			continue
+		}
+		fileVar := l.envVar(key) + "_FILE"
+		path, ok := os.LookupEnv(fileVar)
+		if !ok {
+			continue
+		}
+		if _, ok := os.LookupEnv(l.envVar(key)); ok {
+			return nil, nil, fmt.Errorf("both %s and %s are set", l.envVar(key), fileVar)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", fileVar, err)
+		}
+		v.Set(key, strings.TrimRight(string(data), "\r\n"))
+		secrets[key] = true
+		fileKeys[key] = true
+	}
+
+	var provider SecretProvider
+	for _, key := range v.AllKeys() {
+		value, ok := v.Get(key).(string)
+		if !ok || !strings.Contains(value, "${secret:") || strings.HasPrefix(key, "secrets.") {
+			continue
+		}
+		if provider == nil {
+			// Read leaves individually: UnmarshalKey would only see the
+			// highest layer that defines any secrets.* key
+			cfg := SecretsConfig{
+				Provider:  v.GetString("secrets.provider"),
+				EnvPrefix: v.GetString("secrets.env_prefix"),
+				Dir:       v.GetString("secrets.dir"),
+				File:      v.GetString("secrets.file"),
+				KeyFile:   v.GetString("secrets.key_file"),
+			}
+			if provider, err = NewSecretProvider(cfg); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		var resolveErr error
+		resolved := secretRef.ReplaceAllStringFunc(value, func(ref string) string {
+			secret, err := provider.Secret(secretRef.FindStringSubmatch(ref)[1])
+			if err != nil && resolveErr == nil {
+				resolveErr = err
+			}
+			return secret
+		})
+		if resolveErr != nil {
+			return nil, nil, fmt.Errorf("%s: %w", key, resolveErr)
+		}
+		v.Set(key, resolved)
+		secrets[key] = true
+	}
+	return secrets, fileKeys, nil
+}
+
+// IsSecretKey reports whether key is a field tagged secret:"true"
+func IsSecretKey(key string) bool {
+	return secretTagKeys[key]
+}
+
+// Redact returns a copy of cfg with non-empty secret values replaced by
+// RedactedValue. Fields tagged secret:"true" are always redacted; extra names
+// further keys, such as Loader.SecretKeys
+func Redact(cfg Config, extra map[string]bool) Config {
+	redactValue("", reflect.ValueOf(&cfg).Elem(), extra)
+	return cfg
+}
+
+func redactValue(prefix string, v reflect.Value, extra map[string]bool) {
+	if v.Kind() == reflect.String {
+		if v.Len() > 0 && (secretTagKeys[prefix] || extra[prefix]) {
+			v.SetString(RedactedValue)
+		}
+		return
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < v.NumField(); i++ {
+		redactValue(joinKey(prefix, v.Type().Field(i)), v.Field(i), extra)
+	}
+}
+
+// collectSecretKeys walks t and returns the keys of fields tagged secret:"true"
+func collectSecretKeys(t reflect.Type, prefix string) map[string]bool {
+	keys := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := joinKey(prefix, field)
+		if field.Tag.Get("secret") == "true" {
+			keys[key] = true
+		}
+		if field.Type.Kind() == reflect.Struct {
+			for nested := range collectSecretKeys(field.Type, key) {
+				keys[nested] = true
+			}
+		}
+	}
+	return keys
+}
+
+// joinKey returns the dotted key of field below prefix
+func joinKey(prefix string, field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
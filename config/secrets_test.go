@@ -0,0 +1,103 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKey is a fixed AES-256 key for sealing test secrets
+var testKey = bytes.Repeat([]byte{7}, 32)
+
+// TestSecretFileIndirection verifies <VAR>_FILE reads the value from a file
+func TestSecretFileIndirection(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "config.yaml", "port: 8080\n")
+	writeConfig(t, dir, "nats_token", "from-file\n")
+	t.Setenv("GOPARK_EVENTS_NATS_TOKEN_FILE", filepath.Join(dir, "nats_token"))
+
+	// Test case 1: the trailing newline is trimmed
+	loader := NewLoader(WithSearchPaths(dir))
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "from-file", cfg.Events.NATS.Token)
+	assert.True(t, loader.SecretKeys()["events.nats.token"])
+
+	// Test case 2: setting both the variable and its _FILE form is ambiguous
+	t.Setenv("GOPARK_EVENTS_NATS_TOKEN", "from-env")
+	_, err = NewLoader(WithSearchPaths(dir)).Load()
+	assert.ErrorContains(t, err, "GOPARK_EVENTS_NATS_TOKEN_FILE")
+}
+
+// TestSecretReferences resolves ${secret:name} through each provider
+func TestSecretReferences(t *testing.T) {
+	dir := t.TempDir()
+
+	// Test case 1: the env provider reads prefixed variables
+	writeConfig(t, dir, "config.yaml", "redis: ${secret:redis-host}:6379\nevents:\n  nats:\n    token: ${secret:nats.token}\n")
+	t.Setenv("GOPARK_SECRET_REDIS_HOST", "cache")
+	t.Setenv("GOPARK_SECRET_NATS_TOKEN", "env-token")
+	loader := NewLoader(WithSearchPaths(dir))
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "cache:6379", cfg.Redis)
+	assert.Equal(t, "env-token", cfg.Events.NATS.Token)
+	assert.True(t, loader.SecretKeys()["redis"])
+
+	// Test case 2: the file provider reads one file per secret
+	secretsDir := t.TempDir()
+	writeConfig(t, secretsDir, "nats.token", "file-token\n")
+	writeConfig(t, dir, "config.yaml", "secrets:\n  provider: file\n  dir: "+secretsDir+"\nevents:\n  nats:\n    token: ${secret:nats.token}\n")
+	cfg, err = NewLoader(WithSearchPaths(dir)).Load()
+	require.NoError(t, err)
+	assert.Equal(t, "file-token", cfg.Events.NATS.Token)
+
+	// Test case 3: the encrypted provider decrypts a sealed file
+	sealed, err := SealSecrets(map[string]string{"nats.token": "sealed-token"}, testKey)
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(sealed, []byte("sealed-token")))
+	sealedPath := filepath.Join(dir, "secrets.enc")
+	require.NoError(t, os.WriteFile(sealedPath, sealed, 0o600))
+	writeConfig(t, dir, "config.yaml", "secrets:\n  provider: encrypted\n  file: "+sealedPath+"\nevents:\n  nats:\n    token: ${secret:nats.token}\n")
+	t.Setenv(SecretsKeyEnvVar, strings.Repeat("07", 32))
+	cfg, err = NewLoader(WithSearchPaths(dir)).Load()
+	require.NoError(t, err)
+	assert.Equal(t, "sealed-token", cfg.Events.NATS.Token)
+
+	// Test case 4: a wrong key or an unknown name is an error
+	t.Setenv(SecretsKeyEnvVar, strings.Repeat("08", 32))
+	_, err = NewLoader(WithSearchPaths(dir)).Load()
+	assert.ErrorContains(t, err, "wrong key")
+
+	writeConfig(t, dir, "config.yaml", "events:\n  nats:\n    token: ${secret:missing}\n")
+	_, err = NewLoader(WithSearchPaths(dir)).Load()
+	assert.ErrorIs(t, err, ErrSecretNotFound)
+}
+
+// TestRedact verifies secrets never appear in dumps or validation errors
+func TestRedact(t *testing.T) {
+	var cfg Config
+	cfg.Events.NATS.Token = "s3cret"
+	cfg.Redis = "cache:6379"
+
+	// Test case 1: tagged fields and extra keys are redacted, others are kept
+	redacted := Redact(cfg, map[string]bool{"redis": true})
+	assert.Equal(t, RedactedValue, redacted.Events.NATS.Token)
+	assert.Equal(t, RedactedValue, redacted.Redis)
+	assert.Equal(t, "s3cret", cfg.Events.NATS.Token, "the original is not modified")
+	assert.Equal(t, "cache:6379", Redact(cfg, nil).Redis)
+
+	// Test case 2: validation errors redact secret values
+	dir := t.TempDir()
+	writeConfig(t, dir, "config.yaml", "redis: ${secret:redis}\n")
+	t.Setenv("GOPARK_SECRET_REDIS", "password@not-an-address")
+	_, err := NewLoader(WithSearchPaths(dir)).Load()
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "password")
+	assert.Contains(t, err.Error(), RedactedValue)
+}
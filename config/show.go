@@ -0,0 +1,116 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats supported by Show
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Show writes cfg, as loaded by l, in the given format with every value
+// annotated with its source and secrets redacted. YAML output carries the
+// source as a trailing comment; JSON output wraps each value as
+// {"value": ..., "source": ...}
+func (l *Loader) Show(w io.Writer, cfg Config, format string) error {
+	value := reflect.ValueOf(Redact(cfg, l.SecretKeys()))
+	switch format {
+	case FormatYAML:
+		node, err := l.yamlNode("", value)
+		if err != nil {
+			return err
+		}
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(node); err != nil {
+			return err
+		}
+		return enc.Close()
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(l.jsonValue("", value))
+	default:
+		return fmt.Errorf("unknown format %q (expected yaml or json)", format)
+	}
+}
+
+// yamlNode renders v as a YAML node, annotating leaves with their source
+func (l *Loader) yamlNode(key string, v reflect.Value) (*yaml.Node, error) {
+	if v.Kind() == reflect.Struct && v.Type() != durationType {
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for i := 0; i < v.NumField(); i++ {
+			child := joinKey(key, v.Type().Field(i))
+			value, err := l.yamlNode(child, v.Field(i))
+			if err != nil {
+				return nil, err
+			}
+			name := &yaml.Node{Kind: yaml.ScalarNode, Value: lastKey(child)}
+			if value.Kind != yaml.ScalarNode {
+				// Comments on block collections belong on the key line
+				name.LineComment, value.LineComment = value.LineComment, ""
+			}
+			node.Content = append(node.Content, name, value)
+		}
+		return node, nil
+	}
+
+	node := &yaml.Node{}
+	if err := node.Encode(plainValue(v)); err != nil {
+		return nil, err
+	}
+	node.LineComment = l.Source(key)
+	return node, nil
+}
+
+// jsonValue renders v for JSON, wrapping leaves with their source
+func (l *Loader) jsonValue(key string, v reflect.Value) any {
+	if v.Kind() == reflect.Struct && v.Type() != durationType {
+		out := make(map[string]any, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			child := joinKey(key, v.Type().Field(i))
+			out[lastKey(child)] = l.jsonValue(child, v.Field(i))
+		}
+		return out
+	}
+	return map[string]any{"value": plainValue(v), "source": l.Source(key)}
+}
+
+// plainValue converts v to values that encode like the config file: durations
+// as strings such as 1m30s and structs keyed by their mapstructure names
+func plainValue(v reflect.Value) any {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Slice:
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = plainValue(v.Index(i))
+		}
+		return out
+	case v.Kind() == reflect.Struct:
+		out := make(map[string]any, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			out[lastKey(joinKey("", v.Type().Field(i)))] = plainValue(v.Field(i))
+		}
+		return out
+	default:
+		return v.Interface()
+	}
+}
+
+// lastKey returns the final segment of a dotted key
+func lastKey(key string) string {
+	return key[strings.LastIndex(key, ".")+1:]
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"testing/fstest"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestShow checks provenance annotations and redaction in both formats
+func TestShow(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config.yaml":     {Data: []byte("appname: shown\nport: 8080\nevents:\n  nats:\n    token: plain-token\n")},
+		"config.dev.yaml": {Data: []byte("port: 9000\n")},
+	}
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	RegisterFlags(flags)
+	require.NoError(t, flags.Parse([]string{"--env", "dev", "--set", "jobs.concurrency=3"}))
+
+	loader := NewLoader(WithFS(fsys), WithFlags(flags))
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+
+	// Test case 1: YAML annotates each value with its source
+	var out bytes.Buffer
+	require.NoError(t, loader.Show(&out, cfg, FormatYAML))
+	assert.Contains(t, out.String(), "appname: shown # file\n")
+	assert.Contains(t, out.String(), "port: 9000 # profile\n")
+	assert.Contains(t, out.String(), "  concurrency: 3 # flag\n")
+	assert.Contains(t, out.String(), "  type: sqlite # default\n")
+	assert.Contains(t, out.String(), "  timeout: 5m0s # default\n")
+	assert.NotContains(t, out.String(), "plain-token")
+
+	// Test case 2: JSON wraps values with their source
+	out.Reset()
+	require.NoError(t, loader.Show(&out, cfg, FormatJSON))
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &doc))
+	assert.Equal(t, map[string]any{"value": 9000.0, "source": SourceProfile}, doc["port"])
+	nats := doc["events"].(map[string]any)["nats"].(map[string]any)
+	assert.Equal(t, map[string]any{"value": RedactedValue, "source": SourceFile}, nats["token"])
+
+	// Test case 3: unknown formats are rejected
+	assert.Error(t, loader.Show(&out, cfg, "toml"))
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// TLSVersion parses a tls.min_version value; empty means TLS 1.2
+func TLSVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version %q (expected 1.2 or 1.3)", version)
+	}
+}
+
+// CipherSuiteIDs resolves tls.cipher_suites names. Only suites Go considers
+// secure are accepted; TLS 1.3 suites are not configurable and are rejected
+func CipherSuiteIDs(names []string) ([]uint16, error) {
+	known := make(map[string]*tls.CipherSuite)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite
+	}
+
+	var ids []uint16
+	var unknown []string
+	for _, name := range names {
+		suite, ok := known[name]
+		if !ok || !supportsTLS12(suite) {
+			unknown = append(unknown, name)
+			continue
+		}
+		ids = append(ids, suite.ID)
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unsupported or insecure TLS 1.2 cipher suites: %s", strings.Join(unknown, ", "))
+	}
+	return ids, nil
+}
+
+// supportsTLS12 reports whether suite can be negotiated in TLS 1.2
+func supportsTLS12(suite *tls.CipherSuite) bool {
+	for _, v := range suite.SupportedVersions {
+		if v == tls.VersionTLS12 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,649 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Configuration value sources reported by validation errors
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceProfile = "profile"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// FieldError describes one invalid configuration value
+type FieldError struct {
+	Key     string // Dotted key path, e.g. database.path
+	Source  string // Where the value came from: default, file, profile, env or flag
+	Value   any
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s (value %v from %s)", e.Key, e.Message, e.Value, e.Source)
+}
+
+// ValidationError aggregates every problem found in a configuration
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Errors)+1)
+	lines = append(lines, fmt.Sprintf("invalid configuration (%d problems):", len(e.Errors)))
+	for _, fe := range e.Errors {
+		lines = append(lines, "  "+fe.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SourceFunc reports where the value of a configuration key came from
+type SourceFunc func(key string) string
+
+// validator collects field errors for a single validation pass
+type validator struct {
+	source SourceFunc
+	secret func(key string) bool
+	errs   []FieldError
+}
+
+func (v *validator) fail(key string, value any, format string, args ...any) {
+	source := SourceDefault
+	if v.source != nil {
+		// List entries such as scheduler.tasks[0].name come from the list key
+		base, _, _ := strings.Cut(key, "[")
+		source = v.source(base)
+	}
+	message := fmt.Sprintf(format, args...)
+	if v.secret(key) {
+		// Messages may quote the value, e.g. through a wrapped parse error
+		if s := fmt.Sprint(value); s != "" {
+			message = strings.ReplaceAll(message, s, RedactedValue)
+		}
+		value = RedactedValue
+	}
+	v.errs = append(v.errs, FieldError{Key: key, Source: source, Value: value, Message: message})
+}
+
+func (v *validator) positive(key string, d time.Duration) {
+	if d <= 0 {
+		v.fail(key, d, "must be greater than zero")
+	}
+}
+
+func (v *validator) nonNegative(key string, d time.Duration) {
+	if d < 0 {
+		v.fail(key, d, "must not be negative")
+	}
+}
+
+// Validate checks ranges, enums, addresses, file permissions and cross-field
+// constraints, returning a *ValidationError listing every problem. source may
+// be nil, in which case all values are attributed to defaults
+func Validate(cfg Config, source SourceFunc) error {
+	return validate(cfg, source, IsSecretKey)
+}
+
+// validate implements Validate, redacting the values of keys matched by secret
+func validate(cfg Config, source SourceFunc, secret func(key string) bool) error {
+	v := &validator{source: source, secret: secret}
+
+	if strings.TrimSpace(cfg.AppName) == "" {
+		v.fail("appname", cfg.AppName, "must not be empty")
+	}
+	if cfg.Port < 1 || cfg.Port > 65535 {
+		v.fail("port", cfg.Port, "must be between 1 and 65535")
+	}
+	if cfg.Redis != "" {
+		if err := checkHostPort(cfg.Redis); err != nil {
+			v.fail("redis", cfg.Redis, "%v", err)
+		}
+	}
+
+	validateLog(v, cfg.Log)
+	for i, network := range cfg.RequestID.TrustedNetworks {
+		if _, err := ParseNetwork(network); err != nil {
+			v.fail(fmt.Sprintf("request_id.trusted_networks[%d]", i), network, "must be an IP address or CIDR")
+		}
+	}
+	validateCORS(v, cfg.CORS)
+	validateListeners(v, cfg)
+	v.positive("shutdown.drain_timeout", cfg.Shutdown.DrainTimeout)
+	v.positive("shutdown.ready_timeout", cfg.Shutdown.ReadyTimeout)
+	v.nonNegative("shutdown.readiness_delay", cfg.Shutdown.ReadinessDelay)
+	if cfg.Shutdown.ReadinessDelay > 0 && cfg.Shutdown.ReadinessDelay >= cfg.Shutdown.DrainTimeout {
+		v.fail("shutdown.readiness_delay", cfg.Shutdown.ReadinessDelay, "must be less than shutdown.drain_timeout (%s), which it counts against", cfg.Shutdown.DrainTimeout)
+	}
+	validateHealth(v, cfg.Health)
+	validateTracing(v, cfg.Tracing)
+	validateTLS(v, cfg)
+	validateDatabase(v, cfg)
+	validateEvents(v, cfg.Events)
+	validateJobs(v, cfg.Jobs)
+	validateScheduler(v, cfg.Scheduler)
+	validateBackup(v, cfg)
+	validateReplication(v, cfg)
+	validateSecrets(v, cfg.Secrets)
+
+	if len(v.errs) > 0 {
+		return &ValidationError{Errors: v.errs}
+	}
+	return nil
+}
+
+func validateLog(v *validator, cfg LogConfig) {
+	if cfg.Format != "text" && cfg.Format != "json" {
+		v.fail("log.format", cfg.Format, "unsupported format (expected text or json)")
+	}
+	sc := cfg.Sampling
+	if !sc.Enabled {
+		return
+	}
+	if sc.Initial < 0 {
+		v.fail("log.sampling.initial", sc.Initial, "must not be negative")
+	}
+	if sc.Thereafter < 0 {
+		v.fail("log.sampling.thereafter", sc.Thereafter, "must not be negative")
+	}
+	v.positive("log.sampling.tick", sc.Tick)
+}
+
+func validateCORS(v *validator, cfg CORSConfig) {
+	validateCORSPolicy(v, "cors.default", cfg.Default)
+	prefixes := make(map[string]bool)
+	for i, route := range cfg.Routes {
+		key := fmt.Sprintf("cors.routes[%d]", i)
+		if !strings.HasPrefix(route.Prefix, "/") {
+			v.fail(key+".prefix", route.Prefix, "must be a path starting with /")
+		} else if prefixes[route.Prefix] {
+			v.fail(key+".prefix", route.Prefix, "duplicate route prefix")
+		}
+		prefixes[route.Prefix] = true
+		validateCORSPolicy(v, key, route.CORSPolicy)
+	}
+}
+
+// validateCORSPolicy checks the policy whose keys are below prefix
+func validateCORSPolicy(v *validator, prefix string, cfg CORSPolicy) {
+	anyOrigin := false
+	for i, origin := range cfg.AllowedOrigins {
+		anyOrigin = anyOrigin || origin == "*"
+		if _, err := ParseOrigin(origin); err != nil {
+			v.fail(fmt.Sprintf("%s.allowed_origins[%d]", prefix, i), origin, "%v", err)
+		}
+	}
+	for i, method := range cfg.AllowedMethods {
+		if !isToken(method) || strings.ToUpper(method) != method {
+			v.fail(fmt.Sprintf("%s.allowed_methods[%d]", prefix, i), method, "must be an upper-case HTTP method such as GET")
+		}
+	}
+	for i, header := range cfg.AllowedHeaders {
+		if header == "*" && cfg.AllowCredentials {
+			v.fail(fmt.Sprintf("%s.allowed_headers[%d]", prefix, i), header, "browsers do not accept * with allow_credentials; list the headers")
+		} else if header != "*" && !isToken(header) {
+			v.fail(fmt.Sprintf("%s.allowed_headers[%d]", prefix, i), header, "must be a header name")
+		}
+	}
+	for i, header := range cfg.ExposedHeaders {
+		if !isToken(header) {
+			v.fail(fmt.Sprintf("%s.exposed_headers[%d]", prefix, i), header, "must be a header name")
+		}
+	}
+	if anyOrigin && cfg.AllowCredentials {
+		v.fail(prefix+".allow_credentials", cfg.AllowCredentials, "browsers do not accept credentials with a * origin; list the origins")
+	}
+	v.nonNegative(prefix+".max_age", cfg.MaxAge)
+}
+
+func validateListeners(v *validator, cfg Config) {
+	public, admin := cfg.Listeners.Public, cfg.Listeners.Admin
+	if public.Address != "" {
+		validateListenAddress(v, "listeners.public.address", public.Address)
+	}
+	if admin.Address != "" {
+		validateListenAddress(v, "listeners.admin.address", admin.Address)
+		publicAddress := public.Address
+		if publicAddress == "" {
+			publicAddress = fmt.Sprintf(":%d", cfg.Port)
+		}
+		if sameListenAddress(admin.Address, publicAddress) {
+			v.fail("listeners.admin.address", admin.Address, "must not use the address of the public listener (%s)", publicAddress)
+		}
+	}
+	for name, lc := range map[string]ListenerConfig{"public": public, "admin": admin} {
+		v.nonNegative("listeners."+name+".read_timeout", lc.ReadTimeout)
+		v.nonNegative("listeners."+name+".write_timeout", lc.WriteTimeout)
+		v.nonNegative("listeners."+name+".idle_timeout", lc.IdleTimeout)
+	}
+}
+
+// sameListenAddress reports whether listening on a and b would conflict.
+// TCP addresses conflict on the same port when their hosts match, or when
+// either host is a wildcard such as "" or 0.0.0.0, which binds every address
+func sameListenAddress(a, b string) bool {
+	hostA, portA, errA := net.SplitHostPort(a)
+	hostB, portB, errB := net.SplitHostPort(b)
+	if errA != nil || errB != nil || strings.HasPrefix(a, "unix:") || strings.HasPrefix(a, "systemd:") {
+		return a == b
+	}
+	if portA != portB || portA == "0" {
+		return false // Port 0 picks a free port
+	}
+	if isWildcardHost(hostA) || isWildcardHost(hostB) {
+		return true
+	}
+	ipsA, ipsB := hostIPs(hostA), hostIPs(hostB)
+	if ipsA == nil || ipsB == nil {
+		return strings.EqualFold(hostA, hostB)
+	}
+	for _, ipA := range ipsA {
+		for _, ipB := range ipsB {
+			if ipA.Equal(ipB) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isWildcardHost reports whether a listener on host accepts connections on
+// every local address
+func isWildcardHost(host string) bool {
+	ip := net.ParseIP(host)
+	return host == "" || (ip != nil && ip.IsUnspecified())
+}
+
+// hostIPs returns the addresses host stands for without a DNS lookup, or nil
+// for other host names
+func hostIPs(host string) []net.IP {
+	if strings.EqualFold(host, "localhost") {
+		return []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}
+	}
+	return nil
+}
+
+// validateListenAddress checks a listener address in any supported form
+func validateListenAddress(v *validator, key, address string) {
+	switch {
+	case strings.HasPrefix(address, "unix:"):
+		path := strings.TrimPrefix(address, "unix:")
+		if path == "" {
+			v.fail(key, address, "missing socket path")
+			return
+		}
+		checkDir(v, key, address, filepath.Dir(path))
+	case strings.HasPrefix(address, "systemd:"):
+		if strings.TrimPrefix(address, "systemd:") == "" {
+			v.fail(key, address, "missing socket name or index")
+		}
+	default:
+		_, port, err := net.SplitHostPort(address)
+		if err != nil {
+			v.fail(key, address, "must be host:port, unix:/path or systemd:name")
+			return
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+			v.fail(key, address, "invalid port %q", port)
+		}
+	}
+}
+
+func validateHealth(v *validator, cfg HealthConfig) {
+	v.nonNegative("health.cache_ttl", cfg.CacheTTL)
+	v.nonNegative("health.timeout", cfg.Timeout)
+	if cfg.MinFreeDiskMB < 0 {
+		v.fail("health.min_free_disk_mb", cfg.MinFreeDiskMB, "must not be negative")
+	}
+	if cfg.MaxJobBacklog < 0 {
+		v.fail("health.max_job_backlog", cfg.MaxJobBacklog, "must not be negative")
+	}
+	v.nonNegative("health.stall_after", cfg.StallAfter)
+	if cfg.StallAfter > 0 && cfg.StallAfter <= cfg.Timeout {
+		v.fail("health.stall_after", cfg.StallAfter, "must be greater than health.timeout (%s) or one slow check fails liveness", cfg.Timeout)
+	}
+}
+
+func validateTracing(v *validator, cfg TracingConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	switch cfg.Exporter {
+	case "otlp":
+		if u, err := url.Parse(cfg.Endpoint); err != nil || u.Host == "" {
+			v.fail("tracing.endpoint", cfg.Endpoint, "must be a URL such as http://localhost:4318")
+		} else if u.Scheme != "http" && u.Scheme != "https" {
+			v.fail("tracing.endpoint", cfg.Endpoint, "unsupported scheme %q (expected http or https)", u.Scheme)
+		}
+	case "stdout":
+	default:
+		v.fail("tracing.exporter", cfg.Exporter, "unsupported exporter (expected otlp or stdout)")
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		v.fail("tracing.sample_ratio", cfg.SampleRatio, "must be between 0 and 1")
+	}
+}
+
+func validateTLS(v *validator, cfg Config) {
+	tc := cfg.TLS
+	if cfg.HTTP2.H2C && tc.Enabled {
+		v.fail("http2.h2c", cfg.HTTP2.H2C, "applies only to plain HTTP; HTTP/2 over TLS is controlled by http2.enabled")
+	}
+	if !tc.Enabled {
+		if tc.ClientAuth != "" && tc.ClientAuth != "none" {
+			v.fail("tls.client_auth", tc.ClientAuth, "requires tls.enabled")
+		}
+		return
+	}
+
+	switch {
+	case tc.SelfSigned && (tc.CertFile != "" || tc.KeyFile != ""):
+		v.fail("tls.self_signed", tc.SelfSigned, "cannot be combined with tls.cert_file and tls.key_file")
+	case !tc.SelfSigned:
+		checkFile(v, "tls.cert_file", tc.CertFile)
+		checkFile(v, "tls.key_file", tc.KeyFile)
+	}
+
+	if _, err := TLSVersion(tc.MinVersion); err != nil {
+		v.fail("tls.min_version", tc.MinVersion, "%v", err)
+	}
+	if _, err := CipherSuiteIDs(tc.CipherSuites); err != nil {
+		v.fail("tls.cipher_suites", tc.CipherSuites, "%v", err)
+	}
+
+	switch tc.ClientAuth {
+	case "", "none":
+	case "request", "require":
+		checkFile(v, "tls.client_ca_file", tc.ClientCAFile)
+	default:
+		v.fail("tls.client_auth", tc.ClientAuth, "unknown mode (expected none, request or require)")
+	}
+	switch tc.IdentityFrom {
+	case "", "cn", "dns", "email", "uri":
+	default:
+		v.fail("tls.identity_from", tc.IdentityFrom, "unknown certificate field (expected cn, dns, email or uri)")
+	}
+}
+
+func validateDatabase(v *validator, cfg Config) {
+	if cfg.Database.Type != "sqlite" {
+		v.fail("database.type", cfg.Database.Type, "unsupported database type (expected sqlite)")
+	}
+	if cfg.Database.Path == "" {
+		v.fail("database.path", cfg.Database.Path, "must not be empty")
+		return
+	}
+	info, err := os.Stat(cfg.Database.Path)
+	switch {
+	case err == nil && info.IsDir():
+		v.fail("database.path", cfg.Database.Path, "is a directory")
+	case err == nil && info.Mode().Perm()&0o200 == 0:
+		v.fail("database.path", cfg.Database.Path, "is not writable (mode %s)", info.Mode().Perm())
+	case os.IsNotExist(err):
+		checkDir(v, "database.path", cfg.Database.Path, filepath.Dir(cfg.Database.Path))
+	case err != nil:
+		v.fail("database.path", cfg.Database.Path, "%v", err)
+	}
+}
+
+func validateEvents(v *validator, cfg EventsConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	if cfg.Driver != "nats" {
+		v.fail("events.driver", cfg.Driver, "unsupported events driver (expected nats)")
+		return
+	}
+	nc := cfg.NATS
+	if u, err := url.Parse(nc.URL); err != nil || u.Host == "" {
+		v.fail("events.nats.url", nc.URL, "must be a URL such as nats://host:4222")
+	} else if u.Scheme != "nats" && u.Scheme != "tls" {
+		v.fail("events.nats.url", nc.URL, "unsupported scheme %q (expected nats or tls)", u.Scheme)
+	}
+	if nc.CreateStream && nc.Stream == "" {
+		v.fail("events.nats.stream", nc.Stream, "must be set when create_stream is enabled")
+	}
+	if nc.CreateStream && len(nc.StreamSubjects) == 0 {
+		v.fail("events.nats.stream_subjects", nc.StreamSubjects, "must list at least one subject when create_stream is enabled")
+	}
+	if _, err := template.New("subject").Parse(nc.SubjectTemplate); err != nil || nc.SubjectTemplate == "" {
+		v.fail("events.nats.subject_template", nc.SubjectTemplate, "must be a valid template")
+	}
+	for i, route := range nc.Subjects {
+		key := fmt.Sprintf("events.nats.subjects[%d]", i)
+		if route.Type == "" {
+			v.fail(key+".type", route.Type, "must not be empty")
+		}
+		if _, err := template.New("subject").Parse(route.Template); err != nil || route.Template == "" {
+			v.fail(key+".template", route.Template, "must be a valid template")
+		}
+	}
+	if nc.MaxRetries < 0 {
+		v.fail("events.nats.max_retries", nc.MaxRetries, "must not be negative")
+	}
+	v.positive("events.nats.retry_backoff", nc.RetryBackoff)
+	v.positive("events.nats.ack_timeout", nc.AckTimeout)
+}
+
+func validateJobs(v *validator, cfg JobsConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	if cfg.Concurrency < 1 {
+		v.fail("jobs.concurrency", cfg.Concurrency, "must be at least 1")
+	}
+	if cfg.MaxAttempts < 1 {
+		v.fail("jobs.max_attempts", cfg.MaxAttempts, "must be at least 1")
+	}
+	v.positive("jobs.poll_interval", cfg.PollInterval)
+	v.positive("jobs.timeout", cfg.Timeout)
+	v.positive("jobs.backoff", cfg.Backoff)
+	v.nonNegative("jobs.retention", cfg.Retention)
+	if cfg.MaxBackoff < cfg.Backoff {
+		v.fail("jobs.max_backoff", cfg.MaxBackoff, "must not be less than jobs.backoff (%s)", cfg.Backoff)
+	}
+	if cfg.StaleAfter <= cfg.Timeout {
+		v.fail("jobs.stale_after", cfg.StaleAfter, "must be greater than jobs.timeout (%s) or running jobs are requeued", cfg.Timeout)
+	}
+}
+
+func validateScheduler(v *validator, cfg SchedulerConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	v.positive("scheduler.lease_ttl", cfg.LeaseTTL)
+	v.nonNegative("scheduler.jitter", cfg.Jitter)
+	if cfg.Jitter > 0 && cfg.Jitter >= cfg.LeaseTTL {
+		v.fail("scheduler.jitter", cfg.Jitter, "must be less than scheduler.lease_ttl (%s)", cfg.LeaseTTL)
+	}
+
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	seen := make(map[string]bool)
+	for i, task := range cfg.Tasks {
+		key := fmt.Sprintf("scheduler.tasks[%d]", i)
+		switch {
+		case task.Name == "":
+			v.fail(key+".name", task.Name, "must not be empty")
+		case seen[task.Name]:
+			v.fail(key+".name", task.Name, "duplicate task name")
+		}
+		seen[task.Name] = true
+		if _, err := parser.Parse(task.Schedule); err != nil {
+			v.fail(key+".schedule", task.Schedule, "invalid cron expression: %v", err)
+		}
+	}
+}
+
+func validateBackup(v *validator, cfg Config) {
+	if cfg.Backup.Dir == "" {
+		v.fail("backup.dir", cfg.Backup.Dir, "must not be empty")
+	}
+	if cfg.Backup.Retain < 0 {
+		v.fail("backup.retain", cfg.Backup.Retain, "must not be negative")
+	}
+	v.nonNegative("backup.max_age", cfg.Backup.MaxAge)
+}
+
+func validateReplication(v *validator, cfg Config) {
+	rc := cfg.Replication
+	if !rc.Enabled {
+		return
+	}
+	if rc.Path == "" {
+		v.fail("replication.path", rc.Path, "must not be empty")
+	} else if samePath(rc.Path, cfg.Backup.Dir) {
+		v.fail("replication.path", rc.Path, "must differ from backup.dir")
+	}
+	v.positive("replication.sync_interval", rc.SyncInterval)
+	v.positive("replication.snapshot_interval", rc.SnapshotInterval)
+	if rc.Retention < rc.SnapshotInterval {
+		v.fail("replication.retention", rc.Retention, "must be at least replication.snapshot_interval (%s)", rc.SnapshotInterval)
+	}
+	if rc.CheckpointFrames < 1 {
+		v.fail("replication.checkpoint_frames", rc.CheckpointFrames, "must be at least 1")
+	}
+}
+
+func validateSecrets(v *validator, cfg SecretsConfig) {
+	switch cfg.Provider {
+	case "", "env", "file":
+	case "encrypted":
+		if cfg.File == "" {
+			v.fail("secrets.file", cfg.File, "must be set for the encrypted provider")
+		}
+	default:
+		v.fail("secrets.provider", cfg.Provider, "unknown secrets provider (expected env, file or encrypted)")
+	}
+}
+
+// ParseNetwork parses a CIDR, or an IP address as a single-address network
+func ParseNetwork(s string) (*net.IPNet, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(s)
+	return network, err
+}
+
+// ParseOrigin compiles an allowed origin pattern into an expression matching
+// whole lower-case origins. Patterns are an exact origin such as
+// https://app.example.com, a wildcard matching one or more subdomain labels
+// such as https://*.example.com, regex:<expression>, or * for any origin
+func ParseOrigin(pattern string) (*regexp.Regexp, error) {
+	switch {
+	case pattern == "*":
+		return regexp.MustCompile(`^.*$`), nil
+	case strings.HasPrefix(pattern, "regex:"):
+		re, err := regexp.Compile("^(?:" + strings.TrimPrefix(pattern, "regex:") + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression: %v", err)
+		}
+		return re, nil
+	}
+
+	pattern = strings.ToLower(pattern)
+	scheme, rest, _ := strings.Cut(pattern, "://")
+	wildcard := strings.HasPrefix(rest, "*.")
+	host := rest
+	if wildcard {
+		host = "x" + strings.TrimPrefix(rest, "*") // Checked like a concrete subdomain
+	}
+	u, err := url.Parse(scheme + "://" + host)
+	if err != nil || scheme == "" || u.Host == "" || u.Host != host || strings.Contains(host, "*") {
+		return nil, fmt.Errorf("must be an origin such as https://app.example.com or https://*.example.com")
+	}
+	expr := regexp.QuoteMeta(pattern)
+	if wildcard {
+		expr = regexp.QuoteMeta(scheme+"://") + `[a-z0-9-]+(?:\.[a-z0-9-]+)*` + regexp.QuoteMeta(strings.TrimPrefix(rest, "*"))
+	}
+	return regexp.MustCompile("^" + expr + "$"), nil
+}
+
+// isToken reports whether s is an HTTP token, the syntax of method and
+// header names
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("!#$%&'*+-.^_`|~", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkHostPort verifies addr has the form host:port with a valid port
+func checkHostPort(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("must be host:port: %v", err)
+	}
+	if host == "" {
+		return fmt.Errorf("missing host")
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+// checkFile verifies that path names a readable regular file
+func checkFile(v *validator, key, path string) {
+	if path == "" {
+		v.fail(key, path, "must be set")
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		v.fail(key, path, "%v", err)
+		return
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil && info.IsDir() {
+		v.fail(key, path, "is a directory")
+	}
+}
+
+// checkDir verifies that dir exists, is a directory, and is writable
+func checkDir(v *validator, key string, value any, dir string) {
+	info, err := os.Stat(dir)
+	switch {
+	case os.IsNotExist(err):
+		v.fail(key, value, "parent directory %s does not exist", dir)
+	case err != nil:
+		v.fail(key, value, "%v", err)
+	case !info.IsDir():
+		v.fail(key, value, "parent %s is not a directory", dir)
+	case info.Mode().Perm()&0o200 == 0:
+		v.fail(key, value, "parent directory %s is not writable", dir)
+	}
+}
+
+// samePath reports whether two paths refer to the same location after cleaning
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fieldErrors returns the validation errors keyed by key path
+func fieldErrors(t *testing.T, err error) map[string]FieldError {
+	t.Helper()
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr), "expected *ValidationError, got %v", err)
+	byKey := make(map[string]FieldError)
+	for _, fe := range verr.Errors {
+		byKey[fe.Key] = fe
+	}
+	return byKey
+}
+
+// TestValidate checks individual rules against an otherwise valid config
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	valid := func() Config {
+		var cfg Config
+		cfg.AppName = "gopark"
+		cfg.Port = 8080
+		cfg.Database.Type = "sqlite"
+		cfg.Database.Path = filepath.Join(dir, "gopark.db")
+		cfg.Backup.Dir = filepath.Join(dir, "backups")
+		cfg.Log.Format = "text"
+		cfg.Shutdown = ShutdownConfig{DrainTimeout: time.Second, ReadyTimeout: time.Second}
+		return cfg
+	}
+
+	// Test case 1: a minimal config is valid
+	assert.NoError(t, Validate(valid(), nil))
+
+	// Test case 2: every problem is reported at once
+	cfg := valid()
+	cfg.Port = -1
+	cfg.Database.Type = "postgres"
+	cfg.Redis = "localhost"
+	errs := fieldErrors(t, Validate(cfg, nil))
+	assert.Len(t, errs, 3)
+	assert.Contains(t, errs, "port")
+	assert.Contains(t, errs, "database.type")
+	assert.Contains(t, errs, "redis")
+	assert.Equal(t, SourceDefault, errs["port"].Source)
+
+	// Test case 3: database paths must be writable files in existing directories
+	cfg = valid()
+	cfg.Database.Path = filepath.Join(dir, "missing", "gopark.db")
+	assert.Contains(t, fieldErrors(t, Validate(cfg, nil)), "database.path")
+
+	readOnly := filepath.Join(dir, "readonly.db")
+	require.NoError(t, os.WriteFile(readOnly, nil, 0o444))
+	cfg.Database.Path = readOnly
+	assert.Contains(t, fieldErrors(t, Validate(cfg, nil)), "database.path")
+
+	// Test case 4: cross-field constraints are only checked for enabled features
+	cfg = valid()
+	cfg.Jobs = JobsConfig{Concurrency: 1, PollInterval: 1, MaxAttempts: 1, Timeout: 10, Backoff: 10, MaxBackoff: 5, StaleAfter: 10}
+	assert.NoError(t, Validate(cfg, nil))
+	cfg.Jobs.Enabled = true
+	errs = fieldErrors(t, Validate(cfg, nil))
+	assert.Contains(t, errs, "jobs.max_backoff")
+	assert.Contains(t, errs, "jobs.stale_after")
+
+	// Test case 5: list entries are reported with their index
+	cfg = valid()
+	cfg.Scheduler = SchedulerConfig{Enabled: true, LeaseTTL: 10, Tasks: []ScheduledTask{
+		{Name: "vacuum", Schedule: "@daily"},
+		{Name: "vacuum", Schedule: "not a schedule"},
+	}}
+	errs = fieldErrors(t, Validate(cfg, nil))
+	assert.Contains(t, errs, "scheduler.tasks[1].name")
+	assert.Contains(t, errs, "scheduler.tasks[1].schedule")
+
+	// Test case 6: TLS needs readable certificate files and known settings
+	cfg = valid()
+	cfg.TLS = TLSConfig{Enabled: true, CertFile: filepath.Join(dir, "missing.crt"), MinVersion: "1.1",
+		CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}, ClientAuth: "require", IdentityFrom: "serial"}
+	cfg.HTTP2.H2C = true
+	errs = fieldErrors(t, Validate(cfg, nil))
+	for _, key := range []string{"tls.cert_file", "tls.key_file", "tls.min_version", "tls.cipher_suites", "tls.client_ca_file", "tls.identity_from", "http2.h2c"} {
+		assert.Contains(t, errs, key)
+	}
+	cfg = valid()
+	cfg.TLS = TLSConfig{Enabled: true, SelfSigned: true, CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}}
+	assert.NoError(t, Validate(cfg, nil))
+
+	// Test case 7: listener addresses must be parseable and distinct
+	cfg = valid()
+	cfg.Listeners.Public.Address = "localhost"
+	cfg.Listeners.Admin = ListenerConfig{Address: "unix:" + filepath.Join(dir, "missing", "admin.sock"), WriteTimeout: -time.Second}
+	errs = fieldErrors(t, Validate(cfg, nil))
+	for _, key := range []string{"listeners.public.address", "listeners.admin.address", "listeners.admin.write_timeout"} {
+		assert.Contains(t, errs, key)
+	}
+	cfg = valid()
+	cfg.Listeners.Admin.Address = fmt.Sprintf(":%d", cfg.Port)
+	assert.Contains(t, fieldErrors(t, Validate(cfg, nil)), "listeners.admin.address")
+	cfg.Listeners.Admin.Address = fmt.Sprintf("127.0.0.1:%d", cfg.Port) // The public listener binds every host
+	assert.Contains(t, fieldErrors(t, Validate(cfg, nil)), "listeners.admin.address")
+	cfg.Listeners.Public.Address = "[::1]:8443"
+	cfg.Listeners.Admin.Address = "localhost:8443"
+	assert.Contains(t, fieldErrors(t, Validate(cfg, nil)), "listeners.admin.address")
+	cfg.Listeners.Admin.Address = "127.0.0.2:8443"
+	assert.NoError(t, Validate(cfg, nil))
+	cfg.Listeners.Public.Address = ""
+	cfg.Listeners.Admin.Address = "systemd:admin"
+	assert.NoError(t, Validate(cfg, nil))
+
+	// Test case 8: tracing needs a known exporter, an HTTP endpoint and a ratio
+	cfg = valid()
+	cfg.Tracing = TracingConfig{Enabled: true, Exporter: "otlp", Endpoint: "localhost:4318", SampleRatio: 1.5}
+	errs = fieldErrors(t, Validate(cfg, nil))
+	for _, key := range []string{"tracing.endpoint", "tracing.sample_ratio"} {
+		assert.Contains(t, errs, key)
+	}
+	cfg.Tracing = TracingConfig{Enabled: true, Exporter: "jaeger"}
+	assert.Contains(t, fieldErrors(t, Validate(cfg, nil)), "tracing.exporter")
+	cfg.Tracing = TracingConfig{Enabled: true, Exporter: "stdout", SampleRatio: 0.1}
+	assert.NoError(t, Validate(cfg, nil))
+
+	// Test case 9: log sampling settings are only checked when it is enabled
+	cfg = valid()
+	cfg.Log = LogConfig{Format: "logfmt", Sampling: LogSamplingConfig{Initial: -1}}
+	errs = fieldErrors(t, Validate(cfg, nil))
+	assert.Len(t, errs, 1)
+	assert.Contains(t, errs, "log.format")
+	cfg.Log = LogConfig{Format: "json", Sampling: LogSamplingConfig{Enabled: true, Initial: -1}}
+	errs = fieldErrors(t, Validate(cfg, nil))
+	for _, key := range []string{"log.sampling.initial", "log.sampling.tick"} {
+		assert.Contains(t, errs, key)
+	}
+
+	// Test case 10: trusted request ID sources are IP addresses or CIDRs
+	cfg = valid()
+	cfg.RequestID.TrustedNetworks = []string{"10.0.0.0/8", "::1", "192.168.1.300"}
+	errs = fieldErrors(t, Validate(cfg, nil))
+	assert.Len(t, errs, 1)
+	assert.Contains(t, errs, "request_id.trusted_networks[2]")
+
+	// Test case 11: CORS origins must be valid patterns and * excludes credentials
+	cfg = valid()
+	cfg.CORS.Default = CORSPolicy{AllowedOrigins: []string{"https://app.example.com", "https://*.example.com", `regex:https://pr-\d+\.example\.com`, "*"}, MaxAge: time.Minute}
+	assert.NoError(t, Validate(cfg, nil))
+	cfg.CORS.Default.AllowCredentials = true
+	cfg.CORS.Routes = []CORSRoute{
+		{Prefix: "/api", CORSPolicy: CORSPolicy{AllowedOrigins: []string{"https://app.example.com/", "https://a.*.example.com", "regex:("}}},
+		{Prefix: "api", CORSPolicy: CORSPolicy{AllowedMethods: []string{"get"}, AllowedHeaders: []string{"X Debug"}, MaxAge: -time.Second}},
+	}
+	errs = fieldErrors(t, Validate(cfg, nil))
+	for _, key := range []string{"cors.default.allow_credentials", "cors.routes[0].allowed_origins[0]", "cors.routes[0].allowed_origins[1]",
+		"cors.routes[0].allowed_origins[2]", "cors.routes[1].prefix", "cors.routes[1].allowed_methods[0]", "cors.routes[1].allowed_headers[0]", "cors.routes[1].max_age"} {
+		assert.Contains(t, errs, key)
+	}
+	assert.Len(t, errs, 8)
+
+	// Test case 12: the liveness stall window must outlast a single check
+	cfg = valid()
+	cfg.Health = HealthConfig{Timeout: 2 * time.Second, StallAfter: time.Second}
+	assert.Contains(t, fieldErrors(t, Validate(cfg, nil)), "health.stall_after")
+	cfg.Health.StallAfter = 0
+	assert.NoError(t, Validate(cfg, nil))
+
+	// Test case 13: the readiness delay must leave time to drain
+	cfg = valid()
+	cfg.Shutdown.ReadinessDelay = time.Second
+	assert.Contains(t, fieldErrors(t, Validate(cfg, nil)), "shutdown.readiness_delay")
+	cfg.Shutdown.ReadinessDelay = time.Second / 2
+	assert.NoError(t, Validate(cfg, nil))
+}
+
+// TestLoadConfigValidationSources verifies errors name the source of the bad value
+func TestLoadConfigValidationSources(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "config.yaml", "port: 0\ndatabase:\n  type: mysql\n")
+	t.Setenv("GOPARK_REDIS", "not-an-address")
+
+	_, err := load(t, dir, "--set", "backup.retain=-1")
+	errs := fieldErrors(t, err)
+	assert.Equal(t, SourceFile, errs["port"].Source)
+	assert.Equal(t, SourceFile, errs["database.type"].Source)
+	assert.Equal(t, SourceEnv, errs["redis"].Source)
+	assert.Equal(t, SourceFlag, errs["backup.retain"].Source)
+}
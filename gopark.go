@@ -0,0 +1,605 @@
+// Package gopark embeds the GoPark user service. New wires the database,
+// migrations, event publisher, background workers and HTTP routes the same
+// way the gopark command does, so other services can mount the user API or
+// run the whole service in-process, e.g. in tests:
+//
+//	app, err := gopark.New(gopark.WithConfig(cfg), gopark.WithMigrations("internal/migrations"))
+//	if err != nil {
+//		return err
+//	}
+//	if err := app.Start(ctx); err != nil {
+//		return err
+//	}
+//	defer app.Stop(context.Background())
+package gopark
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"gopark/config"
+	"gopark/internal/backup"
+	"gopark/internal/cors"
+	"gopark/internal/db"
+	"gopark/internal/events"
+	"gopark/internal/handlers"
+	"gopark/internal/health"
+	"gopark/internal/jobs"
+	"gopark/internal/lifecycle"
+	"gopark/internal/metrics"
+	"gopark/internal/models"
+	"gopark/internal/replica"
+	"gopark/internal/routes"
+	"gopark/internal/scheduler"
+	"gopark/internal/server"
+	"gopark/internal/tracing"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// User is a GoPark user
+type User = models.User
+
+// UserStore persists users. Inject one with WithStore to serve the user API
+// from storage other than the built-in SQLite database
+type UserStore = handlers.UserStore
+
+// Component is a part of the service started and stopped by the App. It
+// may depend on the built-in components by name
+type Component = lifecycle.Component
+
+// Names of the built-in components. Without the SQLite database only
+// ComponentEvents and ComponentHTTP exist, and ComponentTracing only exists
+// when tracing is enabled
+const (
+	ComponentTracing     = "tracing"
+	ComponentDatabase    = "database"
+	ComponentEvents      = "events"
+	ComponentReplication = "replication"
+	ComponentJobs        = "jobs"
+	ComponentScheduler   = "scheduler"
+	ComponentHTTP        = "http"
+)
+
+// HealthCheck is a check behind the /livez and /readyz probes, added with
+// WithHealthCheck
+type HealthCheck = health.Check
+
+// Identity is the caller authenticated by a verified client certificate
+// when tls.client_auth is request or require
+type Identity = server.Identity
+
+// ClientIdentity returns the client certificate identity of the request
+// whose context is ctx, for authentication middleware
+func ClientIdentity(ctx context.Context) (Identity, bool) {
+	return server.ClientIdentity(ctx)
+}
+
+// App is an embeddable GoPark service
+type App struct {
+	cfg        config.Config
+	log        *logrus.Logger
+	store      UserStore
+	migrations string
+	migrate    bool
+	middleware []gin.HandlerFunc
+	groups     []routeGroup
+
+	adminMiddleware []gin.HandlerFunc
+
+	db         *db.DB // Opened by New unless a store is injected
+	publisher  events.Publisher
+	queue      *jobs.Queue
+	scheduler  *scheduler.Scheduler
+	backups    *backup.Manager
+	replicator *replica.Replicator
+	cors       *cors.Policies
+	engine     *gin.Engine
+	admin      *gin.Engine
+	server     *server.Server
+
+	singletons     []func(ctx context.Context) error // Deferred starts of a process started by Restart
+	stopSingletons context.CancelFunc
+	singletonsDone chan struct{}
+
+	components []Component
+	checks     []HealthCheck
+	health     *health.Registry
+	metrics    *metrics.Metrics
+	tracing    *tracing.Provider
+	identity   func(cert *x509.Certificate) (string, bool)
+	lifecycle  *lifecycle.Manager
+
+	readinessDelay atomic.Int64 // shutdown.readiness_delay, replaced by SetConfig
+}
+
+// Names of the HTTP listeners
+const (
+	listenerPublic = "public"
+	listenerAdmin  = "admin"
+)
+
+// routeGroup is an extra route group registered through WithRouteGroup
+type routeGroup struct {
+	prefix     string
+	register   func(*gin.RouterGroup)
+	middleware []gin.HandlerFunc
+}
+
+// New builds an App. Unless WithStore is given it opens the configured
+// SQLite database, applies migrations when WithMigrations is given, and
+// creates the job queue, scheduler and backup manager. Nothing runs in the
+// background until Start
+func New(opts ...Option) (*App, error) {
+	a := &App{cfg: config.Default()}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.log == nil {
+		a.log = logrus.New()
+	}
+	a.lifecycle = lifecycle.NewManager(a.log)
+	a.health = health.NewRegistry(a.cfg.Health, a.log)
+	a.readinessDelay.Store(int64(a.cfg.Shutdown.ReadinessDelay))
+	a.metrics = metrics.New()
+
+	if err := a.init(); err != nil {
+		a.lifecycle.Stop(context.Background()) // Close whatever init opened
+		return nil, err
+	}
+	return a, nil
+}
+
+// init opens storage, builds the services and router, and registers each
+// service with the lifecycle manager as soon as it exists
+func (a *App) init() error {
+	cfg, log := a.cfg, a.log
+
+	provider, err := tracing.New(cfg.Tracing, cfg.AppName)
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+	a.tracing = provider
+	if provider.Enabled() {
+		log.AddHook(tracing.LogHook{})
+		otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+			log.Warnf("Tracing: %v", err) // E.g. the collector is unreachable
+		}))
+		// Added first so that it stops last and exports the spans of
+		// everything stopped before it
+		a.lifecycle.Add(lifecycle.Component{Name: ComponentTracing, Stop: provider.Shutdown})
+	}
+
+	if a.store == nil {
+		dbConn, err := db.NewDB(cfg, log)
+		if err != nil {
+			return fmt.Errorf("failed to initialize database connection: %w", err)
+		}
+		a.db, a.store = dbConn, dbConn
+		if err := dbConn.RegisterMetrics(a.metrics.Registerer()); err != nil {
+			dbConn.Close()
+			return fmt.Errorf("failed to register database metrics: %w", err)
+		}
+		a.lifecycle.Add(lifecycle.Component{
+			Name: ComponentDatabase,
+			Stop: func(ctx context.Context) error {
+				dbConn.Close()
+				return nil
+			},
+		})
+
+		if err := a.runMigrations(); err != nil {
+			return err
+		}
+	}
+
+	broker, err := events.NewPublisher(cfg, log)
+	if err != nil {
+		return fmt.Errorf("failed to initialize event publisher: %w", err)
+	}
+	// Requests only queue events, so a slow broker never delays a response
+	publisher := events.NewAsyncPublisher(broker, log)
+	a.publisher = publisher
+	a.lifecycle.Add(lifecycle.Component{
+		Name: ComponentEvents,
+		Stop: publisher.Shutdown,
+	})
+
+	// Background services need the SQLite database
+	httpDeps := []string{ComponentEvents}
+	if a.db != nil {
+		a.queue = jobs.NewQueue(a.db, cfg.Jobs, log)
+		a.backups = backup.NewManager(a.db, cfg.Backup, log)
+		a.scheduler = scheduler.NewScheduler(a.db, cfg.Scheduler, log)
+		if err := a.scheduler.RegisterMetrics(a.metrics.Registerer()); err != nil {
+			return fmt.Errorf("failed to register scheduler metrics: %w", err)
+		}
+		a.registerTasks()
+		a.addWorkers()
+		httpDeps = append(httpDeps, ComponentDatabase, ComponentJobs, ComponentScheduler)
+		a.registerChecks()
+	}
+	if cfg.Redis != "" {
+		// No request depends on Redis yet, so an outage only warns
+		a.health.Register(health.Check{Name: "redis", Optional: true, Check: health.Redis(cfg.Redis)})
+	}
+	for _, check := range a.checks {
+		a.health.Register(check)
+	}
+
+	a.cors = cors.New(cfg.CORS)
+	deps := routes.Dependencies{
+		Log:             log,
+		RequestID:       cfg.RequestID,
+		CORS:            a.cors,
+		DB:              a.db,
+		Users:           a.store,
+		Events:          publisher,
+		Health:          a.health,
+		Metrics:         a.metrics,
+		Middleware:      a.middleware,
+		AdminMiddleware: a.adminMiddleware,
+	}
+	if provider.Enabled() {
+		deps.Tracing = provider
+	}
+	// Typed nil pointers would register admin routes that panic
+	if a.db != nil {
+		deps.Jobs, deps.Scheduler, deps.Backups = a.queue, a.scheduler, a.backups
+	}
+	a.engine = gin.New()
+	a.engine.Use(gin.Recovery())
+	routes.SetupRoutes(a.engine, deps)
+	for _, group := range a.groups {
+		group.register(a.engine.Group(group.prefix, group.middleware...))
+	}
+	a.admin = gin.New()
+	a.admin.Use(gin.Recovery())
+	routes.SetupAdminRoutes(a.admin, deps)
+
+	// Stopping the server first lets in-flight requests finish before the
+	// workers and the database they use go away
+	a.server = server.NewServer(log, a.serverOptions()...)
+	a.lifecycle.Add(lifecycle.Component{
+		Name:      ComponentHTTP,
+		DependsOn: httpDeps,
+		Start:     func(ctx context.Context) error { return a.server.Start() },
+		Stop:      a.server.Shutdown,
+	})
+
+	for _, c := range a.components {
+		if err := a.lifecycle.Add(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serverOptions configures the public listener, serving the user API with
+// TLS when enabled, and the admin listener unless its address is empty
+func (a *App) serverOptions() []server.Option {
+	cfg := a.cfg
+	public := cfg.Listeners.Public
+	if public.Address == "" {
+		public.Address = fmt.Sprintf(":%d", cfg.Port)
+	}
+	opts := []server.Option{
+		server.WithListener(listener(listenerPublic, public, a.engine, true)),
+		server.WithTLS(cfg.TLS),
+		server.WithHTTP2(cfg.HTTP2),
+	}
+	if cfg.Listeners.Admin.Address != "" {
+		opts = append(opts, server.WithListener(listener(listenerAdmin, cfg.Listeners.Admin, a.admin, false)))
+	}
+	if a.identity != nil {
+		opts = append(opts, server.WithIdentityMapper(server.IdentityMapper(a.identity)))
+	}
+	return opts
+}
+
+// listener builds a server listener from its configuration
+func listener(name string, cfg config.ListenerConfig, handler http.Handler, tls bool) server.Listener {
+	return server.Listener{
+		Name:         name,
+		Address:      cfg.Address,
+		Handler:      handler,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+		TLS:          tls,
+	}
+}
+
+// addWorkers registers the background services. Replication starts before
+// and stops after every writer, so the final frames are shipped; workers
+// whose feature is disabled are registered anyway and stay idle. None of them
+// may run in two processes at once, see singleton
+func (a *App) addWorkers() {
+	cfg := a.cfg
+	a.lifecycle.Add(lifecycle.Component{
+		Name:      ComponentReplication,
+		DependsOn: []string{ComponentDatabase},
+		Start: a.singleton(func(ctx context.Context) error {
+			if !cfg.Replication.Enabled {
+				return nil
+			}
+			storage, err := replica.NewLocalStorage(cfg.Replication.Path)
+			if err != nil {
+				return fmt.Errorf("failed to initialize replica storage: %w", err)
+			}
+			replicator := replica.NewReplicator(a.db, storage, cfg.Replication, a.log)
+			if err := replicator.Start(ctx); err != nil {
+				return err
+			}
+			a.replicator = replicator
+			return nil
+		}),
+		Stop: func(ctx context.Context) error {
+			if a.replicator == nil {
+				return nil
+			}
+			return a.replicator.Stop(ctx)
+		},
+	})
+	a.lifecycle.Add(lifecycle.Component{
+		Name:      ComponentJobs,
+		DependsOn: []string{ComponentDatabase, ComponentReplication},
+		Start: a.singleton(func(ctx context.Context) error {
+			if !cfg.Jobs.Enabled {
+				return nil
+			}
+			return a.queue.Start(ctx)
+		}),
+		Stop: a.queue.Drain,
+	})
+	a.lifecycle.Add(lifecycle.Component{
+		Name:      ComponentScheduler,
+		DependsOn: []string{ComponentDatabase, ComponentReplication, ComponentJobs},
+		Start: a.singleton(func(ctx context.Context) error {
+			if !cfg.Scheduler.Enabled {
+				return nil
+			}
+			return a.scheduler.Start()
+		}),
+		Stop: a.scheduler.Stop,
+	})
+}
+
+// singleton returns the Start function of a background service that must not
+// run in two processes at once, such as the replicator, which would write a
+// second generation to the same replica. In a process started by Restart,
+// start only runs once the old process has stopped, in startSingletons
+func (a *App) singleton(start func(ctx context.Context) error) func(ctx context.Context) error {
+	if !server.HasPredecessor() {
+		return start
+	}
+	a.singletons = append(a.singletons, start)
+	return func(ctx context.Context) error { return nil }
+}
+
+// startSingletons waits for the process that started this one through
+// Restart to stop, then starts the services deferred by singleton in their
+// dependency order. It gives up when Stop is called first
+func (a *App) startSingletons(ctx context.Context) {
+	defer close(a.singletonsDone)
+	a.log.Info("Waiting for the previous process to stop before starting background workers")
+	if err := server.WaitForPredecessor(ctx); err != nil {
+		return
+	}
+	for _, start := range a.singletons {
+		if err := start(ctx); err != nil {
+			if ctx.Err() == nil {
+				a.log.Errorf("Failed to start background workers after restart: %v", err)
+			}
+			return
+		}
+	}
+	a.log.Info("Background workers started")
+}
+
+// registerChecks registers the health checks of the SQLite database and the
+// services built on it
+func (a *App) registerChecks() {
+	cfg := a.cfg
+	a.health.Register(health.Check{Name: "database", Check: a.db.Ping})
+	if cfg.Health.StallAfter > 0 {
+		a.health.Register(health.Check{
+			Name:     "database_stalled",
+			Liveness: true,
+			Check:    health.Stalled(a.db.Ping, cfg.Health.StallAfter),
+		})
+	}
+	a.health.Register(health.Check{
+		Name:  "disk",
+		Check: health.DiskSpace(filepath.Dir(cfg.Database.Path), uint64(cfg.Health.MinFreeDiskMB)<<20),
+	})
+	if a.migrations != "" {
+		manager := db.NewMigrationManager(a.db, a.log)
+		a.health.Register(health.Check{Name: "migrations", Check: func(ctx context.Context) error {
+			statuses, err := manager.Status(ctx, a.migrations)
+			if err != nil {
+				return err
+			}
+			var pending []string
+			for _, status := range statuses {
+				if !status.Applied {
+					pending = append(pending, status.Version)
+				}
+			}
+			if len(pending) > 0 {
+				return fmt.Errorf("pending migrations: %s", strings.Join(pending, ", "))
+			}
+			return nil
+		}})
+	}
+	if cfg.Jobs.Enabled && cfg.Health.MaxJobBacklog > 0 {
+		a.health.Register(health.Check{Name: "jobs", Optional: true, Check: func(ctx context.Context) error {
+			pending, err := a.queue.CountByStatus(ctx, jobs.StatusPending)
+			if err != nil {
+				return err
+			}
+			if pending > cfg.Health.MaxJobBacklog {
+				return fmt.Errorf("%d pending jobs, above %d", pending, cfg.Health.MaxJobBacklog)
+			}
+			return nil
+		}})
+	}
+}
+
+// runMigrations applies pending migrations, or only reports them when
+// automatic migration is off
+func (a *App) runMigrations() error {
+	if a.migrations == "" {
+		return nil
+	}
+	manager := db.NewMigrationManager(a.db, a.log)
+	if a.migrate {
+		if err := manager.RunMigrations(context.Background(), a.migrations); err != nil {
+			return fmt.Errorf("failed to run database migrations: %w", err)
+		}
+		a.log.Info("Database migrations completed successfully")
+		return nil
+	}
+
+	statuses, err := manager.Status(context.Background(), a.migrations)
+	if err != nil {
+		a.log.Warnf("Unable to check migrations: %v", err)
+		return nil
+	}
+	for _, status := range statuses {
+		if !status.Applied {
+			a.log.Warnf("Migration %s is pending; run `gopark migrate up`", status.Version)
+		}
+	}
+	return nil
+}
+
+// registerTasks registers the built-in maintenance tasks with the scheduler
+func (a *App) registerTasks() {
+	a.scheduler.Register("analyze", a.db.Analyze)
+	a.scheduler.Register("vacuum", a.db.Vacuum)
+	a.scheduler.Register("jobs_cleanup", func(ctx context.Context) error {
+		_, err := a.queue.PurgeFinished(ctx, time.Now().Add(-a.cfg.Jobs.Retention))
+		return err
+	})
+	a.scheduler.Register("backup", func(ctx context.Context) error {
+		_, err := a.backups.Create(ctx)
+		return err
+	})
+}
+
+// Handler returns the HTTP handler serving the public API, for mounting in
+// another server or for httptest
+func (a *App) Handler() http.Handler {
+	return a.engine
+}
+
+// AdminHandler returns the HTTP handler serving the admin API and pprof,
+// which Start serves on the admin listener
+func (a *App) AdminHandler() http.Handler {
+	return a.admin
+}
+
+// Metrics registers collectors served with the built-in metrics on the
+// admin listener's /metrics endpoint
+func (a *App) Metrics() prometheus.Registerer {
+	return a.metrics.Registerer()
+}
+
+// TracerProvider creates tracers whose spans are exported with the built-in
+// request and query spans; they are discarded unless tracing is enabled
+func (a *App) TracerProvider() trace.TracerProvider {
+	return a.tracing
+}
+
+// Start starts the components in dependency order: the enabled background
+// workers, any added with WithComponent, and the HTTP server on the public
+// and admin listeners. It returns once the server is listening, after telling
+// the process that started this one through Restart, if any. If a component
+// fails to start, everything is stopped again and the App cannot be reused
+func (a *App) Start(ctx context.Context) error {
+	if err := a.lifecycle.Start(ctx); err != nil {
+		return err
+	}
+	if err := server.NotifyReady(); err != nil {
+		a.log.Warnf("Failed to notify the previous process: %v", err)
+	}
+	if len(a.singletons) > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		a.stopSingletons, a.singletonsDone = cancel, make(chan struct{})
+		go a.startSingletons(ctx)
+	}
+	return nil
+}
+
+// Restart starts a new copy of the running binary, which takes over the
+// listening sockets, and returns its process ID once it has started. The
+// caller then stops this App so in-flight requests drain while the new
+// process accepts new connections; if ctx ends first or the new process
+// fails to start, an error is returned and this App keeps serving. The new
+// process starts replication, the job workers and the scheduler only once
+// Stop has returned here, or this process has exited
+func (a *App) Restart(ctx context.Context) (int, error) {
+	return a.server.Handover(ctx)
+}
+
+// Addr returns the address the public listener listens on, or nil before Start
+func (a *App) Addr() net.Addr {
+	return a.server.Addr(listenerPublic)
+}
+
+// AdminAddr returns the address the admin listener listens on, or nil
+// before Start and when it is disabled
+func (a *App) AdminAddr() net.Addr {
+	return a.server.Addr(listenerAdmin)
+}
+
+// SetConfig applies the settings that may change at runtime, currently the
+// CORS policies and the backup retention and compression settings
+func (a *App) SetConfig(cfg config.Config) {
+	a.cors.SetConfig(cfg.CORS)
+	a.readinessDelay.Store(int64(cfg.Shutdown.ReadinessDelay))
+	if a.backups != nil {
+		a.backups.SetConfig(cfg.Backup)
+	}
+}
+
+// Stop stops the components in reverse order: readiness probes fail from
+// then on, and a started App keeps serving for shutdown.readiness_delay so
+// that load balancers notice, or until ctx expires. Then the HTTP server
+// waits for in-flight requests, workers drain, and the publisher and
+// database close last. Components are still stopped after ctx expires,
+// abandoning their remaining work, and every failure is returned. Stop may
+// be called more than once, and without Start to release what New opened.
+// After a Restart, the new process starts its background workers once Stop
+// returns
+func (a *App) Stop(ctx context.Context) error {
+	first := !a.health.Draining()
+	a.health.SetDraining()
+	if delay := time.Duration(a.readinessDelay.Load()); first && delay > 0 && a.Addr() != nil {
+		a.log.Infof("Readiness failing; closing listeners in %s", delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+	if a.stopSingletons != nil {
+		a.stopSingletons()
+		<-a.singletonsDone
+	}
+	err := a.lifecycle.Stop(ctx)
+	a.server.ReleaseSuccessor()
+	return err
+}
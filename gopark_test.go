@@ -0,0 +1,522 @@
+package gopark
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"gopark/config"
+	"gopark/pkg/client"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is an in-memory UserStore
+type memoryStore struct {
+	mu    sync.Mutex
+	users map[uint]User
+	next  uint
+}
+
+func (s *memoryStore) GetUserByID(ctx context.Context, id uint) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return &user, nil
+}
+
+func (s *memoryStore) CreateUser(ctx context.Context, user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	user.ID = s.next
+	s.users[user.ID] = *user
+	return nil
+}
+
+func (s *memoryStore) UpdateUser(ctx context.Context, user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[user.ID] = *user
+	return nil
+}
+
+func (s *memoryStore) DeleteUser(ctx context.Context, id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.users, id)
+	return nil
+}
+
+func (s *memoryStore) SearchUsersByName(ctx context.Context, namePattern string) ([]*User, error) {
+	return nil, nil
+}
+
+func (s *memoryStore) ListUsers(ctx context.Context, limit, offset int) ([]*User, error) {
+	return nil, nil
+}
+
+// envRestartTest makes the test binary act as the new process started by
+// App.Restart, serving restartConfig in the directory it names
+const envRestartTest = "GOPARK_TEST_RESTART"
+
+func TestMain(m *testing.M) {
+	if dir := os.Getenv(envRestartTest); dir != "" {
+		gin.SetMode(gin.TestMode)
+		app, err := New(WithConfig(restartConfig(dir)), WithLogger(quietLogger()), WithMigrations("internal/migrations"))
+		if err != nil || app.Start(context.Background()) != nil {
+			os.Exit(1)
+		}
+		time.Sleep(time.Minute) // Until the test kills it
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// restartConfig replicates a database in dir, with every background worker enabled
+func restartConfig(dir string) config.Config {
+	cfg := config.Default()
+	cfg.Port = 0
+	cfg.Listeners.Admin.Address = ""
+	cfg.Database.Path = filepath.Join(dir, "gopark.db")
+	cfg.Jobs.Enabled = true
+	cfg.Scheduler.Enabled = true
+	cfg.Replication.Enabled = true
+	cfg.Replication.Path = filepath.Join(dir, "replica")
+	cfg.Shutdown.ReadinessDelay = 0
+	return cfg
+}
+
+// quietLogger discards log output
+func quietLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(bytes.NewBuffer(nil)) // Disable logging output
+	return log
+}
+
+// TestEmbedWithStore serves the user API from an injected store
+func TestEmbedWithStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &memoryStore{users: map[uint]User{}}
+	app, err := New(
+		WithLogger(quietLogger()),
+		WithStore(store),
+		WithMiddleware(func(c *gin.Context) { c.Header("X-Embedded", "yes") }),
+		WithRouteGroup("/internal", func(g *gin.RouterGroup) {
+			g.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+		}),
+	)
+	require.NoError(t, err)
+	defer app.Stop(context.Background())
+
+	srv := httptest.NewServer(app.Handler())
+	defer srv.Close()
+	c, err := client.New(srv.URL)
+	require.NoError(t, err)
+
+	// Test case 1: Users are stored in the injected store
+	created, err := c.Users().Create(context.Background(), client.UserInput{Name: "Ada", Mail: "ada@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "Ada", store.users[created.ID].Name)
+
+	// Test case 2: Extra middleware and route groups are mounted
+	resp, err := http.Get(srv.URL + "/internal/ping")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "yes", resp.Header.Get("X-Embedded"))
+
+	// Test case 3: Admin routes need the SQLite database and are absent
+	resp, err = http.Get(srv.URL + "/api/v1/admin/jobs")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+// TestStartStop runs the full service on SQLite on a random port
+func TestStartStop(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.Port = 0
+	cfg.Listeners.Admin.Address = "127.0.0.1:0"
+	cfg.Database.Path = filepath.Join(t.TempDir(), "gopark.db")
+	cfg.Jobs.Enabled = true
+	cfg.Shutdown.ReadinessDelay = time.Hour // Shortened through SetConfig below
+
+	var relay []string
+	app, err := New(WithConfig(cfg), WithLogger(quietLogger()), WithMigrations("internal/migrations"), WithComponent(Component{
+		Name:      "relay",
+		DependsOn: []string{ComponentJobs},
+		Start:     func(ctx context.Context) error { relay = append(relay, "start"); return nil },
+		Stop:      func(ctx context.Context) error { relay = append(relay, "stop"); return nil },
+	}))
+	require.NoError(t, err)
+	require.NoError(t, app.Start(context.Background()))
+	assert.Equal(t, []string{"start"}, relay)
+
+	// Test case 1: The server answers on its address
+	c, err := client.New("http://" + app.Addr().String())
+	require.NoError(t, err)
+	users, err := c.Users().List(context.Background(), client.ListOptions{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, users) // Seeded by the migrations
+
+	// Test case 2: Admin routes are served on the admin listener only
+	resp, err := http.Get("http://" + app.AdminAddr().String() + "/api/v1/admin/jobs")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, err = http.Get("http://" + app.Addr().String() + "/api/v1/admin/jobs")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Test case 3: Readiness checks are detailed on the admin listener only
+	var report struct {
+		Status string
+		Checks []struct{ Name, Status string }
+	}
+	resp, err = http.Get("http://" + app.Addr().String() + "/readyz")
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ok", report.Status)
+	assert.Empty(t, report.Checks)
+	resp, err = http.Get("http://" + app.AdminAddr().String() + "/health")
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	resp.Body.Close()
+	var checks []string
+	for _, check := range report.Checks {
+		checks = append(checks, check.Name)
+		assert.Equal(t, "ok", check.Status, check.Name)
+	}
+	assert.ElementsMatch(t, []string{"database", "database_stalled", "disk", "migrations", "jobs"}, checks)
+	resp, err = http.Get("http://" + app.AdminAddr().String() + "/livez")
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	resp.Body.Close()
+	assert.Equal(t, "ok", report.Status)
+	require.Len(t, report.Checks, 1)
+	assert.Equal(t, "database_stalled", report.Checks[0].Name)
+
+	// Test case 4: Metrics are labelled by route template and served on the admin listener
+	resp, err = http.Get("http://" + app.AdminAddr().String() + "/metrics")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	for _, metric := range []string{
+		`gopark_http_requests_total{method="GET",route="/api/v1/users/list",status="200"} 1`,
+		`gopark_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`gopark_http_request_duration_seconds_bucket{method="GET",route="/readyz",status="200",le="+Inf"} 1`,
+		`gopark_db_query_duration_seconds_count{result="ok",statement="select users"}`,
+		`gopark_db_migration_version 4`,
+		`go_sql_open_connections{db_name="gopark"}`,
+		`gopark_build_info{`,
+		`go_goroutines `,
+	} {
+		assert.Contains(t, string(body), metric)
+	}
+
+	// Test case 5: Starting twice fails
+	assert.Error(t, app.Start(context.Background()))
+
+	// Test case 6: During the readiness delay readiness fails while liveness
+	// passes and requests are still served
+	cfg.Shutdown.ReadinessDelay = 500 * time.Millisecond
+	app.SetConfig(cfg)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stopped := make(chan error, 1)
+	go func() { stopped <- app.Stop(ctx) }()
+	probe := func(path string) int {
+		resp, err := http.Get("http://" + app.Addr().String() + path)
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	require.Eventually(t, func() bool { return probe("/readyz") == http.StatusServiceUnavailable }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, http.StatusOK, probe("/livez"))
+	_, err = c.Users().List(context.Background(), client.ListOptions{})
+	assert.NoError(t, err)
+
+	// Test case 7: Stop shuts down and may be repeated
+	require.NoError(t, <-stopped)
+	require.NoError(t, app.Stop(ctx))
+	assert.Equal(t, []string{"start", "stop"}, relay)
+	_, err = c.Users().List(context.Background(), client.ListOptions{})
+	assert.Error(t, err)
+}
+
+// TestStartFailure stops whatever started when the port is taken
+func TestStartFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	first, err := New(WithLogger(quietLogger()), WithStore(&memoryStore{}), WithConfig(func() config.Config {
+		cfg := config.Default()
+		cfg.Port = 0
+		cfg.Listeners.Admin.Address = "" // Disabled
+		cfg.Shutdown.ReadinessDelay = 0
+		return cfg
+	}()))
+	require.NoError(t, err)
+	require.NoError(t, first.Start(context.Background()))
+	defer first.Stop(context.Background())
+
+	cfg := config.Default()
+	cfg.Database.Path = filepath.Join(t.TempDir(), "gopark.db")
+	cfg.Port = first.Addr().(*net.TCPAddr).Port
+	cfg.Listeners.Admin.Address = "127.0.0.1:0"
+	cfg.Jobs.Enabled = true
+	second, err := New(WithConfig(cfg), WithLogger(quietLogger()), WithMigrations("internal/migrations"))
+	require.NoError(t, err)
+	assert.ErrorContains(t, second.Start(context.Background()), "failed to start http")
+}
+
+// TestTracing follows an incoming trace through the request and its queries
+func TestTracing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var mu sync.Mutex
+	var exported []byte
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		exported = append(exported, data...)
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	cfg := config.Default()
+	cfg.Database.Path = filepath.Join(t.TempDir(), "gopark.db")
+	cfg.Tracing = config.TracingConfig{Enabled: true, Exporter: "otlp", Endpoint: collector.URL}
+	var logs bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&logs)
+	app, err := New(WithConfig(cfg), WithLogger(log), WithMigrations("internal/migrations"))
+	require.NoError(t, err)
+
+	// Test case 1: a sampled traceparent is followed although sample_ratio is 0
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/list", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	app.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, logs.String(), "trace_id=4bf92f3577b34da6a3ce929d0e0e4736")
+
+	// Test case 2: the request and query spans are exported when the App stops
+	require.NoError(t, app.Stop(context.Background()))
+	mu.Lock()
+	defer mu.Unlock()
+	traceID, err := hex.DecodeString("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+	assert.True(t, bytes.Contains(exported, traceID))
+	assert.Contains(t, string(exported), "GET /api/v1/users/list")
+	assert.Contains(t, string(exported), "select users")
+}
+
+// TestAccessLog verifies each request is logged once and store logs carry
+// the request's fields
+func TestAccessLog(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.Database.Path = filepath.Join(t.TempDir(), "gopark.db")
+	var logs bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&logs)
+	log.SetFormatter(&logrus.JSONFormatter{})
+	app, err := New(WithConfig(cfg), WithLogger(log), WithMigrations("internal/migrations"))
+	require.NoError(t, err)
+	defer app.Stop(context.Background())
+	logs.Reset()
+
+	// Test case 1: one access log line with the request, route and user fields
+	w := httptest.NewRecorder()
+	app.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/users?id=999", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	var lines []map[string]any
+	for _, raw := range bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n")) {
+		var line map[string]any
+		require.NoError(t, json.Unmarshal(raw, &line))
+		lines = append(lines, line)
+	}
+	var access []map[string]any
+	for _, line := range lines {
+		assert.Equal(t, w.Header().Get("X-Request-ID"), line["request_id"], line["msg"])
+		assert.Equal(t, "/api/v1/users", line["route"], line["msg"])
+		if line["msg"] == "HTTP Request" {
+			access = append(access, line)
+		}
+	}
+	require.Len(t, access, 1)
+	assert.Equal(t, 999.0, access[0]["user_id"])
+	assert.Equal(t, 404.0, access[0]["status_code"])
+
+	// Test case 2: the store logs through the request's logger
+	var store bool
+	for _, line := range lines {
+		store = store || line["msg"] == "No user found with ID 999"
+	}
+	assert.True(t, store)
+}
+
+// TestRequestID verifies request IDs are generated, accepted from trusted
+// callers, and echoed in responses and error bodies
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.Database.Path = filepath.Join(t.TempDir(), "gopark.db")
+	app, err := New(WithConfig(cfg), WithLogger(quietLogger()), WithMigrations("internal/migrations"))
+	require.NoError(t, err)
+	defer app.Stop(context.Background())
+
+	serve := func(remote string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users?id=999", nil)
+		req.RemoteAddr = remote
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		w := httptest.NewRecorder()
+		app.Handler().ServeHTTP(w, req)
+		return w
+	}
+	bodyID := func(w *httptest.ResponseRecorder) string {
+		var body struct {
+			RequestID string `json:"request_id"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body.RequestID
+	}
+
+	// Test case 1: a new UUIDv7 is echoed in the header and the error body
+	w := serve("192.0.2.1:1234", nil)
+	id := w.Header().Get("X-Request-ID")
+	assert.Len(t, id, 36)
+	assert.Equal(t, "7", id[14:15]) // UUID version
+	assert.Equal(t, id, bodyID(w))
+	assert.NotEqual(t, id, serve("192.0.2.1:1234", nil).Header().Get("X-Request-ID"))
+
+	// Test case 2: IDs from untrusted callers are replaced
+	w = serve("192.0.2.1:1234", http.Header{"X-Request-Id": {"from-client"}})
+	assert.NotEqual(t, "from-client", w.Header().Get("X-Request-ID"))
+
+	// Test case 3: trusted callers set the ID, directly or through traceparent
+	w = serve("127.0.0.1:1234", http.Header{"X-Request-Id": {"from-proxy"}})
+	assert.Equal(t, "from-proxy", w.Header().Get("X-Request-ID"))
+	assert.Equal(t, "from-proxy", bodyID(w))
+	w = serve("127.0.0.1:1234", http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}})
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", w.Header().Get("X-Request-ID"))
+
+	// Test case 4: invalid incoming IDs are replaced
+	w = serve("127.0.0.1:1234", http.Header{"X-Request-Id": {"bad id\r\n"}})
+	assert.Len(t, w.Header().Get("X-Request-ID"), 36)
+}
+
+// TestCORS checks that the configured policies apply to the public routes
+// and that SetConfig replaces them
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.Database.Path = filepath.Join(t.TempDir(), "gopark.db")
+	cfg.CORS.Routes = []config.CORSRoute{{Prefix: "/api/v1/users", CORSPolicy: config.CORSPolicy{
+		AllowedOrigins:   []string{"https://*.example.com"},
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders:   []string{"Content-Type"},
+		AllowCredentials: true,
+	}}}
+	app, err := New(WithConfig(cfg), WithLogger(quietLogger()), WithMigrations("internal/migrations"))
+	require.NoError(t, err)
+	defer app.Stop(context.Background())
+
+	preflight := func(path, origin, method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, path, nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", method)
+		w := httptest.NewRecorder()
+		app.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	// Test case 1: the default policy allows any origin without credentials
+	req := httptest.NewRequest(http.MethodGet, "/livez", nil)
+	req.Header.Set("Origin", "https://other.test")
+	w := httptest.NewRecorder()
+	app.Handler().ServeHTTP(w, req)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+
+	// Test case 2: the route override echoes matching origins with credentials
+	w = preflight("/api/v1/users", "https://app.example.com", http.MethodPost)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Contains(t, w.Header().Values("Vary"), "Origin")
+	assert.Equal(t, http.StatusForbidden, preflight("/api/v1/users/1", "https://app.example.com", http.MethodDelete).Code)
+	assert.Equal(t, http.StatusForbidden, preflight("/api/v1/users", "https://other.test", http.MethodGet).Code)
+
+	// Test case 3: reloaded policies apply to the next request
+	cfg.CORS.Routes = nil
+	cfg.CORS.Default.AllowedOrigins = []string{"https://app.example.com"}
+	app.SetConfig(cfg)
+	assert.Equal(t, http.StatusNoContent, preflight("/api/v1/users/1", "https://app.example.com", http.MethodDelete).Code)
+	assert.Equal(t, http.StatusForbidden, preflight("/livez", "https://other.test", http.MethodGet).Code)
+}
+
+// TestRestart checks that the process started by Restart serves at once but
+// starts its background workers only after the old process has stopped, so
+// that a single replicator writes to the replica at any time
+func TestRestart(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	app, err := New(WithConfig(restartConfig(dir)), WithLogger(quietLogger()), WithMigrations("internal/migrations"))
+	require.NoError(t, err)
+	require.NoError(t, app.Start(context.Background()))
+	defer app.Stop(context.Background())
+	generations := func() int {
+		entries, _ := os.ReadDir(filepath.Join(dir, "replica", "generations"))
+		return len(entries)
+	}
+	require.Equal(t, 1, generations())
+
+	// Test case 1: the new process serves without starting a second replicator
+	t.Setenv(envRestartTest, dir)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	pid, err := app.Restart(ctx)
+	require.NoError(t, err)
+	defer func() {
+		if process, err := os.FindProcess(pid); err == nil {
+			process.Kill()
+		}
+	}()
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, 1, generations())
+
+	// Test case 2: once the old process has stopped, the new one replicates
+	require.NoError(t, app.Stop(ctx))
+	assert.Eventually(t, func() bool { return generations() == 2 }, 5*time.Second, 20*time.Millisecond)
+
+	resp, err := http.Get("http://" + app.Addr().String() + "/livez")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,253 @@
+package backup
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"gopark/config"
+	"gopark/internal/db"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// File name layout: gopark-20061018T150405.000Z.db[.gz]
+const (
+	filePrefix = "gopark-"
+	timeLayout = "20060102T150405.000Z"
+	dbSuffix   = ".db"
+	gzSuffix   = ".gz"
+)
+
+// Backup describes a backup file on disk
+type Backup struct {
+	Name       string    `json:"name"`
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	Compressed bool      `json:"compressed"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Manager writes, lists and prunes backups of the live database
+type Manager struct {
+	db    *db.DB
+	cfg   config.BackupConfig
+	cfgMu sync.RWMutex // Guards cfg, which may be replaced by a config reload
+	log   *logrus.Logger
+	mu    sync.Mutex // Serializes backups triggered by the API, CLI and scheduler
+}
+
+// NewManager creates a backup manager for dbConn
+func NewManager(dbConn *db.DB, cfg config.BackupConfig, log *logrus.Logger) *Manager {
+	return &Manager{db: dbConn, cfg: cfg, log: log}
+}
+
+// SetConfig replaces the retention and compression settings; backups already
+// in progress finish with the previous settings
+func (m *Manager) SetConfig(cfg config.BackupConfig) {
+	m.cfgMu.Lock()
+	defer m.cfgMu.Unlock()
+	m.cfg = cfg
+}
+
+// config returns the settings currently in effect
+func (m *Manager) config() config.BackupConfig {
+	m.cfgMu.RLock()
+	defer m.cfgMu.RUnlock()
+	return m.cfg
+}
+
+// Create takes a hot backup, verifies it, optionally compresses it and applies retention
+func (m *Manager) Create(ctx context.Context) (*Backup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cfg := m.config()
+
+	if err := os.MkdirAll(cfg.Dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	createdAt := time.Now().UTC()
+	name := filePrefix + createdAt.Format(timeLayout) + dbSuffix
+	finalPath := filepath.Join(cfg.Dir, name)
+	tmpPath := finalPath + ".tmp"
+	defer os.Remove(tmpPath)
+	if _, err := os.Stat(finalPath); err == nil {
+		return nil, fmt.Errorf("backup %s already exists", finalPath)
+	}
+
+	m.log.Infof("Starting database backup to %s", finalPath)
+	if err := m.db.BackupTo(ctx, tmpPath); err != nil {
+		return nil, err
+	}
+
+	if err := db.CheckIntegrity(ctx, tmpPath); err != nil {
+		return nil, fmt.Errorf("backup failed verification: %w", err)
+	}
+
+	if cfg.Compress {
+		gzPath := tmpPath + gzSuffix
+		defer os.Remove(gzPath)
+		if err := compressFile(tmpPath, gzPath); err != nil {
+			return nil, err
+		}
+		tmpPath = gzPath
+		finalPath += gzSuffix
+	}
+
+	if err := os.Rename(tmpPath, finalPath); err != nil {
+		return nil, fmt.Errorf("failed to finalize backup: %w", err)
+	}
+
+	info, err := os.Stat(finalPath)
+	if err != nil {
+		return nil, err
+	}
+
+	backup := &Backup{
+		Name:       filepath.Base(finalPath),
+		Path:       finalPath,
+		Size:       info.Size(),
+		Compressed: cfg.Compress,
+		CreatedAt:  createdAt,
+	}
+	m.log.Infof("Database backup %s completed (%d bytes)", backup.Name, backup.Size)
+
+	if err := m.prune(); err != nil {
+		m.log.Errorf("Failed to apply backup retention: %v", err)
+	}
+	return backup, nil
+}
+
+// List returns the backups in the configured directory, newest first
+func (m *Manager) List() ([]Backup, error) {
+	dir := m.config().Dir
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []Backup{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []Backup{}
+	for _, entry := range entries {
+		createdAt, compressed, ok := parseName(entry.Name())
+		if entry.IsDir() || !ok {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, Backup{
+			Name:       entry.Name(),
+			Path:       filepath.Join(dir, entry.Name()),
+			Size:       info.Size(),
+			Compressed: compressed,
+			CreatedAt:  createdAt,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+// prune deletes backups beyond the retention count or older than the maximum
+// age. A retention count of 0 keeps any number of backups
+func (m *Manager) prune() error {
+	backups, err := m.List()
+	if err != nil {
+		return err
+	}
+
+	cfg := m.config()
+	cutoff := time.Time{}
+	if cfg.MaxAge > 0 {
+		cutoff = time.Now().Add(-cfg.MaxAge)
+	}
+
+	for i, backup := range backups {
+		if (cfg.Retain == 0 || i < cfg.Retain) && backup.CreatedAt.After(cutoff) {
+			continue
+		}
+		if err := os.Remove(backup.Path); err != nil {
+			return err
+		}
+		m.log.Infof("Removed expired backup %s", backup.Name)
+	}
+	return nil
+}
+
+// parseName extracts the creation time from a backup file name
+func parseName(name string) (time.Time, bool, bool) {
+	compressed := strings.HasSuffix(name, gzSuffix)
+	trimmed := strings.TrimSuffix(name, gzSuffix)
+	if !strings.HasPrefix(trimmed, filePrefix) || !strings.HasSuffix(trimmed, dbSuffix) {
+		return time.Time{}, false, false
+	}
+
+	stamp := strings.TrimSuffix(strings.TrimPrefix(trimmed, filePrefix), dbSuffix)
+	createdAt, err := time.Parse(timeLayout, stamp)
+	if err != nil {
+		return time.Time{}, false, false
+	}
+	return createdAt, compressed, true
+}
+
+// compressFile gzips src into dest
+func compressFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		return fmt.Errorf("failed to compress backup: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress backup: %w", err)
+	}
+	return out.Sync()
+}
+
+// decompressFile gunzips src into dest
+func decompressFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return fmt.Errorf("failed to read compressed backup: %w", err)
+	}
+	defer gz.Close()
+
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, gz); err != nil {
+		return fmt.Errorf("failed to decompress backup: %w", err)
+	}
+	return out.Sync()
+}
@@ -0,0 +1,129 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"gopark/config"
+	"gopark/internal/db"
+	"gopark/internal/models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupDB creates a migrated temporary database containing one extra user
+func setupDB(t *testing.T) (*db.DB, string, *logrus.Logger) {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(bytes.NewBuffer(nil)) // Disable logging output
+
+	var cfg config.Config
+	cfg.Database.Path = filepath.Join(t.TempDir(), "gopark.db")
+	dbConn, err := db.NewDB(cfg, log)
+	require.NoError(t, err)
+	t.Cleanup(dbConn.Close)
+
+	migrations := db.NewMigrationManager(dbConn, log)
+	require.NoError(t, migrations.RunMigrations(context.Background(), "../migrations"))
+	require.NoError(t, dbConn.CreateUser(context.Background(), &models.User{Name: "Backup User", Mail: "backup@example.com"}))
+	return dbConn, cfg.Database.Path, log
+}
+
+// TestCreateAndRestore exercises a full backup and restore round trip
+func TestCreateAndRestore(t *testing.T) {
+	dbConn, _, log := setupDB(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	manager := NewManager(dbConn, config.BackupConfig{Dir: dir, Retain: 2, Compress: true}, log)
+
+	// Test case 1: a compressed backup is written and listed
+	created, err := manager.Create(ctx)
+	require.NoError(t, err)
+	assert.True(t, created.Compressed)
+	assert.FileExists(t, created.Path)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files must not be left behind")
+
+	backups, err := manager.List()
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.Equal(t, created.Name, backups[0].Name)
+
+	// Test case 2: retention keeps only the newest backups
+	for i := 0; i < 2; i++ {
+		time.Sleep(2 * time.Millisecond)
+		_, err := manager.Create(ctx)
+		require.NoError(t, err)
+	}
+	backups, err = manager.List()
+	require.NoError(t, err)
+	assert.Len(t, backups, 2)
+	assert.NoFileExists(t, created.Path)
+
+	// Test case 3: restoring produces a database with the backed-up rows
+	target := filepath.Join(t.TempDir(), "restored.db")
+	require.NoError(t, os.WriteFile(target+"-wal", []byte("stale"), 0640))
+	require.NoError(t, Restore(ctx, backups[0].Path, target, "../migrations", log))
+	assert.NoFileExists(t, target+"-wal")
+
+	var cfg config.Config
+	cfg.Database.Path = target
+	restored, err := db.NewDB(cfg, log)
+	require.NoError(t, err)
+	defer restored.Close()
+	users, err := restored.SearchUsersByName(ctx, "Backup User")
+	require.NoError(t, err)
+	assert.Len(t, users, 1)
+}
+
+// TestRetainAll verifies a retention count of 0 keeps every backup
+func TestRetainAll(t *testing.T) {
+	dbConn, _, log := setupDB(t)
+	manager := NewManager(dbConn, config.BackupConfig{Dir: t.TempDir(), Retain: 0}, log)
+
+	for i := 0; i < 3; i++ {
+		time.Sleep(2 * time.Millisecond)
+		created, err := manager.Create(context.Background())
+		require.NoError(t, err)
+		assert.FileExists(t, created.Path)
+	}
+	backups, err := manager.List()
+	require.NoError(t, err)
+	assert.Len(t, backups, 3)
+}
+
+// TestRestoreValidation verifies unsafe restores are rejected before files are swapped
+func TestRestoreValidation(t *testing.T) {
+	dbConn, _, log := setupDB(t)
+	ctx := context.Background()
+	manager := NewManager(dbConn, config.BackupConfig{Dir: t.TempDir(), Retain: 1}, log)
+	created, err := manager.Create(ctx)
+	require.NoError(t, err)
+	assert.False(t, created.Compressed)
+
+	target := filepath.Join(t.TempDir(), "restored.db")
+	require.NoError(t, os.WriteFile(target, []byte("original"), 0640))
+
+	// Test case 1: a backup from a newer schema is refused
+	olderBuild := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(olderBuild, "001_create_users_table.sql"), nil, 0640))
+	err = Restore(ctx, created.Path, target, olderBuild, log)
+	assert.ErrorContains(t, err, "unknown migrations")
+
+	// Test case 2: a corrupt backup is refused
+	corrupt := filepath.Join(t.TempDir(), "gopark-corrupt.db")
+	require.NoError(t, os.WriteFile(corrupt, bytes.Repeat([]byte("x"), 4096), 0640))
+	assert.Error(t, Restore(ctx, corrupt, target, "../migrations", log))
+
+	// The original database file is untouched after failed restores
+	content, err := os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "original", string(content))
+}
@@ -0,0 +1,105 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"gopark/internal/db"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Restore replaces the database at dbPath with the backup at src. The server
+// must be stopped; the backup is verified and its schema_migrations versions
+// must all be known to this build before any file is swapped
+func Restore(ctx context.Context, src, dbPath, migrationsDir string, log *logrus.Logger) error {
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("backup %s not found: %w", src, err)
+	}
+
+	stagePath := dbPath + ".restore"
+	os.Remove(stagePath)
+	defer os.Remove(stagePath)
+
+	if strings.HasSuffix(src, gzSuffix) {
+		if err := decompressFile(src, stagePath); err != nil {
+			return err
+		}
+	} else if err := copyFile(src, stagePath); err != nil {
+		return err
+	}
+
+	if err := db.CheckIntegrity(ctx, stagePath); err != nil {
+		return fmt.Errorf("backup failed verification: %w", err)
+	}
+
+	if err := CheckSchemaVersion(ctx, stagePath, migrationsDir); err != nil {
+		return err
+	}
+
+	// Remove WAL side files first so SQLite cannot replay stale frames onto the restored file
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s%s: %w", dbPath, suffix, err)
+		}
+	}
+
+	if err := os.Rename(stagePath, dbPath); err != nil {
+		return fmt.Errorf("failed to swap restored database into place: %w", err)
+	}
+
+	log.Infof("Database %s restored from %s", dbPath, src)
+	return nil
+}
+
+// CheckSchemaVersion verifies every migration applied in the database file is
+// shipped in migrationsDir, i.e. the file was not produced by a newer build
+func CheckSchemaVersion(ctx context.Context, path, migrationsDir string) error {
+	applied, err := db.AppliedMigrations(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	available, err := db.AvailableMigrations(migrationsDir)
+	if err != nil {
+		return fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+
+	known := make(map[string]bool, len(available))
+	for _, version := range available {
+		known[version] = true
+	}
+
+	var unknown []string
+	for _, version := range applied {
+		if !known[version] {
+			unknown = append(unknown, version)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("backup schema is newer than this build; unknown migrations: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// copyFile copies src into a new file at dest
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return fmt.Errorf("failed to copy backup: %w", err)
+	}
+	return out.Sync()
+}
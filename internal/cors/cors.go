@@ -0,0 +1,227 @@
+// Package cors decides which cross-origin requests browsers may make to the
+// public routes, from policies that can be replaced while serving
+package cors
+
+import (
+	"gopark/config"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Request and response headers of the CORS protocol
+const (
+	headerOrigin           = "Origin"
+	headerRequestMethod    = "Access-Control-Request-Method"
+	headerRequestHeaders   = "Access-Control-Request-Headers"
+	headerAllowOrigin      = "Access-Control-Allow-Origin"
+	headerAllowCredentials = "Access-Control-Allow-Credentials"
+	headerAllowMethods     = "Access-Control-Allow-Methods"
+	headerAllowHeaders     = "Access-Control-Allow-Headers"
+	headerExposeHeaders    = "Access-Control-Expose-Headers"
+	headerMaxAge           = "Access-Control-Max-Age"
+)
+
+// Policies holds the default policy and the route overrides. They are
+// swapped as a whole, so a request never sees a mix of two configurations
+type Policies struct {
+	current atomic.Pointer[policySet]
+}
+
+// policySet is one compiled configuration
+type policySet struct {
+	def    *Policy
+	routes []route // Longest prefix first
+}
+
+// route applies a policy below a path prefix
+type route struct {
+	prefix string
+	policy *Policy
+}
+
+// New compiles cfg, which must have passed config.Validate
+func New(cfg config.CORSConfig) *Policies {
+	p := &Policies{}
+	p.SetConfig(cfg)
+	return p
+}
+
+// SetConfig replaces the policies; requests already in flight keep the
+// policy they started with
+func (p *Policies) SetConfig(cfg config.CORSConfig) {
+	set := &policySet{def: compile(cfg.Default)}
+	for _, r := range cfg.Routes {
+		set.routes = append(set.routes, route{prefix: strings.TrimSuffix(r.Prefix, "/"), policy: compile(r.CORSPolicy)})
+	}
+	sort.SliceStable(set.routes, func(i, j int) bool { return len(set.routes[i].prefix) > len(set.routes[j].prefix) })
+	p.current.Store(set)
+}
+
+// Match returns the policy of the longest route prefix containing path, or
+// the default policy. Prefixes match whole segments: /api matches /api and
+// /api/users but not /apis
+func (p *Policies) Match(path string) *Policy {
+	set := p.current.Load()
+	for _, r := range set.routes {
+		if path == r.prefix || strings.HasPrefix(path, r.prefix+"/") {
+			return r.policy
+		}
+	}
+	return set.def
+}
+
+// Policy is a compiled config.CORSPolicy
+type Policy struct {
+	anyOrigin   bool
+	origins     []*regexp.Regexp
+	methods     map[string]bool
+	methodList  string
+	anyHeader   bool
+	headers     map[string]bool // Lower-case names
+	exposed     string
+	credentials bool
+	maxAge      string // Seconds; empty to omit the header
+}
+
+// compile prepares cfg for matching. Invalid patterns are skipped; they are
+// rejected by config.Validate
+func compile(cfg config.CORSPolicy) *Policy {
+	p := &Policy{
+		methods:     make(map[string]bool),
+		headers:     make(map[string]bool),
+		exposed:     strings.Join(cfg.ExposedHeaders, ", "),
+		credentials: cfg.AllowCredentials,
+	}
+	for _, pattern := range cfg.AllowedOrigins {
+		if pattern == "*" {
+			p.anyOrigin = true
+		} else if re, err := config.ParseOrigin(pattern); err == nil {
+			p.origins = append(p.origins, re)
+		}
+	}
+
+	methods := cfg.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead, http.MethodPost} // CORS-safelisted methods
+	}
+	for _, method := range methods {
+		p.methods[method] = true
+	}
+	p.methodList = strings.Join(methods, ", ")
+
+	for _, header := range cfg.AllowedHeaders {
+		if header == "*" {
+			p.anyHeader = true
+		}
+		p.headers[strings.ToLower(header)] = true
+	}
+	if seconds := int(cfg.MaxAge.Seconds()); seconds > 0 {
+		p.maxAge = strconv.Itoa(seconds)
+	}
+	return p
+}
+
+// Enabled reports whether the policy allows any origin at all
+func (p *Policy) Enabled() bool {
+	return p.anyOrigin || len(p.origins) > 0
+}
+
+// AllowOrigin reports whether scripts on origin may call the routes
+func (p *Policy) AllowOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if p.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	for _, re := range p.origins {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// variesByOrigin reports whether the CORS headers of a response depend on
+// the Origin request header, which caches must then key responses by. A
+// policy allowing any origin without credentials always answers *
+func (p *Policy) variesByOrigin() bool {
+	return p.Enabled() && !(p.anyOrigin && !p.credentials)
+}
+
+// IsPreflight reports whether r is a CORS preflight request, which asks
+// whether the actual request may be sent rather than being one
+func IsPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get(headerOrigin) != "" && r.Header.Get(headerRequestMethod) != ""
+}
+
+// Actual adds the headers that let the browser expose the response of r to
+// a script on its origin, and Vary: Origin whenever they depend on it, even
+// if r has no Origin header
+func (p *Policy) Actual(r *http.Request, h http.Header) {
+	if p.variesByOrigin() {
+		h.Add("Vary", headerOrigin)
+	}
+	origin := r.Header.Get(headerOrigin)
+	if !p.AllowOrigin(origin) {
+		return
+	}
+	p.allowOrigin(origin, h)
+	if p.exposed != "" {
+		h.Set(headerExposeHeaders, p.exposed)
+	}
+}
+
+// Preflight answers the preflight request r and reports whether its origin,
+// method and headers are all allowed. Only then are the allow headers set
+func (p *Policy) Preflight(r *http.Request, h http.Header) bool {
+	h.Add("Vary", headerOrigin)
+	h.Add("Vary", headerRequestMethod)
+	h.Add("Vary", headerRequestHeaders)
+
+	origin := r.Header.Get(headerOrigin)
+	if !p.AllowOrigin(origin) || !p.methods[r.Header.Get(headerRequestMethod)] {
+		return false
+	}
+	var requested []string
+	for _, value := range r.Header.Values(headerRequestHeaders) {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				requested = append(requested, name)
+			}
+		}
+	}
+	for _, name := range requested {
+		// * is a literal header name for requests with credentials
+		if !p.headers[name] && !(p.anyHeader && !p.credentials) {
+			return false
+		}
+	}
+
+	p.allowOrigin(origin, h)
+	h.Set(headerAllowMethods, p.methodList)
+	if len(requested) > 0 {
+		h.Set(headerAllowHeaders, strings.Join(requested, ", "))
+	}
+	if p.maxAge != "" {
+		h.Set(headerMaxAge, p.maxAge)
+	}
+	return true
+}
+
+// allowOrigin sets the origin and credentials headers for an allowed origin
+func (p *Policy) allowOrigin(origin string, h http.Header) {
+	if p.anyOrigin && !p.credentials {
+		h.Set(headerAllowOrigin, "*")
+		return
+	}
+	h.Set(headerAllowOrigin, origin)
+	if p.credentials {
+		h.Set(headerAllowCredentials, "true")
+	}
+}
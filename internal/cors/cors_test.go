@@ -0,0 +1,152 @@
+package cors
+
+import (
+	"gopark/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// request builds a request from origin, with preflight headers when method is set
+func request(path, origin, method, headers string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	if method != "" {
+		r.Method = http.MethodOptions
+		r.Header.Set("Access-Control-Request-Method", method)
+	}
+	if headers != "" {
+		r.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	return r
+}
+
+// TestAllowOrigin checks the origin pattern forms
+func TestAllowOrigin(t *testing.T) {
+	p := compile(config.CORSPolicy{AllowedOrigins: []string{
+		"https://app.example.com",
+		"https://*.example.org",
+		`regex:https://pr-\d+\.preview\.example\.net`,
+	}})
+
+	// Test case 1: exact origins match whole origins, ignoring case
+	assert.True(t, p.AllowOrigin("https://app.example.com"))
+	assert.True(t, p.AllowOrigin("HTTPS://APP.example.com"))
+	assert.False(t, p.AllowOrigin("http://app.example.com"))
+	assert.False(t, p.AllowOrigin("https://app.example.com:8443"))
+	assert.False(t, p.AllowOrigin("https://app.example.com.evil.test"))
+
+	// Test case 2: wildcards match one or more subdomain labels only
+	assert.True(t, p.AllowOrigin("https://a.example.org"))
+	assert.True(t, p.AllowOrigin("https://a.b.example.org"))
+	assert.False(t, p.AllowOrigin("https://example.org"))
+	assert.False(t, p.AllowOrigin("https://evilexample.org"))
+
+	// Test case 3: expressions are anchored
+	assert.True(t, p.AllowOrigin("https://pr-42.preview.example.net"))
+	assert.False(t, p.AllowOrigin("https://pr-42.preview.example.net.evil.test"))
+
+	// Test case 4: requests without an origin are not cross-origin
+	assert.False(t, p.AllowOrigin(""))
+	assert.False(t, compile(config.CORSPolicy{}).Enabled())
+}
+
+// TestActual checks the headers of non-preflight responses
+func TestActual(t *testing.T) {
+	// Test case 1: any origin without credentials answers * and does not vary
+	p := compile(config.CORSPolicy{AllowedOrigins: []string{"*"}, ExposedHeaders: []string{"X-Request-ID"}})
+	h := http.Header{}
+	p.Actual(request("/", "https://a.test", "", ""), h)
+	assert.Equal(t, "*", h.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "X-Request-ID", h.Get("Access-Control-Expose-Headers"))
+	assert.Empty(t, h.Get("Access-Control-Allow-Credentials"))
+	assert.Empty(t, h.Values("Vary"))
+
+	// Test case 2: listed origins are echoed with credentials
+	p = compile(config.CORSPolicy{AllowedOrigins: []string{"https://a.test"}, AllowCredentials: true})
+	h = http.Header{}
+	p.Actual(request("/", "https://a.test", "", ""), h)
+	assert.Equal(t, "https://a.test", h.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", h.Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, []string{"Origin"}, h.Values("Vary"))
+
+	// Test case 3: responses to other or missing origins still vary by origin
+	for _, origin := range []string{"https://b.test", ""} {
+		h = http.Header{}
+		p.Actual(request("/", origin, "", ""), h)
+		assert.Empty(t, h.Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, []string{"Origin"}, h.Values("Vary"))
+	}
+}
+
+// TestPreflight checks that preflights validate the method and headers
+func TestPreflight(t *testing.T) {
+	p := compile(config.CORSPolicy{
+		AllowedOrigins: []string{"https://a.test"},
+		AllowedMethods: []string{"GET", "PUT"},
+		AllowedHeaders: []string{"Content-Type", "X-Request-ID"},
+		MaxAge:         10 * time.Minute,
+	})
+
+	// Test case 1: allowed requests get the allow headers
+	h := http.Header{}
+	r := request("/", "https://a.test", "PUT", "content-type, x-request-id")
+	assert.True(t, IsPreflight(r))
+	assert.True(t, p.Preflight(r, h))
+	assert.Equal(t, "https://a.test", h.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, PUT", h.Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "content-type, x-request-id", h.Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", h.Get("Access-Control-Max-Age"))
+	assert.Equal(t, []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}, h.Values("Vary"))
+
+	// Test case 2: an unlisted origin, method or header is refused without allow headers
+	for _, r := range []*http.Request{
+		request("/", "https://b.test", "PUT", ""),
+		request("/", "https://a.test", "DELETE", ""),
+		request("/", "https://a.test", "PUT", "content-type, x-debug"),
+	} {
+		h = http.Header{}
+		assert.False(t, p.Preflight(r, h))
+		assert.Empty(t, h.Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, h.Get("Access-Control-Allow-Methods"))
+	}
+
+	// Test case 3: without listed methods only the safelisted ones are allowed
+	p = compile(config.CORSPolicy{AllowedOrigins: []string{"*"}, AllowedHeaders: []string{"*"}})
+	assert.True(t, p.Preflight(request("/", "https://b.test", "POST", "x-anything"), http.Header{}))
+	assert.False(t, p.Preflight(request("/", "https://b.test", "PATCH", ""), http.Header{}))
+
+	// Test case 4: plain OPTIONS requests are not preflights
+	assert.False(t, IsPreflight(request("/", "", "PUT", "")))
+	r = request("/", "https://a.test", "", "")
+	r.Method = http.MethodOptions
+	assert.False(t, IsPreflight(r))
+}
+
+// TestPolicies checks route overrides and replacing the configuration
+func TestPolicies(t *testing.T) {
+	policies := New(config.CORSConfig{
+		Default: config.CORSPolicy{AllowedOrigins: []string{"*"}},
+		Routes: []config.CORSRoute{
+			{Prefix: "/api", CORSPolicy: config.CORSPolicy{AllowedOrigins: []string{"https://api.test"}}},
+			{Prefix: "/api/v1/admin/", CORSPolicy: config.CORSPolicy{}},
+		},
+	})
+
+	// Test case 1: the longest prefix matching whole segments wins
+	assert.True(t, policies.Match("/health").AllowOrigin("https://any.test"))
+	assert.True(t, policies.Match("/apis").AllowOrigin("https://any.test"))
+	assert.True(t, policies.Match("/api").AllowOrigin("https://api.test"))
+	assert.False(t, policies.Match("/api/v1/users").AllowOrigin("https://any.test"))
+	assert.False(t, policies.Match("/api/v1/admin/jobs").Enabled())
+
+	// Test case 2: new configurations replace every policy
+	policies.SetConfig(config.CORSConfig{Default: config.CORSPolicy{AllowedOrigins: []string{"https://new.test"}}})
+	assert.False(t, policies.Match("/health").AllowOrigin("https://any.test"))
+	assert.True(t, policies.Match("/api/v1/admin/jobs").AllowOrigin("https://new.test"))
+}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+	"golang.org/x/net/context"
+)
+
+// BackupTo copies the live database to destPath through the SQLite online
+// backup API; the copy is page-for-page consistent even while writers are active
+func (db *DB) BackupTo(ctx context.Context, destPath string) error {
+	if _, err := os.Stat(destPath); err == nil {
+		return fmt.Errorf("backup destination %s already exists", destPath)
+	}
+
+	destDB, err := sql.Open("sqlite3", destPath)
+	if err != nil {
+		return fmt.Errorf("unable to open backup destination: %w", err)
+	}
+	defer destDB.Close()
+
+	destConn, err := destDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to connect to backup destination: %w", err)
+	}
+	defer destConn.Close()
+
+	srcConn, err := db.DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to acquire source connection: %w", err)
+	}
+	defer srcConn.Close()
+
+	err = destConn.Raw(func(destDriver interface{}) error {
+		return srcConn.Raw(func(srcDriver interface{}) error {
+			dest, ok := destDriver.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("backup destination is not a SQLite connection")
+			}
+			src, ok := srcDriver.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("backup source is not a SQLite connection")
+			}
+
+			backup, err := dest.Backup("main", src, "main")
+			if err != nil {
+				return fmt.Errorf("unable to start backup: %w", err)
+			}
+
+			// Copy everything in one step; in WAL mode this only holds a read snapshot
+			if _, err := backup.Step(-1); err != nil {
+				backup.Finish()
+				return fmt.Errorf("backup step failed: %w", err)
+			}
+			return backup.Finish()
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	// The copy inherits WAL mode from the source; switch it back so the file
+	// is self-contained and opening it never leaves -wal/-shm files behind
+	if _, err := destConn.ExecContext(ctx, "PRAGMA journal_mode = DELETE"); err != nil {
+		return fmt.Errorf("unable to finalize backup: %w", err)
+	}
+	return nil
+}
+
+// DisableWAL switches the database file at path to rollback journal mode,
+// folding in and removing any -wal/-shm files
+func DisableWAL(ctx context.Context, path string) error {
+	sqlDB, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %w", path, err)
+	}
+	defer sqlDB.Close()
+
+	if _, err := sqlDB.ExecContext(ctx, "PRAGMA journal_mode = DELETE"); err != nil {
+		return fmt.Errorf("unable to change journal mode of %s: %w", path, err)
+	}
+	return nil
+}
+
+// CheckIntegrity runs PRAGMA integrity_check against the database file at path
+func CheckIntegrity(ctx context.Context, path string) error {
+	sqlDB, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %w", path, err)
+	}
+	defer sqlDB.Close()
+
+	rows, err := sqlDB.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return fmt.Errorf("integrity check failed: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return err
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check reported problems: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// AppliedMigrations lists the versions recorded in schema_migrations of the database file at path
+func AppliedMigrations(ctx context.Context, path string) ([]string, error) {
+	sqlDB, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %w", path, err)
+	}
+	defer sqlDB.Close()
+
+	rows, err := sqlDB.QueryContext(ctx, "SELECT version FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, fmt.Errorf("unable to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []string
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
@@ -4,18 +4,37 @@ import (
 	"database/sql"
 	"fmt"
 	"gopark/config"
+	"gopark/internal/logging"
 	"os"
 	"path/filepath"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
 )
 
+// replicatedDriver opens connections with automatic checkpoints disabled so
+// that only the WAL replicator checkpoints, after it has shipped every frame
+const replicatedDriver = "sqlite3_replicated"
+
+func init() {
+	sql.Register(replicatedDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			_, err := conn.Exec("PRAGMA wal_autocheckpoint = 0", nil)
+			return err
+		},
+	})
+}
+
 // DB holds the database connection
 type DB struct {
-	DB  *sql.DB
-	Log *logrus.Logger
+	DB   *sql.DB
+	Log  *logrus.Logger
+	Path string // Database file path
+
+	queries *prometheus.HistogramVec // Statement latency, once RegisterMetrics is called
 }
 
 // NewDB initializes a new database connection
@@ -28,8 +47,14 @@ func NewDB(cfg config.Config, log *logrus.Logger) (*DB, error) {
 		}
 	}
 
-	// Open the SQLite database
-	sqlDB, err := sql.Open("sqlite3", cfg.Database.Path)
+	// Open the SQLite database in WAL mode so background workers can write
+	// while requests read; the busy timeout absorbs short lock contention
+	dsn := cfg.Database.Path + "?_journal_mode=WAL&_busy_timeout=5000"
+	driver := "sqlite3"
+	if cfg.Replication.Enabled {
+		driver = replicatedDriver
+	}
+	sqlDB, err := sql.Open(driver, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("unable to open database: %w", err)
 	}
@@ -45,8 +70,9 @@ func NewDB(cfg config.Config, log *logrus.Logger) (*DB, error) {
 	sqlDB.SetMaxIdleConns(5)
 
 	db := &DB{
-		DB:  sqlDB,
-		Log: log,
+		DB:   sqlDB,
+		Log:  log,
+		Path: cfg.Database.Path,
 	}
 
 	log.Infof("Database connection established to %s", cfg.Database.Path)
@@ -61,17 +87,53 @@ func (db *DB) Close() {
 	}
 }
 
+// logger returns the request-scoped logger in ctx, or the database's logger
+// for background work
+func (db *DB) logger(ctx context.Context) *logrus.Entry {
+	return logging.FromContext(ctx, db.Log)
+}
+
+// Ping verifies that the database file still exists and answers a query
+func (db *DB) Ping(ctx context.Context) error {
+	if _, err := os.Stat(db.Path); err != nil {
+		return fmt.Errorf("database file: %w", err)
+	}
+	var one int
+	return db.DB.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+}
+
 // ExecContext executes a query without returning any rows
 func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
-	return db.DB.ExecContext(ctx, query, args...)
+	ctx, done := db.instrument(ctx, query)
+	result, err := db.DB.ExecContext(ctx, query, args...)
+	done(err)
+	return result, err
 }
 
-// QueryContext executes a query that returns rows
+// QueryContext executes a query that returns rows. The query is timed until
+// the first rows are ready, not until they are read
 func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
-	return db.DB.QueryContext(ctx, query, args...)
+	ctx, done := db.instrument(ctx, query)
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	done(err)
+	return rows, err
 }
 
 // QueryRowContext executes a query that returns a single row
 func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
-	return db.DB.QueryRowContext(ctx, query, args...)
+	ctx, done := db.instrument(ctx, query)
+	row := db.DB.QueryRowContext(ctx, query, args...)
+	done(row.Err())
+	return row
+}
+
+// instrument times query for the metrics and traces it as a child of the
+// span in ctx; the returned function records the outcome
+func (db *DB) instrument(ctx context.Context, query string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := startSpan(ctx, query)
+	return ctx, func(err error) {
+		db.observe(query, start, err)
+		endSpan(span, err)
+	}
 }
@@ -0,0 +1,25 @@
+package db
+
+import (
+	"golang.org/x/net/context"
+)
+
+// Vacuum rebuilds the database file, reclaiming free pages
+func (db *DB) Vacuum(ctx context.Context) error {
+	if _, err := db.ExecContext(ctx, "VACUUM"); err != nil {
+		db.logger(ctx).Errorf("Failed to vacuum database: %v", err)
+		return err
+	}
+	db.logger(ctx).Info("Database vacuum completed")
+	return nil
+}
+
+// Analyze refreshes the query planner statistics
+func (db *DB) Analyze(ctx context.Context) error {
+	if _, err := db.ExecContext(ctx, "ANALYZE"); err != nil {
+		db.logger(ctx).Errorf("Failed to analyze database: %v", err)
+		return err
+	}
+	db.logger(ctx).Info("Database analyze completed")
+	return nil
+}
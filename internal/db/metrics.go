@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// sqlComment matches line comments, which lead migration scripts
+var sqlComment = regexp.MustCompile(`--[^\n]*`)
+
+// RegisterMetrics exposes the connection pool statistics, the latency of
+// each statement and the applied migration version through reg. It must be
+// called before the database is used concurrently
+func (db *DB) RegisterMetrics(reg prometheus.Registerer) error {
+	queries := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "gopark",
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Database statement latency by statement kind and table, e.g. \"select users\".",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"statement", "result"})
+	migration := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "gopark",
+		Subsystem: "db",
+		Name:      "migration_version",
+		Help:      "Numeric version of the latest applied migration, 0 before the first.",
+	}, db.migrationVersion)
+
+	for _, c := range []prometheus.Collector{collectors.NewDBStatsCollector(db.DB, "gopark"), queries, migration} {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+	db.queries = queries
+	return nil
+}
+
+// observe records the latency of query since start
+func (db *DB) observe(query string, start time.Time, err error) {
+	if db.queries == nil {
+		return
+	}
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	db.queries.WithLabelValues(statement(query), result).Observe(time.Since(start).Seconds())
+}
+
+// statement reduces a query to its verb and first table for use as a label
+func statement(query string) string {
+	fields := strings.Fields(sqlComment.ReplaceAllString(query, ""))
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	verb := strings.ToLower(fields[0])
+	for i, field := range fields[:len(fields)-1] {
+		switch strings.ToUpper(field) {
+		case "FROM", "INTO", "UPDATE", "TABLE", "EXISTS":
+			table := strings.ToLower(strings.Trim(fields[i+1], "();`\"'"))
+			if table != "" && table != "if" && table != "not" {
+				return verb + " " + table
+			}
+		}
+	}
+	return verb
+}
+
+// migrationVersion returns the numeric prefix of the latest applied migration
+func (db *DB) migrationVersion() float64 {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	rows, err := db.DB.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return 0
+	}
+	defer rows.Close()
+	latest := 0
+	for rows.Next() {
+		var version string
+		if rows.Scan(&version) != nil {
+			return 0
+		}
+		prefix, _, _ := strings.Cut(version, "_")
+		if n, err := strconv.Atoi(prefix); err == nil && n > latest {
+			latest = n
+		}
+	}
+	return float64(latest)
+}
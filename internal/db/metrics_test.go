@@ -0,0 +1,82 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"gopark/config"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStatement verifies queries are reduced to bounded labels
+func TestStatement(t *testing.T) {
+	tests := map[string]string{
+		"SELECT id, name FROM users WHERE id = ?":                "select users",
+		"SELECT COUNT(*) FROM jobs WHERE status = ?":             "select jobs",
+		"INSERT INTO users (name, mail) VALUES (?, ?)":           "insert users",
+		"UPDATE jobs SET status = ? WHERE id = ?":                "update jobs",
+		"delete from scheduler_leases where name = ?":            "delete scheduler_leases",
+		"-- create users\nCREATE TABLE IF NOT EXISTS users (id)": "create users",
+		"PRAGMA wal_checkpoint(TRUNCATE)":                        "pragma",
+		"  ":                                                     "unknown",
+	}
+	for query, want := range tests {
+		assert.Equal(t, want, statement(query), query)
+	}
+}
+
+// TestRegisterMetrics covers the pool, query and migration metrics
+func TestRegisterMetrics(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(bytes.NewBuffer(nil)) // Disable logging output
+	ctx := context.Background()
+
+	var cfg config.Config
+	cfg.Database.Path = filepath.Join(t.TempDir(), "gopark.db")
+	dbConn, err := NewDB(cfg, log)
+	require.NoError(t, err)
+	t.Cleanup(dbConn.Close)
+	reg := prometheus.NewRegistry()
+	require.NoError(t, dbConn.RegisterMetrics(reg))
+
+	// Test case 1: the migration version is 0 before migrating
+	expected := `
+		# HELP gopark_db_migration_version Numeric version of the latest applied migration, 0 before the first.
+		# TYPE gopark_db_migration_version gauge
+		gopark_db_migration_version 0
+	`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "gopark_db_migration_version"))
+
+	// Test case 2: migrations and queries are observed
+	require.NoError(t, NewMigrationManager(dbConn, log).RunMigrations(ctx, copyMigrations(t)))
+	_, err = dbConn.GetUserByID(ctx, 1)
+	require.NoError(t, err)
+	expected = `
+		# HELP gopark_db_migration_version Numeric version of the latest applied migration, 0 before the first.
+		# TYPE gopark_db_migration_version gauge
+		gopark_db_migration_version 4
+	`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "gopark_db_migration_version"))
+	count, err := testutil.GatherAndCount(reg, "gopark_db_query_duration_seconds", "go_sql_open_connections")
+	require.NoError(t, err)
+	assert.Greater(t, count, 2) // Several statements and the pool gauge
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	var statements []string
+	for _, family := range families {
+		if family.GetName() != "gopark_db_query_duration_seconds" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			statements = append(statements, m.GetLabel()[1].GetValue()+" "+m.GetLabel()[0].GetValue())
+		}
+	}
+	assert.Contains(t, statements, "select users ok")
+}
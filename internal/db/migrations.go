@@ -2,14 +2,33 @@ package db
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+// downSuffix marks the file that reverts the migration of the same version
+const downSuffix = ".down.sql"
+
+// migrationName restricts names passed to CreateMigration
+var migrationName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// MigrationStatus describes one migration version
+type MigrationStatus struct {
+	Version    string     `json:"version"`
+	Applied    bool       `json:"applied"`
+	AppliedAt  *time.Time `json:"applied_at,omitempty"`
+	Reversible bool       `json:"reversible"` // A .down.sql file exists
+	Missing    bool       `json:"missing"`    // Applied but no longer shipped in the migrations directory
+}
+
 // MigrationManager handles database migrations
 type MigrationManager struct {
 	DB  *DB
@@ -74,6 +93,38 @@ func (m *MigrationManager) recordMigration(ctx context.Context, version string)
 	return nil
 }
 
+// listMigrationFiles returns the sorted SQL file names in migrationsDir
+func listMigrationFiles(migrationsDir string) ([]string, error) {
+	files, err := os.ReadDir(migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Filter and sort SQL files; down migrations are paired by version
+	var migrations []string
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".sql") && !strings.HasSuffix(file.Name(), downSuffix) {
+			migrations = append(migrations, file.Name())
+		}
+	}
+	sort.Strings(migrations)
+	return migrations, nil
+}
+
+// AvailableMigrations returns the migration versions shipped in migrationsDir
+func AvailableMigrations(migrationsDir string) ([]string, error) {
+	files, err := listMigrationFiles(migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]string, 0, len(files))
+	for _, file := range files {
+		versions = append(versions, strings.TrimSuffix(file, filepath.Ext(file)))
+	}
+	return versions, nil
+}
+
 // RunMigrations applies all pending migrations
 func (m *MigrationManager) RunMigrations(ctx context.Context, migrationsDir string) error {
 	// Ensure the migrations table exists
@@ -88,21 +139,12 @@ func (m *MigrationManager) RunMigrations(ctx context.Context, migrationsDir stri
 	}
 
 	// Read migration files
-	files, err := os.ReadDir(migrationsDir)
+	migrations, err := listMigrationFiles(migrationsDir)
 	if err != nil {
 		m.Log.Errorf("Failed to read migrations directory: %v", err)
 		return err
 	}
 
-	// Filter and sort SQL files
-	var migrations []string
-	for _, file := range files {
-		if !file.IsDir() && strings.HasSuffix(file.Name(), ".sql") {
-			migrations = append(migrations, file.Name())
-		}
-	}
-	sort.Strings(migrations)
-
 	// Apply migrations
 	for _, migration := range migrations {
 		// Extract the version (filename prefix)
@@ -141,3 +183,118 @@ func (m *MigrationManager) RunMigrations(ctx context.Context, migrationsDir stri
 
 	return nil
 }
+
+// Status lists every shipped or applied migration in version order
+func (m *MigrationManager) Status(ctx context.Context, migrationsDir string) ([]MigrationStatus, error) {
+	if err := m.ensureMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+
+	available, err := AvailableMigrations(migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[string]*MigrationStatus)
+	for _, version := range available {
+		_, err := os.Stat(filepath.Join(migrationsDir, version+downSuffix))
+		byVersion[version] = &MigrationStatus{Version: version, Reversible: err == nil}
+	}
+
+	rows, err := m.DB.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version string
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		status, ok := byVersion[version]
+		if !ok {
+			status = &MigrationStatus{Version: version, Missing: true}
+			byVersion[version] = status
+		}
+		status.Applied = true
+		status.AppliedAt = &appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(byVersion))
+	for _, status := range byVersion {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Rollback reverts the most recently applied migrations, newest first, using
+// their .down.sql files; it stops at the first migration that cannot be reverted
+func (m *MigrationManager) Rollback(ctx context.Context, migrationsDir string, steps int) ([]string, error) {
+	statuses, err := m.Status(ctx, migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []string
+	for i := len(statuses) - 1; i >= 0 && len(reverted) < steps; i-- {
+		status := statuses[i]
+		if !status.Applied {
+			continue
+		}
+		if !status.Reversible {
+			return reverted, fmt.Errorf("migration %s has no %s file", status.Version, downSuffix)
+		}
+
+		content, err := os.ReadFile(filepath.Join(migrationsDir, status.Version+downSuffix))
+		if err != nil {
+			return reverted, err
+		}
+
+		m.Log.Infof("Reverting migration %s", status.Version)
+		if _, err := m.DB.ExecContext(ctx, string(content)); err != nil {
+			m.Log.Errorf("Failed to revert migration %s: %v", status.Version, err)
+			return reverted, err
+		}
+		if _, err := m.DB.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?;`, status.Version); err != nil {
+			return reverted, err
+		}
+		m.Log.Infof("Successfully reverted migration %s", status.Version)
+		reverted = append(reverted, status.Version)
+	}
+	return reverted, nil
+}
+
+// CreateMigration writes empty up and down files for the next version in
+// migrationsDir and returns their paths
+func CreateMigration(migrationsDir, name string) (string, string, error) {
+	if !migrationName.MatchString(name) {
+		return "", "", fmt.Errorf("invalid migration name %q (use lowercase letters, digits and underscores)", name)
+	}
+
+	versions, err := AvailableMigrations(migrationsDir)
+	if err != nil {
+		return "", "", err
+	}
+	next := 1
+	for _, version := range versions {
+		prefix, _, _ := strings.Cut(version, "_")
+		if n, err := strconv.Atoi(prefix); err == nil && n >= next {
+			next = n + 1
+		}
+	}
+
+	version := fmt.Sprintf("%03d_%s", next, name)
+	up := filepath.Join(migrationsDir, version+".sql")
+	down := filepath.Join(migrationsDir, version+downSuffix)
+	if err := os.WriteFile(up, []byte("-- "+strings.ReplaceAll(name, "_", " ")+"\n"), 0644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(down, []byte("-- Revert "+strings.ReplaceAll(name, "_", " ")+"\n"), 0644); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
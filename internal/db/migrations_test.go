@@ -0,0 +1,87 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"gopark/config"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// copyMigrations copies the shipped migrations into a temporary directory
+func copyMigrations(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files, err := filepath.Glob("../migrations/*.sql")
+	require.NoError(t, err)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, filepath.Base(file)), data, 0644))
+	}
+	return dir
+}
+
+// TestMigrationLifecycle covers status, rollback and creation of migrations
+func TestMigrationLifecycle(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(bytes.NewBuffer(nil)) // Disable logging output
+	ctx := context.Background()
+
+	var cfg config.Config
+	cfg.Database.Path = filepath.Join(t.TempDir(), "gopark.db")
+	dbConn, err := NewDB(cfg, log)
+	require.NoError(t, err)
+	t.Cleanup(dbConn.Close)
+
+	dir := copyMigrations(t)
+	manager := NewMigrationManager(dbConn, log)
+
+	// Test case 1: down files are not listed as migrations
+	statuses, err := manager.Status(ctx, dir)
+	require.NoError(t, err)
+	require.Len(t, statuses, 4)
+	for _, status := range statuses {
+		assert.False(t, status.Applied)
+		assert.True(t, status.Reversible)
+	}
+
+	// Test case 2: applied migrations report their time
+	require.NoError(t, manager.RunMigrations(ctx, dir))
+	statuses, err = manager.Status(ctx, dir)
+	require.NoError(t, err)
+	assert.True(t, statuses[3].Applied)
+	assert.NotNil(t, statuses[3].AppliedAt)
+
+	// Test case 3: rollback reverts the newest migrations first
+	reverted, err := manager.Rollback(ctx, dir, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"004_add_jobs_request_id", "003_create_scheduler_leases_table", "002_create_jobs_table"}, reverted)
+	_, err = dbConn.ExecContext(ctx, "SELECT 1 FROM jobs")
+	assert.Error(t, err, "jobs table must be dropped")
+	require.NoError(t, manager.RunMigrations(ctx, dir))
+
+	// Test case 4: created migrations take the next version and are pending
+	up, down, err := CreateMigration(dir, "add_user_roles")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "005_add_user_roles.sql"), up)
+	assert.FileExists(t, down)
+	statuses, err = manager.Status(ctx, dir)
+	require.NoError(t, err)
+	require.Len(t, statuses, 5)
+	assert.False(t, statuses[4].Applied)
+
+	_, _, err = CreateMigration(dir, "Bad Name")
+	assert.Error(t, err)
+
+	// Test case 5: a migration without a down file stops the rollback
+	require.NoError(t, os.Remove(down))
+	require.NoError(t, manager.RunMigrations(ctx, dir))
+	_, err = manager.Rollback(ctx, dir, 1)
+	assert.Error(t, err)
+}
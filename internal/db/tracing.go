@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Literals replaced by sanitize
+var (
+	sqlString = regexp.MustCompile(`'(?:[^']|'')*'`)
+	sqlNumber = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+)
+
+// startSpan starts a client span for query as a child of the span in ctx.
+// Queries outside a trace, such as job polling, are not traced
+func startSpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	parent := trace.SpanFromContext(ctx)
+	if !parent.SpanContext().IsValid() {
+		return ctx, trace.SpanFromContext(context.Background()) // Ending it does nothing
+	}
+
+	name := statement(query)
+	operation, table, _ := strings.Cut(name, " ")
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemSqlite,
+		semconv.DBQueryText(sanitize(query)),
+		semconv.DBOperationName(operation),
+	}
+	if table != "" {
+		attrs = append(attrs, semconv.DBCollectionName(table))
+	}
+	return parent.TracerProvider().Tracer("gopark/internal/db").Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// endSpan records the outcome of a query and ends its span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// sanitize replaces literals in query with ? so that spans never carry user
+// data written into a statement, and collapses whitespace
+func sanitize(query string) string {
+	query = sqlComment.ReplaceAllString(query, "")
+	query = sqlString.ReplaceAllString(query, "?")
+	query = sqlNumber.ReplaceAllString(query, "?")
+	return strings.Join(strings.Fields(query), " ")
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"gopark/config"
+	"gopark/internal/models"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// TestSanitize verifies literals never reach span attributes
+func TestSanitize(t *testing.T) {
+	tests := map[string]string{
+		"SELECT id FROM users WHERE id = ?":                          "SELECT id FROM users WHERE id = ?",
+		"SELECT id FROM users WHERE mail = 'a@b.c' AND id > 10":      "SELECT id FROM users WHERE mail = ? AND id > ?",
+		"UPDATE users SET name = 'O''Brien', score = 1.5 WHERE id=7": "UPDATE users SET name = ?, score = ? WHERE id=?",
+		"-- seed\nINSERT INTO users2 (name)\n  VALUES ('x')":         "INSERT INTO users2 (name) VALUES (?)",
+	}
+	for query, want := range tests {
+		assert.Equal(t, want, sanitize(query), query)
+	}
+}
+
+// TestTracing verifies queries in a trace add child spans
+func TestTracing(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(bytes.NewBuffer(nil)) // Disable logging output
+
+	var cfg config.Config
+	cfg.Database.Path = filepath.Join(t.TempDir(), "gopark.db")
+	dbConn, err := NewDB(cfg, log)
+	require.NoError(t, err)
+	t.Cleanup(dbConn.Close)
+	_, err = dbConn.ExecContext(context.Background(), "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, mail TEXT)")
+	require.NoError(t, err)
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+
+	// Test case 1: queries outside a trace are not traced
+	require.NoError(t, dbConn.CreateUser(context.Background(), &models.User{Name: "alice", Mail: "alice@example.com"}))
+	assert.Empty(t, recorder.Ended())
+
+	// Test case 2: queries in a trace are children of its span
+	_, err = dbConn.GetUserByID(ctx, 1)
+	require.NoError(t, err)
+	_, err = dbConn.ExecContext(ctx, "DELETE FROM missing WHERE id = 1")
+	require.Error(t, err)
+	parent.End()
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	assert.Equal(t, "select users", spans[0].Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Contains(t, spans[0].Attributes(), semconv.DBSystemSqlite)
+	assert.Contains(t, spans[0].Attributes(), semconv.DBCollectionName("users"))
+
+	// Test case 3: failed queries are marked and their text is sanitized
+	assert.Equal(t, "delete missing", spans[1].Name())
+	assert.Equal(t, "Error", spans[1].Status().Code.String())
+	assert.Contains(t, spans[1].Attributes(), attribute.String(string(semconv.DBQueryTextKey), "DELETE FROM missing WHERE id = ?"))
+}
@@ -0,0 +1,125 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopark/internal/logging"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// asyncBuffer is the number of events an AsyncPublisher holds while the
+// broker is slow or unreachable
+const asyncBuffer = 1024
+
+// ErrPublisherBusy is returned by AsyncPublisher.Publish when its buffer is
+// full; the event is dropped
+var ErrPublisherBusy = errors.New("event buffer full")
+
+// ErrPublisherClosed is returned by Publish after Close
+var ErrPublisherClosed = errors.New("event publisher closed")
+
+// AsyncPublisher hands events to a background goroutine that delivers them
+// through another Publisher, including its retries, so that requests never
+// wait for the broker. Delivery failures are logged
+type AsyncPublisher struct {
+	next    Publisher
+	log     *logrus.Logger
+	pending chan pendingEvent
+	done    chan struct{}
+	stop    context.Context // Canceled when Shutdown gives up on the queued events
+	abort   context.CancelFunc
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// pendingEvent is an event waiting for delivery with the context it was
+// published with, minus the cancellation
+type pendingEvent struct {
+	ctx   context.Context
+	event Event
+}
+
+// NewAsyncPublisher starts delivering events through next
+func NewAsyncPublisher(next Publisher, log *logrus.Logger) *AsyncPublisher {
+	p := &AsyncPublisher{
+		next:    next,
+		log:     log,
+		pending: make(chan pendingEvent, asyncBuffer),
+		done:    make(chan struct{}),
+	}
+	p.stop, p.abort = context.WithCancel(context.Background())
+	go p.deliver()
+	return p
+}
+
+// Publish queues the event and returns at once. The context's values, such
+// as the request ID, reach the delivery; its cancellation does not, because
+// the request usually ends before the event is delivered
+func (p *AsyncPublisher) Publish(ctx context.Context, event Event) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrPublisherClosed
+	}
+	select {
+	case p.pending <- pendingEvent{ctx: context.WithoutCancel(ctx), event: event}:
+		return nil
+	default:
+		return ErrPublisherBusy
+	}
+}
+
+// deliver publishes the queued events in order until Shutdown
+func (p *AsyncPublisher) deliver() {
+	defer close(p.done)
+	dropped := 0
+	for pending := range p.pending {
+		if p.stop.Err() != nil {
+			dropped++
+			continue
+		}
+		ctx, cancel := context.WithCancel(pending.ctx)
+		stop := context.AfterFunc(p.stop, cancel)
+		if err := p.next.Publish(ctx, pending.event); err != nil && p.stop.Err() != nil {
+			dropped++
+		} else if err != nil {
+			logging.FromContext(ctx, p.log).Errorf("Failed to publish %s event: %v", pending.event.Type, err)
+		}
+		stop()
+		cancel()
+	}
+	if dropped > 0 {
+		p.log.Errorf("Dropped %d undelivered events on shutdown", dropped)
+	}
+}
+
+// Shutdown stops accepting events and delivers those already queued, then
+// closes the underlying publisher. When ctx ends first, the delivery in
+// progress is canceled and the rest are dropped
+func (p *AsyncPublisher) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.pending)
+	}
+	p.mu.Unlock()
+
+	var err error
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		p.abort()
+		<-p.done
+		err = fmt.Errorf("event delivery incomplete: %w", ctx.Err())
+	}
+	return errors.Join(err, p.next.Close())
+}
+
+// Close is Shutdown without a deadline
+func (p *AsyncPublisher) Close() error {
+	return p.Shutdown(context.Background())
+}
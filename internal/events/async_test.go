@@ -0,0 +1,84 @@
+package events
+
+import (
+	"context"
+	"gopark/internal/requestid"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingPublisher records events, holding each delivery until release is
+// closed or its context ends
+type blockingPublisher struct {
+	release   chan struct{}
+	delivered chan Event
+	requestID chan string
+	closed    bool
+}
+
+func newBlockingPublisher() *blockingPublisher {
+	return &blockingPublisher{release: make(chan struct{}), delivered: make(chan Event, 10), requestID: make(chan string, 10)}
+}
+
+func (p *blockingPublisher) Publish(ctx context.Context, event Event) error {
+	select {
+	case <-p.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	p.requestID <- requestid.FromContext(ctx)
+	p.delivered <- event
+	return nil
+}
+
+func (p *blockingPublisher) Close() error {
+	p.closed = true
+	return nil
+}
+
+// TestAsyncPublisher checks that publishing never waits for the broker
+func TestAsyncPublisher(t *testing.T) {
+	// Test case 1: Publish returns while the broker is stuck, and the event is
+	// delivered with the request ID after the request context has ended
+	next := newBlockingPublisher()
+	publisher := NewAsyncPublisher(next, testLogger())
+	ctx, cancel := context.WithCancel(requestid.NewContext(context.Background(), "req-1"))
+	start := time.Now()
+	require.NoError(t, publisher.Publish(ctx, NewEvent(UserCreated, nil)))
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+	cancel()
+	close(next.release)
+	select {
+	case event := <-next.delivered:
+		assert.Equal(t, UserCreated, event.Type)
+		assert.Equal(t, "req-1", <-next.requestID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not delivered")
+	}
+
+	// Test case 2: Shutdown delivers queued events, closes the broker and
+	// rejects later events
+	require.NoError(t, publisher.Publish(context.Background(), NewEvent(UserUpdated, nil)))
+	require.NoError(t, publisher.Close())
+	assert.Equal(t, UserUpdated, (<-next.delivered).Type)
+	assert.True(t, next.closed)
+	assert.ErrorIs(t, publisher.Publish(context.Background(), NewEvent(UserDeleted, nil)), ErrPublisherClosed)
+
+	// Test case 3: a full buffer drops events instead of blocking
+	next = newBlockingPublisher()
+	publisher = NewAsyncPublisher(next, testLogger())
+	var err error
+	for i := 0; i <= asyncBuffer+1 && err == nil; i++ {
+		err = publisher.Publish(context.Background(), NewEvent(UserCreated, nil))
+	}
+	assert.ErrorIs(t, err, ErrPublisherBusy)
+
+	// Test case 4: Shutdown gives up on a stuck broker when its context ends
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, publisher.Shutdown(ctx), context.DeadlineExceeded)
+	assert.True(t, next.closed)
+}
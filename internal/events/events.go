@@ -0,0 +1,81 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"gopark/config"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Event types emitted by gopark
+const (
+	UserCreated = "user.created"
+	UserUpdated = "user.updated"
+	UserDeleted = "user.deleted"
+)
+
+// Event describes a domain event delivered to external consumers
+type Event struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	Source     string      `json:"source"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	RequestID  string      `json:"request_id,omitempty"` // Request that caused the event; set by Publish from its context
+	Data       interface{} `json:"data"`
+}
+
+// NewEvent builds an event with a generated ID and the current timestamp
+func NewEvent(eventType string, data interface{}) Event {
+	return Event{
+		ID:         newEventID(),
+		Type:       eventType,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+}
+
+// Publisher delivers events to a message broker
+type Publisher interface {
+	// Publish sends the event. NATSPublisher blocks until the broker
+	// confirms it; AsyncPublisher returns once it is queued
+	Publish(ctx context.Context, event Event) error
+	// Close releases the broker connection
+	Close() error
+}
+
+// NopPublisher discards all events; used when publishing is disabled
+type NopPublisher struct{}
+
+// Publish implements Publisher
+func (NopPublisher) Publish(ctx context.Context, event Event) error { return nil }
+
+// Close implements Publisher
+func (NopPublisher) Close() error { return nil }
+
+// NewPublisher creates the publisher selected by the events configuration
+func NewPublisher(cfg config.Config, log *logrus.Logger) (Publisher, error) {
+	if !cfg.Events.Enabled {
+		log.Info("Event publishing disabled")
+		return NopPublisher{}, nil
+	}
+
+	switch cfg.Events.Driver {
+	case "nats":
+		return NewNATSPublisher(cfg.Events.NATS, cfg.AppName, log)
+	default:
+		return nil, fmt.Errorf("unsupported events driver %q", cfg.Events.Driver)
+	}
+}
+
+// newEventID returns a random 128-bit hex identifier
+func newEventID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
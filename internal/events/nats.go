@@ -0,0 +1,174 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gopark/config"
+	"gopark/internal/requestid"
+	"text/template"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/sirupsen/logrus"
+)
+
+// NATSPublisher publishes events to a NATS JetStream stream
+type NATSPublisher struct {
+	cfg      config.NATSConfig
+	source   string
+	conn     *nats.Conn
+	js       jetstream.JetStream
+	fallback *template.Template
+	subjects map[string]*template.Template
+	log      *logrus.Logger
+}
+
+// subjectData is the data available to subject templates
+type subjectData struct {
+	Type   string
+	ID     string
+	Source string
+}
+
+// NewNATSPublisher connects to NATS and prepares the JetStream stream
+func NewNATSPublisher(cfg config.NATSConfig, source string, log *logrus.Logger) (*NATSPublisher, error) {
+	fallback, err := template.New("subject").Option("missingkey=error").Parse(cfg.SubjectTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject template %q: %w", cfg.SubjectTemplate, err)
+	}
+
+	subjects := make(map[string]*template.Template, len(cfg.Subjects))
+	for _, route := range cfg.Subjects {
+		tmpl, err := template.New(route.Type).Option("missingkey=error").Parse(route.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid subject template for %s: %w", route.Type, err)
+		}
+		subjects[route.Type] = tmpl
+	}
+
+	opts := []nats.Option{nats.Name(source)}
+	if cfg.Token != "" {
+		opts = append(opts, nats.Token(cfg.Token))
+	}
+	conn, err := nats.Connect(cfg.URL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to NATS: %w", err)
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to create JetStream context: %w", err)
+	}
+
+	p := &NATSPublisher{
+		cfg:      cfg,
+		source:   source,
+		conn:     conn,
+		js:       js,
+		fallback: fallback,
+		subjects: subjects,
+		log:      log,
+	}
+
+	if cfg.CreateStream {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.AckTimeout)
+		defer cancel()
+		_, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+			Name:     cfg.Stream,
+			Subjects: cfg.StreamSubjects,
+		})
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("unable to create stream %s: %w", cfg.Stream, err)
+		}
+	}
+
+	log.Infof("Event publisher connected to NATS at %s (stream %s)", conn.ConnectedUrl(), cfg.Stream)
+	return p, nil
+}
+
+// Subject renders the subject an event is published on
+func (p *NATSPublisher) Subject(event Event) (string, error) {
+	tmpl, ok := p.subjects[event.Type]
+	if !ok {
+		tmpl = p.fallback
+	}
+
+	var buf bytes.Buffer
+	data := subjectData{Type: event.Type, ID: event.ID, Source: p.source}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("unable to render subject for %s: %w", event.Type, err)
+	}
+	return buf.String(), nil
+}
+
+// Publish sends the event and retries until JetStream acknowledges it
+func (p *NATSPublisher) Publish(ctx context.Context, event Event) error {
+	if event.Source == "" {
+		event.Source = p.source
+	}
+	if event.RequestID == "" {
+		event.RequestID = requestid.FromContext(ctx)
+	}
+
+	subject, err := p.Subject(event)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("unable to encode event %s: %w", event.ID, err)
+	}
+
+	msg := &nats.Msg{
+		Subject: subject,
+		Data:    payload,
+		Header:  nats.Header{},
+	}
+	msg.Header.Set("Gopark-Event-Type", event.Type)
+	if event.RequestID != "" {
+		msg.Header.Set(requestid.Header, event.RequestID)
+	}
+
+	backoff := p.cfg.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		// The message ID lets JetStream drop duplicates when an ack was lost and we retry
+		ackCtx, cancel := context.WithTimeout(ctx, p.cfg.AckTimeout)
+		ack, err := p.js.PublishMsg(ackCtx, msg, jetstream.WithMsgID(event.ID))
+		cancel()
+		if err == nil {
+			if ack.Duplicate {
+				p.log.Debugf("Event %s already stored in stream %s", event.ID, ack.Stream)
+			}
+			p.log.Debugf("Published event %s to %s (stream %s, seq %d)", event.ID, subject, ack.Stream, ack.Sequence)
+			return nil
+		}
+
+		if attempt >= p.cfg.MaxRetries {
+			return fmt.Errorf("unable to publish event %s to %s after %d attempts: %w", event.ID, subject, attempt+1, err)
+		}
+
+		p.log.Warnf("Publishing event %s to %s failed (attempt %d): %v", event.ID, subject, attempt+1, err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("publishing event %s canceled: %w", event.ID, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// Close drains pending messages and closes the NATS connection
+func (p *NATSPublisher) Close() error {
+	if err := p.conn.Drain(); err != nil {
+		p.conn.Close()
+		return err
+	}
+	p.log.Info("Event publisher connection closed")
+	return nil
+}
@@ -0,0 +1,147 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"gopark/config"
+	"gopark/internal/requestid"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runServer starts an in-process NATS server with JetStream enabled
+func runServer(t *testing.T) *natsserver.Server {
+	t.Helper()
+	srv, err := natsserver.NewServer(&natsserver.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	require.NoError(t, err)
+
+	go srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server did not start")
+	}
+	t.Cleanup(srv.Shutdown)
+	return srv
+}
+
+// testConfig returns a NATS configuration pointing at the embedded server
+func testConfig(url string) config.NATSConfig {
+	return config.NATSConfig{
+		URL:             url,
+		Stream:          "TEST_EVENTS",
+		StreamSubjects:  []string{"gopark.>"},
+		CreateStream:    true,
+		SubjectTemplate: "gopark.{{.Type}}",
+		Subjects: []config.SubjectRoute{
+			{Type: UserDeleted, Template: "gopark.users.deleted"},
+		},
+		MaxRetries:   3,
+		RetryBackoff: 50 * time.Millisecond,
+		AckTimeout:   time.Second,
+	}
+}
+
+func testLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(bytes.NewBuffer(nil)) // Disable logging output
+	return log
+}
+
+// TestNATSPublisher exercises publishing through JetStream
+func TestNATSPublisher(t *testing.T) {
+	srv := runServer(t)
+	publisher, err := NewNATSPublisher(testConfig(srv.ClientURL()), "gopark-test", testLogger())
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	// Test case 1: subjects follow the default and per-type templates
+	t.Run("Subject Templates", func(t *testing.T) {
+		subject, err := publisher.Subject(NewEvent(UserCreated, nil))
+		assert.NoError(t, err)
+		assert.Equal(t, "gopark.user.created", subject)
+
+		subject, err = publisher.Subject(NewEvent(UserDeleted, nil))
+		assert.NoError(t, err)
+		assert.Equal(t, "gopark.users.deleted", subject)
+	})
+
+	// Test case 2: published events are stored in the stream
+	t.Run("Publish", func(t *testing.T) {
+		event := NewEvent(UserCreated, map[string]string{"name": "Test User"})
+		require.NoError(t, publisher.Publish(requestid.NewContext(context.Background(), "req-1"), event))
+
+		stream, err := publisher.js.Stream(context.Background(), "TEST_EVENTS")
+		require.NoError(t, err)
+		stored, err := stream.GetLastMsgForSubject(context.Background(), "gopark.user.created")
+		require.NoError(t, err)
+
+		var decoded Event
+		require.NoError(t, json.Unmarshal(stored.Data, &decoded))
+		assert.Equal(t, event.ID, decoded.ID)
+		assert.Equal(t, "gopark-test", decoded.Source)
+		assert.Equal(t, UserCreated, stored.Header.Get("Gopark-Event-Type"))
+		assert.Equal(t, "req-1", decoded.RequestID)
+		assert.Equal(t, "req-1", stored.Header.Get(requestid.Header))
+	})
+
+	// Test case 3: republishing the same event is deduplicated by JetStream
+	t.Run("Duplicate", func(t *testing.T) {
+		event := NewEvent(UserUpdated, nil)
+		require.NoError(t, publisher.Publish(context.Background(), event))
+		require.NoError(t, publisher.Publish(context.Background(), event))
+
+		stream, err := publisher.js.Stream(context.Background(), "TEST_EVENTS")
+		require.NoError(t, err)
+		info, err := stream.Info(context.Background(), jetstream.WithSubjectFilter("gopark.user.updated"))
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), info.State.Subjects["gopark.user.updated"])
+	})
+}
+
+// TestNATSPublisherRetry verifies unacknowledged publishes are retried
+func TestNATSPublisherRetry(t *testing.T) {
+	srv := runServer(t)
+	cfg := testConfig(srv.ClientURL())
+	cfg.CreateStream = false
+
+	publisher, err := NewNATSPublisher(cfg, "gopark-test", testLogger())
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	// Test case 1: no stream ever acknowledges the message
+	t.Run("Exhausted", func(t *testing.T) {
+		err := publisher.Publish(context.Background(), NewEvent(UserCreated, nil))
+		assert.ErrorContains(t, err, "after 4 attempts")
+	})
+
+	// Test case 2: the stream appears while the publisher is backing off
+	t.Run("Recovered", func(t *testing.T) {
+		conn, err := nats.Connect(srv.ClientURL())
+		require.NoError(t, err)
+		defer conn.Close()
+		js, err := jetstream.New(conn)
+		require.NoError(t, err)
+
+		go func() {
+			time.Sleep(60 * time.Millisecond)
+			js.CreateStream(context.Background(), jetstream.StreamConfig{Name: "TEST_EVENTS", Subjects: []string{"gopark.>"}})
+		}()
+
+		err = publisher.Publish(context.Background(), NewEvent(UserCreated, nil))
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"gopark/internal/backup"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// BackupHandler handles database backup requests
+type BackupHandler struct {
+	log     *logrus.Logger
+	manager *backup.Manager
+}
+
+// NewBackupHandler creates a new BackupHandler instance
+func NewBackupHandler(log *logrus.Logger, manager *backup.Manager) *BackupHandler {
+	return &BackupHandler{log: log, manager: manager}
+}
+
+// CreateBackup handles POST requests to take a hot database backup
+// @Summary      Create backup
+// @Description  Take an online backup of the database, verify it and apply retention
+// @Tags         admin
+// @Produce      json
+// @Success      201  {object}  backup.Backup
+// @Failure      500  {object}  handlers.ErrorResponse
+// @Router       /admin/backups [post]
+func (h *BackupHandler) CreateBackup(c *gin.Context) {
+	requestLogger(c, h.log).Info("Handling CreateBackup request")
+	created, err := h.manager.Create(c.Request.Context())
+	if err != nil {
+		requestLogger(c, h.log).Errorf("Failed to create backup: %v", err)
+		InternalServerError(c, "Failed to create backup", h.log)
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// ListBackups handles GET requests to list available backups
+// @Summary      List backups
+// @Description  List database backups, newest first
+// @Tags         admin
+// @Produce      json
+// @Success      200  {array}   backup.Backup
+// @Failure      500  {object}  handlers.ErrorResponse
+// @Router       /admin/backups [get]
+func (h *BackupHandler) ListBackups(c *gin.Context) {
+	requestLogger(c, h.log).Info("Handling ListBackups request")
+	backups, err := h.manager.List()
+	if err != nil {
+		requestLogger(c, h.log).Errorf("Failed to list backups: %v", err)
+		InternalServerError(c, "Failed to list backups", h.log)
+		return
+	}
+
+	c.JSON(http.StatusOK, backups)
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"gopark/internal/logging"
+	"gopark/internal/requestid"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -9,13 +11,21 @@ import (
 
 // ErrorResponse defines the common error payload
 type ErrorResponse struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"` // Quote it when reporting the error
 }
 
-// RespondWithError sends a consistent error response
+// requestLogger returns the request-scoped logger stored by the logging
+// middleware, or log when there is none
+func requestLogger(c *gin.Context, log *logrus.Logger) *logrus.Entry {
+	return logging.FromContext(c.Request.Context(), log)
+}
+
+// RespondWithError sends a consistent error response, logged with the
+// request's fields
 func RespondWithError(c *gin.Context, statusCode int, message string, log *logrus.Logger) {
-	log.WithFields(logrus.Fields{
+	requestLogger(c, log).WithFields(logrus.Fields{
 		"status_code": statusCode,
 		"error":       message,
 		"path":        c.Request.URL.Path,
@@ -23,8 +33,9 @@ func RespondWithError(c *gin.Context, statusCode int, message string, log *logru
 	}).Error("Request error")
 
 	c.JSON(statusCode, ErrorResponse{
-		Code:    statusCode,
-		Message: message,
+		Code:      statusCode,
+		Message:   message,
+		RequestID: requestid.FromContext(c.Request.Context()),
 	})
 }
 
@@ -1,12 +1,56 @@
 package handlers
 
 import (
+	"gopark/internal/health"
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 )
 
-// HealthCheckHandler handles the health check request
-func HealthCheckHandler(c *gin.Context) {
-	c.JSON(200, gin.H{
-		"status": "ok",
-	})
+// HealthHandler handles liveness and readiness probes
+type HealthHandler struct {
+	registry *health.Registry
+	verbose  bool // Include every check result, for the admin listener
+}
+
+// NewHealthHandler creates a new HealthHandler instance. Unless verbose, only
+// the overall status is returned, so check errors are not exposed publicly
+func NewHealthHandler(registry *health.Registry, verbose bool) *HealthHandler {
+	return &HealthHandler{registry: registry, verbose: verbose}
+}
+
+// Livez handles liveness probes
+// @Summary      Liveness probe
+// @Description  Report whether the process is alive; 503 means it should be restarted
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  health.Report
+// @Failure      503  {object}  health.Report
+// @Router       /livez [get]
+func (h *HealthHandler) Livez(c *gin.Context) {
+	h.respond(c, h.registry.Liveness(c.Request.Context()))
+}
+
+// Readyz handles readiness probes, also served as /health
+// @Summary      Readiness probe
+// @Description  Report whether the service can take requests; 503 while a dependency fails or during shutdown
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  health.Report
+// @Failure      503  {object}  health.Report
+// @Router       /readyz [get]
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	h.respond(c, h.registry.Readiness(c.Request.Context()))
+}
+
+// respond writes report with 200 when healthy and 503 otherwise
+func (h *HealthHandler) respond(c *gin.Context, report health.Report) {
+	status := http.StatusOK
+	if !report.Healthy() {
+		status = http.StatusServiceUnavailable
+	}
+	if !h.verbose {
+		report.Checks = nil
+	}
+	c.JSON(status, report)
 }
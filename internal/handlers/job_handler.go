@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"errors"
+	"gopark/internal/jobs"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// JobHandler handles background job administration requests
+type JobHandler struct {
+	log   *logrus.Logger
+	queue *jobs.Queue
+}
+
+// NewJobHandler creates a new JobHandler instance
+func NewJobHandler(log *logrus.Logger, queue *jobs.Queue) *JobHandler {
+	return &JobHandler{log: log, queue: queue}
+}
+
+// ListJobs handles GET requests to list background jobs
+// @Summary      List jobs
+// @Description  List background jobs, optionally filtered by status
+// @Tags         admin
+// @Produce      json
+// @Param        status   query     string  false  "Job status (pending, running, succeeded, failed, canceled)"
+// @Param        limit    query     int     false  "Items per page"
+// @Param        offset   query     int     false  "Result offset"
+// @Success      200  {array}   jobs.Job
+// @Failure      400  {object}  handlers.ErrorResponse
+// @Failure      500  {object}  handlers.ErrorResponse
+// @Router       /admin/jobs [get]
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	requestLogger(c, h.log).Info("Handling ListJobs request")
+
+	status := jobs.Status(c.Query("status"))
+	switch status {
+	case "", jobs.StatusPending, jobs.StatusRunning, jobs.StatusSucceeded, jobs.StatusFailed, jobs.StatusCanceled:
+	default:
+		BadRequest(c, "Invalid status parameter", h.log)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil {
+		BadRequest(c, "Invalid limit parameter", h.log)
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		BadRequest(c, "Invalid offset parameter", h.log)
+		return
+	}
+
+	list, err := h.queue.List(c.Request.Context(), status, limit, offset)
+	if err != nil {
+		requestLogger(c, h.log).Errorf("Failed to list jobs: %v", err)
+		InternalServerError(c, "Failed to list jobs", h.log)
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// GetJob handles GET requests to retrieve a single job
+// @Summary      Get job
+// @Description  Retrieve a background job by ID
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "Job ID"
+// @Success      200  {object}  jobs.Job
+// @Failure      400  {object}  handlers.ErrorResponse
+// @Failure      404  {object}  handlers.ErrorResponse
+// @Router       /admin/jobs/{id} [get]
+func (h *JobHandler) GetJob(c *gin.Context) {
+	requestLogger(c, h.log).Info("Handling GetJob request")
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.queue.Get(c.Request.Context(), id)
+	if err != nil {
+		h.respondWithJobError(c, err, "Failed to retrieve job")
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// RetryJob handles POST requests to requeue a failed or canceled job
+// @Summary      Retry job
+// @Description  Requeue a failed or canceled job with a fresh set of attempts
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "Job ID"
+// @Success      200  {object}  jobs.Job
+// @Failure      400  {object}  handlers.ErrorResponse
+// @Failure      404  {object}  handlers.ErrorResponse
+// @Failure      409  {object}  handlers.ErrorResponse
+// @Router       /admin/jobs/{id}/retry [post]
+func (h *JobHandler) RetryJob(c *gin.Context) {
+	requestLogger(c, h.log).Info("Handling RetryJob request")
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.queue.Retry(c.Request.Context(), id); err != nil {
+		h.respondWithJobError(c, err, "Failed to retry job")
+		return
+	}
+
+	h.respondWithJob(c, id)
+}
+
+// CancelJob handles POST requests to cancel a pending or running job
+// @Summary      Cancel job
+// @Description  Cancel a pending or running job
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "Job ID"
+// @Success      200  {object}  jobs.Job
+// @Failure      400  {object}  handlers.ErrorResponse
+// @Failure      404  {object}  handlers.ErrorResponse
+// @Failure      409  {object}  handlers.ErrorResponse
+// @Router       /admin/jobs/{id}/cancel [post]
+func (h *JobHandler) CancelJob(c *gin.Context) {
+	requestLogger(c, h.log).Info("Handling CancelJob request")
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.queue.Cancel(c.Request.Context(), id); err != nil {
+		h.respondWithJobError(c, err, "Failed to cancel job")
+		return
+	}
+
+	h.respondWithJob(c, id)
+}
+
+// parseID reads the job ID path parameter, responding with 400 when invalid
+func (h *JobHandler) parseID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		requestLogger(c, h.log).Errorf("Invalid ID format: %v", err)
+		BadRequest(c, "Invalid ID format", h.log)
+		return 0, false
+	}
+	return id, true
+}
+
+// respondWithJob writes the current state of a job
+func (h *JobHandler) respondWithJob(c *gin.Context, id int64) {
+	job, err := h.queue.Get(c.Request.Context(), id)
+	if err != nil {
+		h.respondWithJobError(c, err, "Failed to retrieve job")
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// respondWithJobError maps queue errors to HTTP responses
+func (h *JobHandler) respondWithJobError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		NotFound(c, "Job not found", h.log)
+	case errors.Is(err, jobs.ErrInvalidState), errors.Is(err, jobs.ErrDuplicate):
+		RespondWithError(c, http.StatusConflict, err.Error(), h.log)
+	default:
+		requestLogger(c, h.log).Errorf("%s: %v", message, err)
+		InternalServerError(c, message, h.log)
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"gopark/internal/scheduler"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// SchedulerHandler handles scheduler status requests
+type SchedulerHandler struct {
+	log       *logrus.Logger
+	scheduler *scheduler.Scheduler
+}
+
+// NewSchedulerHandler creates a new SchedulerHandler instance
+func NewSchedulerHandler(log *logrus.Logger, scheduler *scheduler.Scheduler) *SchedulerHandler {
+	return &SchedulerHandler{log: log, scheduler: scheduler}
+}
+
+// GetStatus handles GET requests for scheduled task status
+// @Summary      Scheduler status
+// @Description  List scheduled tasks with their last run, next run and counters
+// @Tags         admin
+// @Produce      json
+// @Success      200  {array}  scheduler.TaskStatus
+// @Router       /admin/scheduler [get]
+func (h *SchedulerHandler) GetStatus(c *gin.Context) {
+	requestLogger(c, h.log).Info("Handling GetSchedulerStatus request")
+	c.JSON(http.StatusOK, h.scheduler.Status())
+}
@@ -2,24 +2,80 @@ package handlers
 
 import (
 	"gopark/internal/db"
+	"gopark/internal/events"
+	"gopark/internal/logging"
 	"gopark/internal/models"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/context"
 )
 
+// UserStore persists users; *db.DB is the SQLite implementation
+type UserStore interface {
+	GetUserByID(ctx context.Context, id uint) (*models.User, error)
+	CreateUser(ctx context.Context, user *models.User) error
+	UpdateUser(ctx context.Context, user *models.User) error
+	DeleteUser(ctx context.Context, id uint) error
+	SearchUsersByName(ctx context.Context, namePattern string) ([]*models.User, error)
+	ListUsers(ctx context.Context, limit, offset int) ([]*models.User, error)
+}
+
+var _ UserStore = (*db.DB)(nil)
+
 // UserHandler handles user-related requests
 type UserHandler struct {
-	log *logrus.Logger
-	db  *db.DB
+	log    *logrus.Logger
+	db     UserStore
+	events events.Publisher
 }
 
 // NewUserHandler creates a new UserHandler instance
-func NewUserHandler(log *logrus.Logger, db *db.DB) *UserHandler {
-	return &UserHandler{log: log, db: db}
+func NewUserHandler(log *logrus.Logger, store UserStore, publisher events.Publisher) *UserHandler {
+	return &UserHandler{log: log, db: store, events: publisher}
+}
+
+// publishEvent emits a user event. The publisher delivers it in the
+// background, so failures are logged and never delay or fail the request
+func (h *UserHandler) publishEvent(ctx context.Context, eventType string, data interface{}) {
+	if h.events == nil {
+		return
+	}
+
+	event := events.NewEvent(eventType, data)
+	if err := h.events.Publish(ctx, event); err != nil {
+		logging.FromContext(ctx, h.log).Errorf("Failed to publish %s event: %v", eventType, err)
+	}
+}
+
+// setUserID adds the ID of the user the request operates on to the request's
+// logger, and so to the log lines of the store and the access log
+func (h *UserHandler) setUserID(c *gin.Context, id uint) {
+	c.Request = c.Request.WithContext(logging.AddFields(c.Request.Context(), h.log, logrus.Fields{"user_id": id}))
+}
+
+// bindUser decodes and validates the user in the request body, responding
+// with 400 when it is invalid
+func (h *UserHandler) bindUser(c *gin.Context, user *models.User) bool {
+	ctx := c.Request.Context()
+	_, span := trace.SpanFromContext(ctx).TracerProvider().Tracer("gopark/internal/handlers").Start(ctx, "validate user")
+	defer span.End()
+
+	if err := c.ShouldBindJSON(user); err != nil {
+		requestLogger(c, h.log).Errorf("Invalid request payload: %v", err)
+		BadRequest(c, "Invalid request payload", h.log)
+		return false
+	}
+
+	// Validate user data
+	if err := user.Validate(); err != nil {
+		BadRequest(c, err.Error(), h.log)
+		return false
+	}
+	return true
 }
 
 // GetUser handles GET requests to retrieve user information
@@ -35,7 +91,7 @@ func NewUserHandler(log *logrus.Logger, db *db.DB) *UserHandler {
 // @Failure      500  {object}  handlers.ErrorResponse
 // @Router       /users [get]
 func (h *UserHandler) GetUser(c *gin.Context) {
-	h.log.Info("Handling GetUser request")
+	requestLogger(c, h.log).Info("Handling GetUser request")
 	idParam := c.Query("id")
 	if idParam == "" {
 		BadRequest(c, "ID parameter is required", h.log)
@@ -44,14 +100,15 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 
 	id, err := strconv.ParseUint(idParam, 10, 32)
 	if err != nil {
-		h.log.Errorf("Invalid ID format: %v", err)
+		requestLogger(c, h.log).Errorf("Invalid ID format: %v", err)
 		BadRequest(c, "Invalid ID format", h.log)
 		return
 	}
+	h.setUserID(c, uint(id))
 
-	user, err := h.db.GetUserByID(context.Background(), uint(id))
+	user, err := h.db.GetUserByID(c.Request.Context(), uint(id))
 	if err != nil {
-		h.log.Errorf("Failed to retrieve user: %v", err)
+		requestLogger(c, h.log).Errorf("Failed to retrieve user: %v", err)
 		NotFound(c, "User not found", h.log)
 		return
 	}
@@ -71,26 +128,20 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 // @Failure      500   {object}  handlers.ErrorResponse
 // @Router       /users [post]
 func (h *UserHandler) CreateUser(c *gin.Context) {
-	h.log.Info("Handling CreateUser request")
+	requestLogger(c, h.log).Info("Handling CreateUser request")
 	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		h.log.Errorf("Invalid request payload: %v", err)
-		BadRequest(c, "Invalid request payload", h.log)
+	if !h.bindUser(c, &user) {
 		return
 	}
 
-	// Validate user data
-	if err := user.Validate(); err != nil {
-		BadRequest(c, err.Error(), h.log)
-		return
-	}
-
-	if err := h.db.CreateUser(context.Background(), &user); err != nil {
-		h.log.Errorf("Failed to create user: %v", err)
+	if err := h.db.CreateUser(c.Request.Context(), &user); err != nil {
+		requestLogger(c, h.log).Errorf("Failed to create user: %v", err)
 		InternalServerError(c, "Failed to create user", h.log)
 		return
 	}
 
+	h.setUserID(c, user.ID)
+	h.publishEvent(c.Request.Context(), events.UserCreated, user)
 	c.JSON(http.StatusCreated, user)
 }
 
@@ -107,35 +158,29 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 // @Failure      500   {object}  handlers.ErrorResponse
 // @Router       /users/{id} [put]
 func (h *UserHandler) UpdateUser(c *gin.Context) {
-	h.log.Info("Handling UpdateUser request")
+	requestLogger(c, h.log).Info("Handling UpdateUser request")
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 32)
 	if err != nil {
-		h.log.Errorf("Invalid ID format: %v", err)
+		requestLogger(c, h.log).Errorf("Invalid ID format: %v", err)
 		BadRequest(c, "Invalid ID format", h.log)
 		return
 	}
+	h.setUserID(c, uint(id))
 
 	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		h.log.Errorf("Invalid request payload: %v", err)
-		BadRequest(c, "Invalid request payload", h.log)
-		return
-	}
-
-	// Validate user data
-	if err := user.Validate(); err != nil {
-		BadRequest(c, err.Error(), h.log)
+	if !h.bindUser(c, &user) {
 		return
 	}
 
 	user.ID = uint(id)
-	if err := h.db.UpdateUser(context.Background(), &user); err != nil {
-		h.log.Errorf("Failed to update user: %v", err)
+	if err := h.db.UpdateUser(c.Request.Context(), &user); err != nil {
+		requestLogger(c, h.log).Errorf("Failed to update user: %v", err)
 		InternalServerError(c, "Failed to update user", h.log)
 		return
 	}
 
+	h.publishEvent(c.Request.Context(), events.UserUpdated, user)
 	c.JSON(http.StatusOK, user)
 }
 
@@ -151,21 +196,23 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 // @Failure      500  {object}  handlers.ErrorResponse
 // @Router       /users/{id} [delete]
 func (h *UserHandler) DeleteUser(c *gin.Context) {
-	h.log.Info("Handling DeleteUser request")
+	requestLogger(c, h.log).Info("Handling DeleteUser request")
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 32)
 	if err != nil {
-		h.log.Errorf("Invalid ID format: %v", err)
+		requestLogger(c, h.log).Errorf("Invalid ID format: %v", err)
 		BadRequest(c, "Invalid ID format", h.log)
 		return
 	}
+	h.setUserID(c, uint(id))
 
-	if err := h.db.DeleteUser(context.Background(), uint(id)); err != nil {
-		h.log.Errorf("Failed to delete user: %v", err)
+	if err := h.db.DeleteUser(c.Request.Context(), uint(id)); err != nil {
+		requestLogger(c, h.log).Errorf("Failed to delete user: %v", err)
 		InternalServerError(c, "Failed to delete user", h.log)
 		return
 	}
 
+	h.publishEvent(c.Request.Context(), events.UserDeleted, gin.H{"id": uint(id)})
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
 
@@ -181,16 +228,16 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 // @Failure      500  {object}  handlers.ErrorResponse
 // @Router       /users/search [get]
 func (h *UserHandler) SearchUsers(c *gin.Context) {
-	h.log.Info("Handling SearchUsers request")
+	requestLogger(c, h.log).Info("Handling SearchUsers request")
 	namePattern := c.Query("name")
 	if namePattern == "" {
 		BadRequest(c, "Name search pattern is required", h.log)
 		return
 	}
 
-	users, err := h.db.SearchUsersByName(context.Background(), namePattern)
+	users, err := h.db.SearchUsersByName(c.Request.Context(), namePattern)
 	if err != nil {
-		h.log.Errorf("Failed to search users: %v", err)
+		requestLogger(c, h.log).Errorf("Failed to search users: %v", err)
 		InternalServerError(c, "Failed to search users", h.log)
 		return
 	}
@@ -211,7 +258,7 @@ func (h *UserHandler) SearchUsers(c *gin.Context) {
 // @Failure      500  {object}  handlers.ErrorResponse
 // @Router       /users/list [get]
 func (h *UserHandler) ListUsers(c *gin.Context) {
-	h.log.Info("Handling ListUsers request")
+	requestLogger(c, h.log).Info("Handling ListUsers request")
 
 	// Parse pagination parameters
 	limitStr := c.DefaultQuery("limit", "10")
@@ -229,9 +276,9 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 		return
 	}
 
-	users, err := h.db.ListUsers(context.Background(), limit, offset)
+	users, err := h.db.ListUsers(c.Request.Context(), limit, offset)
 	if err != nil {
-		h.log.Errorf("Failed to list users: %v", err)
+		requestLogger(c, h.log).Errorf("Failed to list users: %v", err)
 		InternalServerError(c, "Failed to list users", h.log)
 		return
 	}
@@ -0,0 +1,79 @@
+package health
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// DiskSpace fails when the file system holding dir has less than minFree
+// bytes available to unprivileged users
+func DiskSpace(dir string, minFree uint64) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var stat syscall.Statfs_t
+		if err := syscall.Statfs(dir, &stat); err != nil {
+			return fmt.Errorf("failed to read free space of %s: %w", dir, err)
+		}
+		free := stat.Bavail * uint64(stat.Bsize)
+		if free < minFree {
+			return fmt.Errorf("%d MB free in %s, below %d MB", free>>20, dir, minFree>>20)
+		}
+		return nil
+	}
+}
+
+// Redis fails unless the Redis server at addr answers PING. A server that
+// requires authentication is reachable and passes
+func Redis(addr string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		if _, err := conn.Write([]byte("PING\r\n")); err != nil {
+			return err
+		}
+		reply, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			return err
+		}
+		reply = strings.TrimSpace(reply)
+		if reply != "+PONG" && !strings.HasPrefix(reply, "-NOAUTH") {
+			return fmt.Errorf("unexpected reply to PING: %q", reply)
+		}
+		return nil
+	}
+}
+
+// Stalled wraps check so that it fails only once check has kept failing for
+// longer than after, counted from the last success or from the call to
+// Stalled. It suits liveness, where a brief outage must not get the process
+// restarted but a dependency that never recovers, such as a database
+// connection held by a stuck transaction, should
+func Stalled(check func(ctx context.Context) error, after time.Duration) func(ctx context.Context) error {
+	var lastOK atomic.Int64
+	lastOK.Store(time.Now().UnixNano())
+	return func(ctx context.Context) error {
+		err := check(ctx)
+		now := time.Now()
+		if err == nil {
+			lastOK.Store(now.UnixNano())
+			return nil
+		}
+		failing := now.Sub(time.Unix(0, lastOK.Load()))
+		if failing < after {
+			return nil
+		}
+		return fmt.Errorf("failing for %s: %w", failing.Round(time.Second), err)
+	}
+}
@@ -0,0 +1,173 @@
+// Package health runs the liveness and readiness checks registered by the
+// components of the service
+package health
+
+import (
+	"context"
+	"gopark/config"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Status is the outcome of a check or of a whole probe
+type Status string
+
+// Statuses
+const (
+	StatusOK       Status = "ok"
+	StatusWarn     Status = "warn" // An optional check failed; the service is still ready
+	StatusFail     Status = "fail"
+	StatusDraining Status = "draining" // The service is shutting down and takes no new work
+)
+
+// Check is a health check registered by a component
+type Check struct {
+	Name  string
+	Check func(ctx context.Context) error
+
+	Liveness bool // Also gates liveness; most checks only gate readiness
+	Optional bool // Failures are reported as warnings and leave the service ready
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Name      string    `json:"name"`
+	Status    Status    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report is the outcome of a probe
+type Report struct {
+	Status Status   `json:"status"`
+	Checks []Result `json:"checks,omitempty"`
+}
+
+// Healthy reports whether the probe passed, possibly with warnings
+func (r Report) Healthy() bool {
+	return r.Status == StatusOK || r.Status == StatusWarn
+}
+
+// entry is a registered check with its cached result
+type entry struct {
+	Check
+	mu      sync.Mutex // Serializes runs, so concurrent probes share one
+	result  Result
+	expires time.Time
+}
+
+// Registry holds the registered checks. It is safe for concurrent use
+type Registry struct {
+	cfg      config.HealthConfig
+	log      *logrus.Logger
+	mu       sync.Mutex
+	entries  []*entry
+	draining atomic.Bool
+}
+
+// NewRegistry creates an empty Registry. Checks that start or stop failing
+// are logged
+func NewRegistry(cfg config.HealthConfig, log *logrus.Logger) *Registry {
+	return &Registry{cfg: cfg, log: log}
+}
+
+// Register adds a check
+func (r *Registry) Register(c Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, &entry{Check: c})
+}
+
+// SetDraining makes readiness fail from now on, so load balancers stop
+// sending requests while in-flight ones finish
+func (r *Registry) SetDraining() {
+	r.draining.Store(true)
+}
+
+// Draining reports whether SetDraining has been called
+func (r *Registry) Draining() bool {
+	return r.draining.Load()
+}
+
+// Liveness runs the liveness checks. A process without any is alive as long
+// as it answers
+func (r *Registry) Liveness(ctx context.Context) Report {
+	return r.run(ctx, func(e *entry) bool { return e.Liveness })
+}
+
+// Readiness runs every check, or reports draining during shutdown
+func (r *Registry) Readiness(ctx context.Context) Report {
+	if r.draining.Load() {
+		return Report{Status: StatusDraining}
+	}
+	return r.run(ctx, func(*entry) bool { return true })
+}
+
+// run runs the selected checks concurrently, reusing cached results
+func (r *Registry) run(ctx context.Context, selected func(*entry) bool) Report {
+	r.mu.Lock()
+	var entries []*entry
+	for _, e := range r.entries {
+		if selected(e) {
+			entries = append(entries, e)
+		}
+	}
+	r.mu.Unlock()
+
+	report := Report{Status: StatusOK, Checks: make([]Result, len(entries))}
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = r.result(ctx, e)
+		}()
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		switch {
+		case result.Status == StatusFail:
+			report.Status = StatusFail
+		case result.Status == StatusWarn && report.Status == StatusOK:
+			report.Status = StatusWarn
+		}
+	}
+	return report
+}
+
+// result returns the cached result of e, running it when expired
+func (r *Registry) result(ctx context.Context, e *entry) Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now()
+	if now.Before(e.expires) {
+		return e.result
+	}
+
+	if r.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.cfg.Timeout)
+		defer cancel()
+	}
+	err := e.Check.Check(ctx)
+	result := Result{Name: e.Name, Status: StatusOK, Duration: time.Since(now).String(), CheckedAt: now}
+	if err != nil {
+		result.Status, result.Error = StatusFail, err.Error()
+		if e.Optional {
+			result.Status = StatusWarn
+		}
+	}
+	switch {
+	case err != nil && e.result.Error == "":
+		r.log.Warnf("Health check %s failed: %v", e.Name, err)
+	case err == nil && e.result.Error != "":
+		r.log.Infof("Health check %s recovered", e.Name)
+	}
+	e.result, e.expires = result, now.Add(r.cfg.CacheTTL)
+	return result
+}
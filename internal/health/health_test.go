@@ -0,0 +1,119 @@
+package health
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"gopark/config"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRegistry creates a Registry that does not log
+func newTestRegistry(cfg config.HealthConfig) *Registry {
+	log := logrus.New()
+	log.SetOutput(bytes.NewBuffer(nil)) // Disable logging output
+	return NewRegistry(cfg, log)
+}
+
+// TestRegistry covers probe outcomes, caching and draining
+func TestRegistry(t *testing.T) {
+	var dbErr error
+	var runs atomic.Int32
+	r := newTestRegistry(config.HealthConfig{CacheTTL: time.Hour, Timeout: 50 * time.Millisecond})
+	r.Register(Check{Name: "database", Check: func(ctx context.Context) error { runs.Add(1); return dbErr }})
+
+	// Test case 1: passing checks are ok, and liveness ignores readiness checks
+	report := r.Readiness(context.Background())
+	assert.Equal(t, StatusOK, report.Status)
+	require.Len(t, report.Checks, 1)
+	assert.Equal(t, "database", report.Checks[0].Name)
+	live := r.Liveness(context.Background())
+	assert.Equal(t, StatusOK, live.Status)
+	assert.Empty(t, live.Checks)
+
+	// Test case 2: results are cached
+	dbErr = errors.New("database is locked")
+	assert.Equal(t, StatusOK, r.Readiness(context.Background()).Status)
+	assert.Equal(t, int32(1), runs.Load())
+
+	// Test case 3: a failing check fails readiness; an optional one only warns
+	r = newTestRegistry(config.HealthConfig{Timeout: 50 * time.Millisecond})
+	r.Register(Check{Name: "jobs", Optional: true, Check: func(ctx context.Context) error { return errors.New("backlog") }})
+	report = r.Readiness(context.Background())
+	assert.Equal(t, StatusWarn, report.Status)
+	assert.True(t, report.Healthy())
+	r.Register(Check{Name: "database", Liveness: true, Check: func(ctx context.Context) error { return dbErr }})
+	report = r.Readiness(context.Background())
+	assert.Equal(t, StatusFail, report.Status)
+	assert.False(t, report.Healthy())
+	assert.Equal(t, "database is locked", report.Checks[1].Error)
+	assert.Equal(t, StatusFail, r.Liveness(context.Background()).Status)
+
+	// Test case 4: checks are bounded by the timeout
+	r = newTestRegistry(config.HealthConfig{Timeout: 20 * time.Millisecond})
+	r.Register(Check{Name: "slow", Check: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	report = r.Readiness(context.Background())
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Contains(t, report.Checks[0].Error, "deadline exceeded")
+
+	// Test case 5: readiness fails while draining; liveness does not
+	r.SetDraining()
+	assert.Equal(t, StatusDraining, r.Readiness(context.Background()).Status)
+	assert.Equal(t, StatusOK, r.Liveness(context.Background()).Status)
+}
+
+// TestChecks covers the built-in checks
+func TestChecks(t *testing.T) {
+	ctx := context.Background()
+
+	// Test case 1: disk space is compared with the minimum
+	assert.NoError(t, DiskSpace(t.TempDir(), 1)(ctx))
+	assert.ErrorContains(t, DiskSpace(t.TempDir(), 1<<62)(ctx), "MB free")
+	assert.Error(t, DiskSpace("/does/not/exist", 1)(ctx))
+
+	// Test case 2: Redis must answer PING
+	reply := "+PONG\r\n"
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			bufio.NewReader(conn).ReadString('\n')
+			conn.Write([]byte(reply))
+			conn.Close()
+		}
+	}()
+	assert.NoError(t, Redis(ln.Addr().String())(ctx))
+	reply = "-NOAUTH Authentication required.\r\n"
+	assert.NoError(t, Redis(ln.Addr().String())(ctx))
+	reply = "-ERR unknown\r\n"
+	assert.ErrorContains(t, Redis(ln.Addr().String())(ctx), "unexpected reply")
+
+	// Test case 3: a stalled check fails only after failing for the whole window
+	var pingErr error
+	stalled := Stalled(func(ctx context.Context) error { return pingErr }, 50*time.Millisecond)
+	assert.NoError(t, stalled(ctx))
+	pingErr = errors.New("database is locked")
+	assert.NoError(t, stalled(ctx))
+	time.Sleep(60 * time.Millisecond)
+	assert.ErrorContains(t, stalled(ctx), "database is locked")
+	pingErr = nil
+	assert.NoError(t, stalled(ctx))
+	pingErr = errors.New("database is locked")
+	assert.NoError(t, stalled(ctx))
+}
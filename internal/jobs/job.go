@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Status describes where a job is in its lifecycle
+type Status string
+
+// Job statuses
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCanceled  Status = "canceled"
+)
+
+var (
+	// ErrNotFound is returned when a job does not exist
+	ErrNotFound = errors.New("job not found")
+	// ErrInvalidState is returned when a job cannot transition from its current status
+	ErrInvalidState = errors.New("job is not in a valid state for this operation")
+	// ErrDuplicate is returned when another active job already holds the unique key
+	ErrDuplicate = errors.New("an active job with the same unique key already exists")
+)
+
+// Job is a unit of background work persisted in SQLite
+type Job struct {
+	ID             int64           `json:"id"`
+	Kind           string          `json:"kind"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         Status          `json:"status"`
+	UniqueKey      string          `json:"unique_key,omitempty"`
+	Attempts       int             `json:"attempts"`
+	MaxAttempts    int             `json:"max_attempts"`
+	TimeoutSeconds int             `json:"timeout_seconds"`
+	LastError      string          `json:"last_error,omitempty"`
+	RunAt          time.Time       `json:"run_at"`
+	LockedAt       *time.Time      `json:"locked_at,omitempty"`
+	FinishedAt     *time.Time      `json:"finished_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	RequestID      string          `json:"request_id,omitempty"` // Request that enqueued the job
+}
+
+// Decode unmarshals the job payload into v
+func (j *Job) Decode(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// Handler executes a job; returning an error schedules a retry
+type Handler func(ctx context.Context, job *Job) error
+
+// EnqueueOptions tune how a job is scheduled; zero values use the queue defaults
+type EnqueueOptions struct {
+	UniqueKey   string        // Deduplicates against pending or running jobs with the same key
+	RunAt       time.Time     // Earliest execution time
+	MaxAttempts int           // Attempts before the job is marked failed
+	Timeout     time.Duration // Execution timeout for a single attempt, rounded up to whole seconds
+}
+
+// permanentError marks an error that must not be retried
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the job fails immediately instead of being retried
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// isPermanent reports whether err was wrapped with Permanent
+func isPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
@@ -0,0 +1,321 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopark/config"
+	"gopark/internal/db"
+	"gopark/internal/logging"
+	"gopark/internal/requestid"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Queue persists jobs in SQLite and executes them on a pool of workers
+type Queue struct {
+	db       *db.DB
+	cfg      config.JobsConfig
+	log      *logrus.Logger
+	handlers map[string]Handler
+
+	mu       sync.Mutex
+	inflight map[int64]context.CancelFunc
+	started  bool
+
+	wake     chan struct{}
+	quit     chan struct{}
+	quitOnce sync.Once
+	wg       sync.WaitGroup
+
+	// runCtx is the parent of every job context; canceling it aborts running jobs
+	runCtx    context.Context
+	cancelRun context.CancelFunc
+}
+
+// NewQueue creates a job queue backed by the jobs table
+func NewQueue(dbConn *db.DB, cfg config.JobsConfig, log *logrus.Logger) *Queue {
+	runCtx, cancelRun := context.WithCancel(context.Background())
+	return &Queue{
+		db:        dbConn,
+		cfg:       cfg,
+		log:       log,
+		handlers:  make(map[string]Handler),
+		inflight:  make(map[int64]context.CancelFunc),
+		wake:      make(chan struct{}, 1),
+		quit:      make(chan struct{}),
+		runCtx:    runCtx,
+		cancelRun: cancelRun,
+	}
+}
+
+// Register associates a handler with a job kind; call before Start
+func (q *Queue) Register(kind string, handler Handler) {
+	q.handlers[kind] = handler
+}
+
+// Enqueue persists a new job; if an active job holds the same unique key it is returned instead
+func (q *Queue) Enqueue(ctx context.Context, kind string, payload interface{}, opts EnqueueOptions) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode payload for %s job: %w", kind, err)
+	}
+
+	job := &Job{
+		Kind:           kind,
+		Payload:        data,
+		UniqueKey:      opts.UniqueKey,
+		MaxAttempts:    opts.MaxAttempts,
+		TimeoutSeconds: timeoutSeconds(opts.Timeout),
+		RunAt:          opts.RunAt,
+		RequestID:      requestid.FromContext(ctx),
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = q.cfg.MaxAttempts
+	}
+	if opts.Timeout <= 0 {
+		job.TimeoutSeconds = timeoutSeconds(q.cfg.Timeout)
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now().UTC()
+	}
+
+	log := logging.FromContext(ctx, q.log)
+	if err := q.insertJob(ctx, job); err != nil {
+		if errors.Is(err, ErrDuplicate) {
+			existing, getErr := q.getActiveByUniqueKey(ctx, opts.UniqueKey)
+			if getErr == nil {
+				log.Debugf("Job %s with unique key %q already queued as %d", kind, opts.UniqueKey, existing.ID)
+				return existing, nil
+			}
+		}
+		log.Errorf("Failed to enqueue %s job: %v", kind, err)
+		return nil, err
+	}
+
+	log.Infof("Enqueued %s job %d (run at %s)", kind, job.ID, job.RunAt.Format(time.RFC3339))
+	q.notify()
+	return job, nil
+}
+
+// timeoutSeconds converts a job timeout to the whole seconds stored with the
+// job, rounding up so that a sub-second timeout is not lost
+func timeoutSeconds(timeout time.Duration) int {
+	return int((timeout + time.Second - 1) / time.Second)
+}
+
+// Retry requeues a failed or canceled job with a fresh set of attempts
+func (q *Queue) Retry(ctx context.Context, id int64) error {
+	err := q.transition(ctx, id,
+		"status = 'pending', attempts = 0, last_error = '', locked_at = NULL, finished_at = NULL, run_at = ?",
+		[]Status{StatusFailed, StatusCanceled}, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	q.log.Infof("Job %d requeued for retry", id)
+	q.notify()
+	return nil
+}
+
+// Cancel stops a pending or running job; a running job's context is canceled
+func (q *Queue) Cancel(ctx context.Context, id int64) error {
+	now := time.Now().UTC()
+	err := q.transition(ctx, id,
+		"status = 'canceled', locked_at = NULL, finished_at = ?",
+		[]Status{StatusPending, StatusRunning}, now)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	if cancel, ok := q.inflight[id]; ok {
+		cancel()
+	}
+	q.mu.Unlock()
+
+	q.log.Infof("Job %d canceled", id)
+	return nil
+}
+
+// Start requeues stale jobs and launches the worker pool
+func (q *Queue) Start(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.started {
+		return errors.New("job queue already started")
+	}
+
+	requeued, err := q.requeueStale(ctx, time.Now().Add(-q.cfg.StaleAfter))
+	if err != nil {
+		return fmt.Errorf("unable to requeue stale jobs: %w", err)
+	}
+	if requeued > 0 {
+		q.log.Warnf("Requeued %d stale running jobs", requeued)
+	}
+
+	for i := 0; i < q.cfg.Concurrency; i++ {
+		q.wg.Add(1)
+		go q.worker(i)
+	}
+	q.started = true
+
+	q.log.Infof("Job queue started with %d workers", q.cfg.Concurrency)
+	return nil
+}
+
+// Drain stops claiming new jobs and waits for running ones; when ctx expires
+// the remaining jobs are canceled and returned to the queue
+func (q *Queue) Drain(ctx context.Context) error {
+	q.quitOnce.Do(func() { close(q.quit) })
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.log.Info("Job queue drained")
+		return nil
+	case <-ctx.Done():
+		q.log.Warn("Job queue drain timed out, aborting running jobs")
+		q.cancelRun()
+		<-done
+		return fmt.Errorf("job queue drain incomplete: %w", ctx.Err())
+	}
+}
+
+// notify wakes an idle worker without blocking
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// worker claims and executes jobs until the queue is drained
+func (q *Queue) worker(n int) {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-q.quit:
+			return
+		default:
+		}
+
+		job, err := q.claim(context.Background())
+		if err != nil {
+			q.log.Errorf("Worker %d failed to claim job: %v", n, err)
+		}
+		if job == nil {
+			select {
+			case <-q.quit:
+				return
+			case <-q.wake:
+			case <-time.After(q.cfg.PollInterval):
+			}
+			continue
+		}
+
+		q.execute(job)
+	}
+}
+
+// execute runs a claimed job and records the outcome
+func (q *Queue) execute(job *Job) {
+	timeout := time.Duration(job.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = q.cfg.Timeout
+	}
+	ctx, cancel := context.WithTimeout(q.runCtx, timeout)
+	defer cancel()
+
+	q.mu.Lock()
+	q.inflight[job.ID] = cancel
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		delete(q.inflight, job.ID)
+		q.mu.Unlock()
+	}()
+
+	log := q.log.WithFields(logrus.Fields{
+		"job_id":   job.ID,
+		"job_kind": job.Kind,
+		"attempt":  job.Attempts,
+	})
+	// Handlers log and publish events as part of the enqueuing request
+	if job.RequestID != "" {
+		log = log.WithField("request_id", job.RequestID)
+		ctx = requestid.NewContext(ctx, job.RequestID)
+	}
+	ctx = logging.NewContext(ctx, log)
+
+	started := time.Now()
+	err := q.invoke(ctx, job)
+	latency := time.Since(started)
+
+	// Bookkeeping must succeed even when the job context was canceled
+	bg := context.Background()
+	switch {
+	case err == nil:
+		if err := q.markSucceeded(bg, job); err != nil {
+			log.Errorf("Failed to mark job succeeded: %v", err)
+			return
+		}
+		log.Infof("Job succeeded in %s", latency)
+	case q.runCtx.Err() != nil:
+		// Aborted by shutdown; the attempt does not count against the job
+		if err := q.release(bg, job); err != nil {
+			log.Errorf("Failed to release aborted job: %v", err)
+			return
+		}
+		log.Warn("Job aborted by shutdown and returned to the queue")
+	case isPermanent(err) || job.Attempts >= job.MaxAttempts:
+		if err := q.markFailed(bg, job, err); err != nil {
+			log.Errorf("Failed to mark job failed: %v", err)
+			return
+		}
+		log.Errorf("Job failed after %d attempts: %v", job.Attempts, err)
+	default:
+		runAt := time.Now().Add(q.backoff(job.Attempts))
+		if err := q.markRetry(bg, job, runAt, err); err != nil {
+			log.Errorf("Failed to reschedule job: %v", err)
+			return
+		}
+		log.Warnf("Job attempt failed, retrying at %s: %v", runAt.Format(time.RFC3339), err)
+	}
+}
+
+// invoke calls the registered handler, converting panics into errors
+func (q *Queue) invoke(ctx context.Context, job *Job) (err error) {
+	handler, ok := q.handlers[job.Kind]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler registered for job kind %q", job.Kind))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job handler panicked: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// backoff returns the exponential delay before the next attempt
+func (q *Queue) backoff(attempts int) time.Duration {
+	delay := q.cfg.Backoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= q.cfg.MaxBackoff {
+			return q.cfg.MaxBackoff
+		}
+	}
+	return delay
+}
@@ -0,0 +1,229 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"gopark/config"
+	"gopark/internal/db"
+	"gopark/internal/requestid"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupQueue creates a queue backed by a migrated temporary database
+func setupQueue(t *testing.T) *Queue {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(bytes.NewBuffer(nil)) // Disable logging output
+
+	var cfg config.Config
+	cfg.Database.Path = filepath.Join(t.TempDir(), "jobs.db")
+	dbConn, err := db.NewDB(cfg, log)
+	require.NoError(t, err)
+	t.Cleanup(dbConn.Close)
+
+	migrations := db.NewMigrationManager(dbConn, log)
+	require.NoError(t, migrations.RunMigrations(context.Background(), "../migrations"))
+
+	return NewQueue(dbConn, config.JobsConfig{
+		Concurrency:  2,
+		PollInterval: 10 * time.Millisecond,
+		MaxAttempts:  3,
+		Timeout:      time.Second,
+		Backoff:      time.Millisecond,
+		MaxBackoff:   5 * time.Millisecond,
+		StaleAfter:   time.Minute,
+	}, log)
+}
+
+// waitForStatus polls until the job reaches the expected status
+func waitForStatus(t *testing.T, q *Queue, id int64, status Status) *Job {
+	t.Helper()
+	var job *Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = q.Get(context.Background(), id)
+		return err == nil && job.Status == status
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+// TestQueueExecution exercises job execution, retries and failure
+func TestQueueExecution(t *testing.T) {
+	q := setupQueue(t)
+	var flaky int32
+	q.Register("echo", func(ctx context.Context, job *Job) error {
+		var payload map[string]string
+		return job.Decode(&payload)
+	})
+	q.Register("flaky", func(ctx context.Context, job *Job) error {
+		if atomic.AddInt32(&flaky, 1) < 2 {
+			return errors.New("temporary failure")
+		}
+		return nil
+	})
+	q.Register("broken", func(ctx context.Context, job *Job) error {
+		return errors.New("always fails")
+	})
+	q.Register("invalid", func(ctx context.Context, job *Job) error {
+		return Permanent(errors.New("bad payload"))
+	})
+	require.NoError(t, q.Start(context.Background()))
+	defer q.Drain(context.Background())
+
+	// Test case 1: a job succeeds on the first attempt
+	t.Run("Success", func(t *testing.T) {
+		job, err := q.Enqueue(context.Background(), "echo", map[string]string{"hello": "world"}, EnqueueOptions{})
+		require.NoError(t, err)
+		job = waitForStatus(t, q, job.ID, StatusSucceeded)
+		assert.Equal(t, 1, job.Attempts)
+		assert.NotNil(t, job.FinishedAt)
+	})
+
+	// Test case 2: a failing job is retried until it succeeds
+	t.Run("Retry", func(t *testing.T) {
+		job, err := q.Enqueue(context.Background(), "flaky", nil, EnqueueOptions{})
+		require.NoError(t, err)
+		job = waitForStatus(t, q, job.ID, StatusSucceeded)
+		assert.Equal(t, 2, job.Attempts)
+	})
+
+	// Test case 3: a job that keeps failing is marked failed after max attempts
+	t.Run("Exhausted", func(t *testing.T) {
+		job, err := q.Enqueue(context.Background(), "broken", nil, EnqueueOptions{MaxAttempts: 2})
+		require.NoError(t, err)
+		job = waitForStatus(t, q, job.ID, StatusFailed)
+		assert.Equal(t, 2, job.Attempts)
+		assert.Equal(t, "always fails", job.LastError)
+	})
+
+	// Test case 4: permanent errors and unknown kinds skip retries
+	t.Run("Permanent", func(t *testing.T) {
+		job, err := q.Enqueue(context.Background(), "invalid", nil, EnqueueOptions{})
+		require.NoError(t, err)
+		job = waitForStatus(t, q, job.ID, StatusFailed)
+		assert.Equal(t, 1, job.Attempts)
+
+		job, err = q.Enqueue(context.Background(), "unknown", nil, EnqueueOptions{})
+		require.NoError(t, err)
+		job = waitForStatus(t, q, job.ID, StatusFailed)
+		assert.Contains(t, job.LastError, "no handler registered")
+	})
+
+	// Test case 5: a job exceeding its timeout sees its context canceled
+	t.Run("Timeout", func(t *testing.T) {
+		q.Register("slow", func(ctx context.Context, job *Job) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		job, err := q.Enqueue(context.Background(), "slow", nil, EnqueueOptions{MaxAttempts: 1, Timeout: time.Second})
+		require.NoError(t, err)
+		job = waitForStatus(t, q, job.ID, StatusFailed)
+		assert.Contains(t, job.LastError, "deadline exceeded")
+
+		// Sub-second timeouts round up instead of falling back to the default
+		job, err = q.Enqueue(context.Background(), "slow", nil, EnqueueOptions{MaxAttempts: 1, Timeout: 500 * time.Millisecond})
+		require.NoError(t, err)
+		assert.Equal(t, 1, job.TimeoutSeconds)
+		job = waitForStatus(t, q, job.ID, StatusFailed)
+		assert.Equal(t, 1, job.TimeoutSeconds)
+	})
+
+	// Test case 6: the enqueuing request's ID is stored and passed to the handler
+	t.Run("RequestID", func(t *testing.T) {
+		seen := make(chan string, 1)
+		q.Register("traced", func(ctx context.Context, job *Job) error {
+			seen <- requestid.FromContext(ctx)
+			return nil
+		})
+		job, err := q.Enqueue(requestid.NewContext(context.Background(), "req-1"), "traced", nil, EnqueueOptions{})
+		require.NoError(t, err)
+		job = waitForStatus(t, q, job.ID, StatusSucceeded)
+		assert.Equal(t, "req-1", job.RequestID)
+		assert.Equal(t, "req-1", <-seen)
+	})
+}
+
+// TestQueueAdministration exercises dedup, scheduling, cancel and retry
+func TestQueueAdministration(t *testing.T) {
+	q := setupQueue(t)
+	ctx := context.Background()
+
+	// Test case 1: an active job with the same unique key is returned instead of a new one
+	t.Run("Unique Key", func(t *testing.T) {
+		first, err := q.Enqueue(ctx, "export", nil, EnqueueOptions{UniqueKey: "export:1"})
+		require.NoError(t, err)
+		second, err := q.Enqueue(ctx, "export", nil, EnqueueOptions{UniqueKey: "export:1"})
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, second.ID)
+	})
+
+	// Test case 2: jobs scheduled in the future are not claimed early
+	t.Run("Scheduled", func(t *testing.T) {
+		job, err := q.Enqueue(ctx, "later", nil, EnqueueOptions{RunAt: time.Now().Add(time.Hour)})
+		require.NoError(t, err)
+
+		claimed, err := q.claim(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, claimed)
+		assert.NotEqual(t, job.ID, claimed.ID)
+	})
+
+	// Test case 3: canceled jobs release their unique key and can be retried
+	t.Run("Cancel And Retry", func(t *testing.T) {
+		job, err := q.Enqueue(ctx, "import", nil, EnqueueOptions{UniqueKey: "import:1"})
+		require.NoError(t, err)
+		require.NoError(t, q.Cancel(ctx, job.ID))
+		assert.ErrorIs(t, q.Cancel(ctx, job.ID), ErrInvalidState)
+
+		replacement, err := q.Enqueue(ctx, "import", nil, EnqueueOptions{UniqueKey: "import:1"})
+		require.NoError(t, err)
+		assert.NotEqual(t, job.ID, replacement.ID)
+		assert.ErrorIs(t, q.Retry(ctx, job.ID), ErrDuplicate)
+
+		require.NoError(t, q.Cancel(ctx, replacement.ID))
+		require.NoError(t, q.Retry(ctx, job.ID))
+		job, err = q.Get(ctx, job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, StatusPending, job.Status)
+	})
+
+	// Test case 4: unknown jobs report not found
+	t.Run("Not Found", func(t *testing.T) {
+		assert.ErrorIs(t, q.Cancel(ctx, 9999), ErrNotFound)
+		_, err := q.Get(ctx, 9999)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+// TestQueueDrain verifies running jobs are returned to the queue when the drain deadline expires
+func TestQueueDrain(t *testing.T) {
+	q := setupQueue(t)
+	started := make(chan struct{})
+	q.Register("blocking", func(ctx context.Context, job *Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	require.NoError(t, q.Start(context.Background()))
+
+	job, err := q.Enqueue(context.Background(), "blocking", nil, EnqueueOptions{Timeout: time.Minute})
+	require.NoError(t, err)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Error(t, q.Drain(ctx))
+
+	job, err = q.Get(context.Background(), job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, job.Status)
+	assert.Equal(t, 0, job.Attempts)
+}
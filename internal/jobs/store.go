@@ -0,0 +1,258 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// jobColumns lists the columns scanned by scanJob, in order
+const jobColumns = `id, kind, payload, status, unique_key, attempts, max_attempts, timeout_seconds,
+	last_error, run_at, locked_at, finished_at, created_at, updated_at, request_id`
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanJob reads a job row selected with jobColumns
+func scanJob(row rowScanner) (*Job, error) {
+	var (
+		job        Job
+		payload    string
+		uniqueKey  sql.NullString
+		lockedAt   sql.NullTime
+		finishedAt sql.NullTime
+	)
+	err := row.Scan(&job.ID, &job.Kind, &payload, &job.Status, &uniqueKey, &job.Attempts, &job.MaxAttempts,
+		&job.TimeoutSeconds, &job.LastError, &job.RunAt, &lockedAt, &finishedAt, &job.CreatedAt, &job.UpdatedAt, &job.RequestID)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Payload = []byte(payload)
+	job.UniqueKey = uniqueKey.String
+	if lockedAt.Valid {
+		job.LockedAt = &lockedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return &job, nil
+}
+
+// isUniqueViolation reports whether err is a SQLite unique constraint failure
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// insertJob stores a new pending job
+func (q *Queue) insertJob(ctx context.Context, job *Job) error {
+	now := time.Now().UTC()
+	var uniqueKey interface{}
+	if job.UniqueKey != "" {
+		uniqueKey = job.UniqueKey
+	}
+
+	query := `INSERT INTO jobs (kind, payload, status, unique_key, max_attempts, timeout_seconds, run_at, created_at, updated_at, request_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := q.db.ExecContext(ctx, query, job.Kind, string(job.Payload), StatusPending, uniqueKey,
+		job.MaxAttempts, job.TimeoutSeconds, job.RunAt.UTC(), now, now, job.RequestID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicate
+		}
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	job.ID = id
+	job.Status = StatusPending
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	return nil
+}
+
+// Get retrieves a job by ID
+func (q *Queue) Get(ctx context.Context, id int64) (*Job, error) {
+	query := "SELECT " + jobColumns + " FROM jobs WHERE id = ?"
+	job, err := scanJob(q.db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return job, err
+}
+
+// getActiveByUniqueKey retrieves the pending or running job holding a unique key
+func (q *Queue) getActiveByUniqueKey(ctx context.Context, key string) (*Job, error) {
+	query := "SELECT " + jobColumns + " FROM jobs WHERE unique_key = ? AND status IN ('pending', 'running')"
+	job, err := scanJob(q.db.QueryRowContext(ctx, query, key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return job, err
+}
+
+// List retrieves jobs, optionally filtered by status, newest first
+func (q *Queue) List(ctx context.Context, status Status, limit, offset int) ([]*Job, error) {
+	if limit <= 0 {
+		limit = 20 // Default limit
+	}
+	if limit > 100 {
+		limit = 100 // Maximum limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	query := "SELECT " + jobColumns + " FROM jobs"
+	args := []interface{}{}
+	if status != "" {
+		query += " WHERE status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []*Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// CountByStatus returns the number of jobs in the given status
+func (q *Queue) CountByStatus(ctx context.Context, status Status) (int, error) {
+	var count int
+	err := q.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM jobs WHERE status = ?", status).Scan(&count)
+	return count, err
+}
+
+// claim atomically marks the next due job as running and returns it
+func (q *Queue) claim(ctx context.Context) (*Job, error) {
+	now := time.Now().UTC()
+	query := `UPDATE jobs SET status = 'running', attempts = attempts + 1, locked_at = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM jobs WHERE status = 'pending' AND run_at <= ? ORDER BY run_at, id LIMIT 1
+		)
+		RETURNING ` + jobColumns
+	job, err := scanJob(q.db.QueryRowContext(ctx, query, now, now, now))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return job, err
+}
+
+// markSucceeded records a successful run
+func (q *Queue) markSucceeded(ctx context.Context, job *Job) error {
+	now := time.Now().UTC()
+	query := `UPDATE jobs SET status = 'succeeded', last_error = '', locked_at = NULL, finished_at = ?, updated_at = ?
+		WHERE id = ? AND status = 'running'`
+	_, err := q.db.ExecContext(ctx, query, now, now, job.ID)
+	return err
+}
+
+// markRetry returns a job to the queue to run again at runAt
+func (q *Queue) markRetry(ctx context.Context, job *Job, runAt time.Time, runErr error) error {
+	query := `UPDATE jobs SET status = 'pending', last_error = ?, locked_at = NULL, run_at = ?, updated_at = ?
+		WHERE id = ? AND status = 'running'`
+	_, err := q.db.ExecContext(ctx, query, runErr.Error(), runAt.UTC(), time.Now().UTC(), job.ID)
+	return err
+}
+
+// release returns an interrupted job to the queue without consuming an attempt
+func (q *Queue) release(ctx context.Context, job *Job) error {
+	query := `UPDATE jobs SET status = 'pending', attempts = attempts - 1, locked_at = NULL, updated_at = ?
+		WHERE id = ? AND status = 'running'`
+	_, err := q.db.ExecContext(ctx, query, time.Now().UTC(), job.ID)
+	return err
+}
+
+// markFailed records a job that exhausted its attempts or failed permanently
+func (q *Queue) markFailed(ctx context.Context, job *Job, runErr error) error {
+	now := time.Now().UTC()
+	query := `UPDATE jobs SET status = 'failed', last_error = ?, locked_at = NULL, finished_at = ?, updated_at = ?
+		WHERE id = ? AND status = 'running'`
+	_, err := q.db.ExecContext(ctx, query, runErr.Error(), now, now, job.ID)
+	return err
+}
+
+// requeueStale returns jobs left running by a crashed worker to the queue
+func (q *Queue) requeueStale(ctx context.Context, before time.Time) (int64, error) {
+	query := `UPDATE jobs SET status = 'pending', locked_at = NULL, updated_at = ?
+		WHERE status = 'running' AND locked_at < ?`
+	result, err := q.db.ExecContext(ctx, query, time.Now().UTC(), before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// PurgeFinished deletes succeeded, failed and canceled jobs finished before the cutoff
+func (q *Queue) PurgeFinished(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM jobs WHERE status IN ('succeeded', 'failed', 'canceled') AND finished_at < ?`
+	result, err := q.db.ExecContext(ctx, query, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	q.log.Infof("Purged %d finished jobs", purged)
+	return purged, nil
+}
+
+// transition moves a job between statuses, guarding on the allowed source statuses
+func (q *Queue) transition(ctx context.Context, id int64, set string, from []Status, args ...interface{}) error {
+	query := fmt.Sprintf("UPDATE jobs SET %s, updated_at = ? WHERE id = ? AND status IN (", set)
+	args = append(args, time.Now().UTC(), id)
+	for i, status := range from {
+		if i > 0 {
+			query += ", "
+		}
+		query += "?"
+		args = append(args, status)
+	}
+	query += ")"
+
+	result, err := q.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicate
+		}
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		if _, err := q.Get(ctx, id); err != nil {
+			return err
+		}
+		return ErrInvalidState
+	}
+	return nil
+}
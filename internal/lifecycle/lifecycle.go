@@ -0,0 +1,198 @@
+// Package lifecycle starts and stops the components of the service in
+// dependency order
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Component is a part of the service with its own startup and shutdown
+type Component struct {
+	Name      string
+	DependsOn []string // Components that must be started before and stopped after this one
+
+	// Start brings the component up and returns once it is running; long
+	// running work belongs in goroutines stopped by Stop. A component without
+	// Start is running as soon as it is added, e.g. a database opened earlier
+	Start func(ctx context.Context) error
+	// Stop releases the component. It is called even when the shutdown
+	// deadline has passed, so it should then give up quickly rather than hang
+	Stop func(ctx context.Context) error
+
+	StartTimeout time.Duration // Bounds Start; 0 leaves only the caller's deadline
+	StopTimeout  time.Duration // Bounds Stop; 0 leaves only the caller's deadline
+}
+
+// component tracks the state of a registered Component
+type component struct {
+	Component
+	running bool
+}
+
+// Manager runs components in dependency order. It is safe for concurrent use
+type Manager struct {
+	log        *logrus.Logger
+	mu         sync.Mutex
+	components []*component
+	byName     map[string]*component
+	started    bool
+	stopped    bool
+}
+
+// NewManager creates an empty Manager
+func NewManager(log *logrus.Logger) *Manager {
+	return &Manager{log: log, byName: map[string]*component{}}
+}
+
+// Add registers a component. Components may be added in any order; their
+// dependencies are resolved by Start
+func (m *Manager) Add(c Component) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c.Name == "" {
+		return errors.New("component name is required")
+	}
+	if _, ok := m.byName[c.Name]; ok {
+		return fmt.Errorf("component %s already registered", c.Name)
+	}
+	if m.started || m.stopped {
+		return fmt.Errorf("cannot add component %s after start", c.Name)
+	}
+	comp := &component{Component: c, running: c.Start == nil}
+	m.components = append(m.components, comp)
+	m.byName[c.Name] = comp
+	return nil
+}
+
+// Start starts every component after its dependencies, in registration order
+// where dependencies allow. If one fails, every running component is stopped
+// in reverse order and the start error is returned together with any stop
+// errors
+func (m *Manager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.started || m.stopped {
+		return errors.New("lifecycle already started")
+	}
+	m.started = true
+
+	order, err := m.order()
+	if err != nil {
+		return errors.Join(err, m.stop(ctx, m.components))
+	}
+
+	for _, c := range order {
+		if c.running {
+			continue
+		}
+		begin := time.Now()
+		if err := call(ctx, c.StartTimeout, c.Start); err != nil {
+			err = fmt.Errorf("failed to start %s: %w", c.Name, err)
+			return errors.Join(err, m.stop(ctx, order))
+		}
+		c.running = true
+		m.log.Debugf("Started %s in %s", c.Name, time.Since(begin))
+	}
+	return nil
+}
+
+// Stop stops the running components in the reverse of their start order.
+// Every component is stopped even if others fail or ctx expires; the
+// failures are logged and returned joined. Stop may be called more than
+// once, and before Start to release components without a Start hook
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	order, err := m.order()
+	if err != nil {
+		order = m.components // Invalid dependencies never started anything; any order will do
+	}
+	return m.stop(ctx, order)
+}
+
+// stop stops the running components of order, last first
+func (m *Manager) stop(ctx context.Context, order []*component) error {
+	m.stopped = true
+	var errs []error
+	for i := len(order) - 1; i >= 0; i-- {
+		c := order[i]
+		if !c.running {
+			continue
+		}
+		c.running = false
+		if c.Stop == nil {
+			continue
+		}
+		begin := time.Now()
+		if err := call(ctx, c.StopTimeout, c.Stop); err != nil {
+			err = fmt.Errorf("failed to stop %s: %w", c.Name, err)
+			m.log.Error(err)
+			errs = append(errs, err)
+			continue
+		}
+		m.log.Debugf("Stopped %s in %s", c.Name, time.Since(begin))
+	}
+	return errors.Join(errs...)
+}
+
+// order sorts the components so each follows its dependencies, keeping
+// registration order otherwise
+func (m *Manager) order() ([]*component, error) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[*component]int, len(m.components))
+	order := make([]*component, 0, len(m.components))
+	var path []string
+
+	var visit func(c *component) error
+	visit = func(c *component) error {
+		switch state[c] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s -> %s", strings.Join(path, " -> "), c.Name)
+		}
+		state[c] = visiting
+		path = append(path, c.Name)
+		for _, name := range c.DependsOn {
+			dep, ok := m.byName[name]
+			if !ok {
+				return fmt.Errorf("component %s depends on unknown component %s", c.Name, name)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[c] = done
+		order = append(order, c)
+		return nil
+	}
+
+	for _, c := range m.components {
+		if err := visit(c); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// call runs fn with ctx, bounded by timeout when it is positive
+func call(ctx context.Context, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return fn(ctx)
+}
@@ -0,0 +1,146 @@
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder collects start and stop events in order
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// component returns a Component that records its start and stop
+func (r *recorder) component(name string, deps ...string) Component {
+	return Component{
+		Name:      name,
+		DependsOn: deps,
+		Start:     func(ctx context.Context) error { r.record("start " + name); return nil },
+		Stop:      func(ctx context.Context) error { r.record("stop " + name); return nil },
+	}
+}
+
+// newTestManager creates a manager with logging disabled
+func newTestManager() *Manager {
+	log := logrus.New()
+	log.SetOutput(bytes.NewBuffer(nil)) // Disable logging output
+	return NewManager(log)
+}
+
+// TestOrder verifies dependencies start first and stop last
+func TestOrder(t *testing.T) {
+	rec := &recorder{}
+	m := newTestManager()
+	ctx := context.Background()
+
+	// Test case 1: registration order does not matter
+	require.NoError(t, m.Add(rec.component("http", "jobs", "database")))
+	require.NoError(t, m.Add(rec.component("jobs", "database")))
+	require.NoError(t, m.Add(Component{Name: "database", Stop: func(ctx context.Context) error {
+		rec.record("stop database")
+		return nil
+	}}))
+	require.NoError(t, m.Start(ctx))
+	assert.Equal(t, []string{"start jobs", "start http"}, rec.events)
+
+	// Test case 2: shutdown runs in reverse, including components without Start
+	require.NoError(t, m.Stop(ctx))
+	assert.Equal(t, []string{"start jobs", "start http", "stop http", "stop jobs", "stop database"}, rec.events)
+
+	// Test case 3: stopping again and restarting do nothing
+	require.NoError(t, m.Stop(ctx))
+	assert.Error(t, m.Start(ctx))
+	assert.Len(t, rec.events, 5)
+}
+
+// TestStartFailure verifies a failed start unwinds what is running
+func TestStartFailure(t *testing.T) {
+	rec := &recorder{}
+	m := newTestManager()
+	require.NoError(t, m.Add(rec.component("database")))
+	require.NoError(t, m.Add(rec.component("jobs", "database")))
+	require.NoError(t, m.Add(Component{Name: "http", DependsOn: []string{"jobs"}, Start: func(ctx context.Context) error {
+		return errors.New("address already in use")
+	}}))
+	require.NoError(t, m.Add(rec.component("metrics")))
+
+	err := m.Start(context.Background())
+	assert.ErrorContains(t, err, "failed to start http: address already in use")
+	assert.Equal(t, []string{"start database", "start jobs", "stop jobs", "stop database"}, rec.events)
+}
+
+// TestStopErrors verifies every component is stopped and failures reported
+func TestStopErrors(t *testing.T) {
+	rec := &recorder{}
+	m := newTestManager()
+	require.NoError(t, m.Add(rec.component("database")))
+	require.NoError(t, m.Add(Component{
+		Name:        "jobs",
+		DependsOn:   []string{"database"},
+		Start:       func(ctx context.Context) error { return nil },
+		StopTimeout: 10 * time.Millisecond,
+		Stop: func(ctx context.Context) error {
+			<-ctx.Done() // A drain that never finishes
+			return ctx.Err()
+		},
+	}))
+	require.NoError(t, m.Add(Component{
+		Name:      "events",
+		DependsOn: []string{"jobs"},
+		Start:     func(ctx context.Context) error { return nil },
+		Stop:      func(ctx context.Context) error { return errors.New("flush failed") },
+	}))
+	require.NoError(t, m.Start(context.Background()))
+
+	err := m.Stop(context.Background())
+	assert.ErrorContains(t, err, "failed to stop events: flush failed")
+	assert.ErrorContains(t, err, "failed to stop jobs")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []string{"start database", "stop database"}, rec.events)
+}
+
+// TestInvalidDependencies verifies configuration errors are reported by Start
+func TestInvalidDependencies(t *testing.T) {
+	tests := []struct {
+		name       string
+		components []Component
+		want       string
+	}{
+		{"Unknown", []Component{{Name: "jobs", DependsOn: []string{"database"}}}, "depends on unknown component database"},
+		{"Cycle", []Component{
+			{Name: "a", DependsOn: []string{"b"}},
+			{Name: "b", DependsOn: []string{"c"}},
+			{Name: "c", DependsOn: []string{"a"}},
+		}, "dependency cycle: a -> b -> c -> a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager()
+			for _, c := range tt.components {
+				require.NoError(t, m.Add(c))
+			}
+			assert.ErrorContains(t, m.Start(context.Background()), tt.want)
+		})
+	}
+
+	// Duplicate and unnamed components are rejected when added
+	m := newTestManager()
+	require.NoError(t, m.Add(Component{Name: "database"}))
+	assert.Error(t, m.Add(Component{Name: "database"}))
+	assert.Error(t, m.Add(Component{}))
+}
@@ -0,0 +1,93 @@
+// Package logging configures the log output and carries request-scoped
+// loggers in contexts
+package logging
+
+import (
+	"context"
+	"gopark/config"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// contextKey stores the request-scoped logger in a context
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying entry, e.g. a logger with the
+// fields of the current request
+func NewContext(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, contextKey{}, entry)
+}
+
+// FromContext returns the logger stored in ctx, or log outside a request.
+// The entry carries ctx so that hooks can read e.g. the current span
+func FromContext(ctx context.Context, log *logrus.Logger) *logrus.Entry {
+	if entry, ok := ctx.Value(contextKey{}).(*logrus.Entry); ok {
+		return entry.WithContext(ctx)
+	}
+	return log.WithContext(ctx)
+}
+
+// AddFields returns a copy of ctx whose logger has fields added, e.g. the ID
+// of the user a request operates on. log is used outside a request
+func AddFields(ctx context.Context, log *logrus.Logger, fields logrus.Fields) context.Context {
+	return NewContext(ctx, FromContext(ctx, log).WithFields(fields))
+}
+
+// Configure sets the output format of log and, when enabled, samples
+// repeated info and debug messages. It may be called again on reload
+func Configure(log *logrus.Logger, cfg config.LogConfig) {
+	var formatter logrus.Formatter = &logrus.TextFormatter{FullTimestamp: true}
+	if cfg.Format == "json" {
+		formatter = &logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano}
+	}
+	if cfg.Sampling.Enabled {
+		formatter = newSampler(formatter, cfg.Sampling)
+	}
+	log.SetFormatter(formatter)
+}
+
+// sampler logs the first Initial occurrences of each info or debug message
+// per tick and every Thereafter-th occurrence after that. Dropped entries
+// format to nothing, so logrus writes nothing; hooks still see them
+type sampler struct {
+	logrus.Formatter
+	cfg config.LogSamplingConfig
+	now func() time.Time
+
+	mu     sync.Mutex
+	start  time.Time      // Start of the current tick
+	counts map[string]int // Occurrences in the current tick by level and message
+}
+
+// newSampler wraps formatter with sampling
+func newSampler(formatter logrus.Formatter, cfg config.LogSamplingConfig) *sampler {
+	return &sampler{Formatter: formatter, cfg: cfg, now: time.Now, counts: make(map[string]int)}
+}
+
+// Format formats entry, or returns nothing when it is sampled out
+func (s *sampler) Format(entry *logrus.Entry) ([]byte, error) {
+	if entry.Level <= logrus.WarnLevel || s.sample(entry.Level.String()+" "+entry.Message) {
+		return s.Formatter.Format(entry)
+	}
+	return nil, nil
+}
+
+// sample counts an occurrence of key and reports whether it is logged
+func (s *sampler) sample(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Counts start over every tick, which also bounds the map
+	if now := s.now(); now.Sub(s.start) >= s.cfg.Tick {
+		s.start = now
+		clear(s.counts)
+	}
+	s.counts[key]++
+	n := s.counts[key]
+	if n <= s.cfg.Initial {
+		return true
+	}
+	return s.cfg.Thereafter > 0 && (n-s.cfg.Initial)%s.cfg.Thereafter == 0
+}
@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"gopark/config"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestContext verifies loggers are carried in and added to contexts
+func TestContext(t *testing.T) {
+	var out bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&out)
+	Configure(log, config.LogConfig{Format: "json"})
+
+	// Test case 1: outside a request the fallback logger is used
+	FromContext(context.Background(), log).Info("background")
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &line))
+	assert.Equal(t, "background", line["msg"])
+	assert.NotContains(t, line, "request_id")
+
+	// Test case 2: request fields and added fields are kept
+	out.Reset()
+	ctx := NewContext(context.Background(), log.WithField("request_id", "abc"))
+	ctx = AddFields(ctx, log, logrus.Fields{"user_id": 7})
+	FromContext(ctx, log).Warn("request")
+	line = nil
+	require.NoError(t, json.Unmarshal(out.Bytes(), &line))
+	assert.Equal(t, "abc", line["request_id"])
+	assert.Equal(t, 7.0, line["user_id"])
+	assert.Equal(t, "warning", line["level"])
+}
+
+// TestSampling verifies repeated info messages are sampled per tick
+func TestSampling(t *testing.T) {
+	var out bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&out)
+	s := newSampler(&logrus.TextFormatter{DisableTimestamp: true}, config.LogSamplingConfig{Enabled: true, Initial: 2, Thereafter: 3, Tick: time.Second})
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	log.SetFormatter(s)
+
+	// Test case 1: the first occurrences and then every third are logged
+	for i := 0; i < 9; i++ {
+		log.Info("HTTP Request")
+	}
+	assert.Equal(t, 4, strings.Count(out.String(), "HTTP Request")) // 1, 2, 5 and 8
+
+	// Test case 2: other messages and warnings are counted separately
+	out.Reset()
+	log.Info("other")
+	for i := 0; i < 5; i++ {
+		log.Warn("HTTP Request")
+	}
+	assert.Equal(t, 1, strings.Count(out.String(), "other"))
+	assert.Equal(t, 5, strings.Count(out.String(), "HTTP Request"))
+
+	// Test case 3: counts start over with the next tick
+	out.Reset()
+	now = now.Add(time.Second)
+	log.Info("HTTP Request")
+	assert.Equal(t, 1, strings.Count(out.String(), "HTTP Request"))
+
+	// Test case 4: without thereafter only the first occurrences are logged
+	out.Reset()
+	s.cfg.Thereafter = 0
+	for i := 0; i < 10; i++ {
+		log.Info("HTTP Request")
+	}
+	assert.Equal(t, 1, strings.Count(out.String(), "HTTP Request")) // One earlier in this tick
+}
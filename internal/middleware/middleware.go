@@ -1,70 +1,178 @@
 package middleware
 
 import (
+	"gopark/config"
+	"gopark/internal/cors"
+	"gopark/internal/logging"
+	"gopark/internal/metrics"
+	"gopark/internal/requestid"
+	"net"
+	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// Logger records HTTP request metadata
+// Logger stores a logger with the request ID and route in the request
+// context, where handlers and queries retrieve it with logging.FromContext,
+// and writes one access log line per request with the fields they added
 func Logger(log *logrus.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Capture start time
 		startTime := time.Now()
 
+		fields := logrus.Fields{"request_id": requestid.FromContext(c.Request.Context())}
+		if route := c.FullPath(); route != "" {
+			fields["route"] = route
+		}
+		c.Request = c.Request.WithContext(logging.NewContext(c.Request.Context(), log.WithFields(fields)))
+
 		// Process request
 		c.Next()
 
-		// Capture end time
-		endTime := time.Now()
-		// Compute latency
-		latencyTime := endTime.Sub(startTime)
-
-		// Request method
-		reqMethod := c.Request.Method
-		// Request URI
-		reqURI := c.Request.RequestURI
-		// Status code
-		statusCode := c.Writer.Status()
-		// Client IP
-		clientIP := c.ClientIP()
-
-		// Log structure
-		log.WithFields(logrus.Fields{
-			"status_code":  statusCode,
-			"latency_time": latencyTime,
-			"client_ip":    clientIP,
-			"req_method":   reqMethod,
-			"req_uri":      reqURI,
-		}).Info("HTTP Request")
+		// Log structure; the trace fields come from the request context
+		entry := logging.FromContext(c.Request.Context(), log).WithFields(logrus.Fields{
+			"status_code":  c.Writer.Status(),
+			"latency_time": time.Since(startTime),
+			"client_ip":    c.ClientIP(),
+			"req_method":   c.Request.Method,
+			"req_uri":      c.Request.RequestURI,
+			"proto":        c.Request.Proto,
+			"user_agent":   c.Request.UserAgent(),
+		})
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			entry = entry.WithField("error", errs)
+		}
+		entry.Info("HTTP Request")
 	}
 }
 
-// CORS sets permissive cross-origin headers
-func CORS() gin.HandlerFunc {
+// Metrics records the count and latency of HTTP requests, labelled by the
+// matched route template rather than the raw path
+func Metrics(m *metrics.Metrics) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		start := time.Now()
+		c.Next()
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched" // 404s would otherwise add a series per path
+		}
+		m.ObserveRequest(route, c.Request.Method, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// Tracing starts a server span for each request, continuing the trace of a
+// W3C traceparent header, and carries it in the request context so that
+// handlers and queries using c.Request.Context() add child spans
+func Tracing(tp trace.TracerProvider) gin.HandlerFunc {
+	tracer := tp.Tracer("gopark/internal/middleware")
+	propagator := propagation.TraceContext{}
+	return func(c *gin.Context) {
+		ctx := propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		// Spans are named by route template, like the metrics
+		name, route := c.Request.Method, c.FullPath()
+		attrs := []attribute.KeyValue{
+			semconv.HTTPRequestMethodKey.String(c.Request.Method),
+			semconv.URLPath(c.Request.URL.Path),
+			semconv.ClientAddress(c.ClientIP()),
+			semconv.UserAgentOriginal(c.Request.UserAgent()),
+		}
+		if route != "" {
+			name += " " + route
+			attrs = append(attrs, semconv.HTTPRoute(route))
+		}
+		ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// CORS applies the policy matching the request path: preflight requests are
+// answered with 204 when allowed and 403 otherwise, and other requests get
+// the headers that expose their response to the calling origin. Policies may
+// be replaced at runtime with cors.Policies.SetConfig
+func CORS(policies *cors.Policies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy := policies.Match(c.Request.URL.Path)
+		if !policy.Enabled() {
+			c.Next()
 			return
 		}
 
+		if cors.IsPreflight(c.Request) {
+			if policy.Preflight(c.Request, c.Writer.Header()) {
+				c.AbortWithStatus(http.StatusNoContent)
+			} else {
+				c.AbortWithStatus(http.StatusForbidden)
+			}
+			return
+		}
+		policy.Actual(c.Request, c.Writer.Header())
 		c.Next()
 	}
 }
 
-// RequestID attaches a unique identifier to each request
-func RequestID() gin.HandlerFunc {
+// RequestID assigns each request an ID and echoes it in the X-Request-ID
+// response header. Callers in trustedNetworks may set it with an X-Request-ID
+// header, or with a traceparent header whose trace ID is used; everyone else
+// gets a new UUIDv7. The ID is stored in the request context, where
+// requestid.FromContext returns it, and as "RequestID" in the gin context
+func RequestID(trustedNetworks []string) gin.HandlerFunc {
+	var trusted []*net.IPNet
+	for _, s := range trustedNetworks {
+		if network, err := config.ParseNetwork(s); err == nil { // Validated with the configuration
+			trusted = append(trusted, network)
+		}
+	}
+	propagator := propagation.TraceContext{}
+
 	return func(c *gin.Context) {
-		// Derive a simple unique ID; consider UUIDs for production use
-		requestID := time.Now().UnixNano()
-		c.Set("RequestID", requestID)
-		c.Writer.Header().Set("X-Request-ID", time.Now().String())
+		var id string
+		if isTrusted(trusted, c.RemoteIP()) {
+			if incoming := c.GetHeader(requestid.Header); requestid.Valid(incoming) {
+				id = incoming
+			} else if sc := trace.SpanContextFromContext(propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))); sc.IsValid() {
+				id = sc.TraceID().String()
+			}
+		}
+		if id == "" {
+			id = requestid.New()
+		}
+
+		c.Set("RequestID", id)
+		c.Request = c.Request.WithContext(requestid.NewContext(c.Request.Context(), id))
+		c.Writer.Header().Set(requestid.Header, id)
 		c.Next()
 	}
 }
+
+// isTrusted reports whether the peer address ip is in one of networks. Peers
+// on Unix sockets have no IP address and are not trusted
+func isTrusted(networks []*net.IPNet, ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,4 @@
+-- Drop jobs table and its indexes
+DROP INDEX IF EXISTS idx_jobs_unique_key;
+DROP INDEX IF EXISTS idx_jobs_status_run_at;
+DROP TABLE IF EXISTS jobs;
@@ -0,0 +1,2 @@
+-- Drop scheduler leases table
+DROP TABLE IF EXISTS scheduler_leases;
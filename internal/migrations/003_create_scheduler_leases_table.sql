@@ -0,0 +1,7 @@
+-- Create scheduler lease table; one row per task coordinates ticks across instances
+CREATE TABLE IF NOT EXISTS scheduler_leases (
+    task TEXT PRIMARY KEY,
+    holder TEXT NOT NULL,
+    tick TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,2 @@
+-- Drop the enqueuing request ID from jobs
+ALTER TABLE jobs DROP COLUMN request_id;
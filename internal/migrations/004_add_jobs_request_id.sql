@@ -0,0 +1,2 @@
+-- Record the request that enqueued each job
+ALTER TABLE jobs ADD COLUMN request_id TEXT NOT NULL DEFAULT '';
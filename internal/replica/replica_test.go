@@ -0,0 +1,138 @@
+package replica
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"gopark/config"
+	"gopark/internal/db"
+	"gopark/internal/models"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupReplicator creates a replicated database and a replicator shipping to a temporary directory
+func setupReplicator(t *testing.T) (*db.DB, *Replicator, *LocalStorage, *logrus.Logger) {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(bytes.NewBuffer(nil)) // Disable logging output
+
+	var cfg config.Config
+	cfg.Database.Path = filepath.Join(t.TempDir(), "gopark.db")
+	cfg.Replication.Enabled = true
+	dbConn, err := db.NewDB(cfg, log)
+	require.NoError(t, err)
+	t.Cleanup(dbConn.Close)
+
+	migrations := db.NewMigrationManager(dbConn, log)
+	require.NoError(t, migrations.RunMigrations(context.Background(), "../migrations"))
+
+	storage, err := NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+
+	replicator := NewReplicator(dbConn, storage, config.ReplicationConfig{
+		SyncInterval:     time.Hour, // Syncs are driven manually by the tests
+		SnapshotInterval: time.Hour,
+		Retention:        time.Hour,
+		CheckpointFrames: 1,
+	}, log)
+	return dbConn, replicator, storage, log
+}
+
+// createUsers inserts n users with names derived from prefix
+func createUsers(t *testing.T, dbConn *db.DB, prefix string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		user := &models.User{Name: fmt.Sprintf("%s %d", prefix, i), Mail: fmt.Sprintf("%s%d@example.com", prefix, i)}
+		require.NoError(t, dbConn.CreateUser(context.Background(), user))
+	}
+}
+
+// countUsers restores the replica as of target and counts users matching prefix
+func countUsers(t *testing.T, storage Storage, target time.Time, prefix string, log *logrus.Logger) int {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "restored.db")
+	require.NoError(t, Restore(context.Background(), storage, target, out, log))
+	require.NoError(t, db.CheckIntegrity(context.Background(), out))
+
+	var cfg config.Config
+	cfg.Database.Path = out
+	restored, err := db.NewDB(cfg, log)
+	require.NoError(t, err)
+	defer restored.Close()
+
+	users, err := restored.SearchUsersByName(context.Background(), prefix)
+	require.NoError(t, err)
+	return len(users)
+}
+
+// TestPointInTimeRestore ships WAL frames across checkpoints and restores intermediate states
+func TestPointInTimeRestore(t *testing.T) {
+	dbConn, replicator, storage, log := setupReplicator(t)
+	ctx := context.Background()
+
+	createUsers(t, dbConn, "Before", 3)
+	require.NoError(t, replicator.Start(ctx))
+
+	// First batch lands in the WAL that existed when the snapshot was taken
+	createUsers(t, dbConn, "First", 5)
+	require.NoError(t, replicator.Sync(ctx))
+	afterFirst := time.Now()
+	time.Sleep(5 * time.Millisecond)
+
+	// A checkpoint lets the next writer restart the WAL
+	createUsers(t, dbConn, "Second", 5)
+	require.NoError(t, replicator.Sync(ctx))
+	createUsers(t, dbConn, "Third", 5)
+	require.NoError(t, replicator.Sync(ctx))
+	require.NoError(t, replicator.Stop(ctx))
+
+	segments, err := listSegments(ctx, storage, replicator.generation)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, len(segments), 3)
+	assert.Greater(t, segments[len(segments)-1].index, segments[0].index, "expected at least one WAL restart")
+
+	// Test case 1: the latest state contains every batch
+	assert.Equal(t, 3, countUsers(t, storage, time.Time{}, "Before", log))
+	assert.Equal(t, 5, countUsers(t, storage, time.Time{}, "Third", log))
+
+	// Test case 2: an earlier point in time excludes later batches
+	assert.Equal(t, 5, countUsers(t, storage, afterFirst, "First", log))
+	assert.Equal(t, 0, countUsers(t, storage, afterFirst, "Second", log))
+
+	// Test case 3: targets before the first generation cannot be restored
+	err = Restore(ctx, storage, afterFirst.Add(-time.Hour), filepath.Join(t.TempDir(), "early.db"), log)
+	assert.ErrorContains(t, err, "no replica generation")
+}
+
+// TestGenerations verifies new snapshots start new generations and old ones are pruned
+func TestGenerations(t *testing.T) {
+	dbConn, replicator, storage, log := setupReplicator(t)
+	ctx := context.Background()
+	replicator.cfg.Retention = 0
+
+	require.NoError(t, replicator.Start(ctx))
+	createUsers(t, dbConn, "Gen", 2)
+	require.NoError(t, replicator.Sync(ctx))
+	first := replicator.generation
+
+	time.Sleep(2 * time.Millisecond)
+	createUsers(t, dbConn, "Next", 2)
+	require.NoError(t, replicator.Snapshot(ctx))
+	require.NoError(t, replicator.Stop(ctx))
+
+	// Test case 1: the superseded generation is removed once outside retention
+	generations, err := listGenerations(ctx, storage)
+	require.NoError(t, err)
+	require.Len(t, generations, 1)
+	assert.NotEqual(t, first, generations[0].name)
+
+	// Test case 2: the new generation restores the full state
+	assert.Equal(t, 2, countUsers(t, storage, time.Time{}, "Next", log))
+	assert.Equal(t, 2, countUsers(t, storage, time.Time{}, "Gen", log))
+}
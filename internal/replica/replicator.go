@@ -0,0 +1,351 @@
+package replica
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"gopark/config"
+	"gopark/internal/db"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// errWALGap signals that the WAL restarted before all of its frames were shipped
+var errWALGap = errors.New("wal restarted without a replicator checkpoint")
+
+// position tracks how far the current WAL has been shipped
+type position struct {
+	valid    bool
+	index    int // WAL restart counter; increases every time SQLite restarts the log
+	salt1    uint32
+	salt2    uint32
+	offset   int64 // Byte offset of the next unshipped frame
+	checksum [2]uint32
+}
+
+// Replicator continuously copies committed WAL frames and periodic snapshots
+// of the database to a Storage. It must be the only process checkpointing
+// the database, which db.NewDB guarantees when replication is enabled
+type Replicator struct {
+	db      *db.DB
+	storage Storage
+	cfg     config.ReplicationConfig
+	log     *logrus.Logger
+	walPath string
+
+	mu           sync.Mutex // Serializes syncs and snapshots
+	generation   string
+	lastSnapshot time.Time
+	pos          position
+	unchecked    int  // Frames shipped since the last full checkpoint
+	checkpointed bool // A full checkpoint completed, so a WAL restart is expected
+
+	quit     chan struct{}
+	quitOnce sync.Once
+	done     chan struct{}
+}
+
+// NewReplicator creates a replicator for dbConn writing to storage
+func NewReplicator(dbConn *db.DB, storage Storage, cfg config.ReplicationConfig, log *logrus.Logger) *Replicator {
+	return &Replicator{
+		db:      dbConn,
+		storage: storage,
+		cfg:     cfg,
+		log:     log,
+		walPath: dbConn.Path + "-wal",
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start takes the initial snapshot and begins shipping WAL frames
+func (r *Replicator) Start(ctx context.Context) error {
+	if err := r.Snapshot(ctx); err != nil {
+		return err
+	}
+
+	go r.loop()
+	r.log.Infof("WAL replication started (generation %s)", r.generation)
+	return nil
+}
+
+// Stop ships any remaining frames and stops the replication loop
+func (r *Replicator) Stop(ctx context.Context) error {
+	r.quitOnce.Do(func() { close(r.quit) })
+
+	select {
+	case <-r.done:
+		r.log.Info("WAL replication stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wal replication stop incomplete: %w", ctx.Err())
+	}
+}
+
+// loop ships frames on every sync tick and rolls generations on schedule
+func (r *Replicator) loop() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.cfg.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.quit:
+			if err := r.Sync(context.Background()); err != nil {
+				r.log.Errorf("Final WAL sync failed: %v", err)
+			}
+			return
+		case <-ticker.C:
+		}
+
+		var err error
+		if time.Since(r.lastSnapshot) >= r.cfg.SnapshotInterval {
+			err = r.Snapshot(context.Background())
+		} else {
+			err = r.Sync(context.Background())
+		}
+		if err != nil {
+			r.log.Errorf("WAL replication failed: %v", err)
+		}
+	}
+}
+
+// withWriteLock runs fn while holding the database write lock so no frames
+// are appended to, or checkpointed out of, the WAL in the meantime
+func (r *Replicator) withWriteLock(ctx context.Context, fn func() error) error {
+	conn, err := r.db.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return fmt.Errorf("unable to acquire write lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "ROLLBACK")
+
+	return fn()
+}
+
+// Sync ships committed frames appended since the last sync and checkpoints
+// the WAL once enough frames are safely replicated
+func (r *Replicator) Sync(ctx context.Context) error {
+	r.mu.Lock()
+	err := r.withWriteLock(ctx, func() error {
+		if err := r.shipWAL(ctx, true); err != nil {
+			return err
+		}
+		return r.maybeCheckpoint(ctx)
+	})
+	r.mu.Unlock()
+
+	if errors.Is(err, errWALGap) {
+		r.log.Warn("WAL restarted before it was fully replicated, starting a new generation")
+		return r.Snapshot(ctx)
+	}
+	return err
+}
+
+// Snapshot ships outstanding frames to the current generation and starts a new
+// generation from a page-for-page copy of the database
+func (r *Replicator) Snapshot(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	generation := time.Now().UTC().Format(generationLayout)
+	err := r.withWriteLock(ctx, func() error {
+		if r.generation != "" {
+			if err := r.shipWAL(ctx, true); err != nil && !errors.Is(err, errWALGap) {
+				return err
+			}
+		}
+
+		// Record the WAL end without shipping; the snapshot already contains those frames
+		r.pos = position{index: r.pos.index}
+		if err := r.shipWAL(ctx, false); err != nil {
+			return err
+		}
+
+		tmp := filepath.Join(os.TempDir(), fmt.Sprintf("gopark-snapshot-%s.db", generation))
+		defer os.Remove(tmp)
+		if err := r.db.BackupTo(ctx, tmp); err != nil {
+			return err
+		}
+		return r.putCompressedFile(ctx, snapshotKey(generation), tmp)
+	})
+	if err != nil {
+		return fmt.Errorf("snapshot failed: %w", err)
+	}
+
+	r.generation = generation
+	r.lastSnapshot = time.Now()
+	r.log.Infof("Replica snapshot written for generation %s", generation)
+
+	if err := r.prune(ctx); err != nil {
+		r.log.Errorf("Failed to prune replica generations: %v", err)
+	}
+	return nil
+}
+
+// shipWAL uploads committed frames past the current position; with upload
+// disabled it only advances the position to the end of the committed WAL
+func (r *Replicator) shipWAL(ctx context.Context, upload bool) error {
+	f, err := os.Open(r.walPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < walHeaderSize {
+		return nil
+	}
+
+	headerBytes := make([]byte, walHeaderSize)
+	if _, err := f.ReadAt(headerBytes, 0); err != nil {
+		return err
+	}
+	header, err := parseWALHeader(headerBytes)
+	if err != nil {
+		return err
+	}
+
+	if !r.pos.valid || header.salt1 != r.pos.salt1 || header.salt2 != r.pos.salt2 || info.Size() < r.pos.offset {
+		if r.pos.valid && !r.checkpointed {
+			return errWALGap
+		}
+		index := r.pos.index
+		if r.pos.valid {
+			index++
+		}
+		r.pos = position{
+			valid:    true,
+			index:    index,
+			salt1:    header.salt1,
+			salt2:    header.salt2,
+			offset:   walHeaderSize,
+			checksum: header.checksum,
+		}
+		r.checkpointed = false
+		r.unchecked = 0
+	}
+
+	data := make([]byte, info.Size()-r.pos.offset)
+	if _, err := f.ReadAt(data, r.pos.offset); err != nil && err != io.EOF {
+		return err
+	}
+
+	n, checksum := scanFrames(header, data, r.pos.checksum)
+	if n == 0 {
+		return nil
+	}
+
+	if upload {
+		key := walKey(r.generation, r.pos.index, r.pos.offset, time.Now())
+		if err := r.putCompressed(ctx, key, data[:n]); err != nil {
+			return err
+		}
+		r.unchecked += n / (walFrameHeaderSize + header.pageSize)
+		r.log.Debugf("Shipped %d WAL bytes at offset %d (index %d)", n, r.pos.offset, r.pos.index)
+	}
+
+	r.pos.offset += int64(n)
+	r.pos.checksum = checksum
+	return nil
+}
+
+// maybeCheckpoint copies replicated frames into the database file so SQLite
+// can restart the WAL; it runs while the write lock is held
+func (r *Replicator) maybeCheckpoint(ctx context.Context) error {
+	if r.unchecked < r.cfg.CheckpointFrames {
+		return nil
+	}
+
+	var busy, logFrames, checkpointed int
+	err := r.db.QueryRowContext(ctx, "PRAGMA wal_checkpoint(PASSIVE)").Scan(&busy, &logFrames, &checkpointed)
+	if err != nil {
+		return fmt.Errorf("checkpoint failed: %w", err)
+	}
+
+	// Readers on older snapshots can hold back a full checkpoint; retry on the next sync
+	if checkpointed == logFrames {
+		r.checkpointed = true
+		r.unchecked = 0
+		r.log.Debugf("Checkpointed %d replicated WAL frames", checkpointed)
+	}
+	return nil
+}
+
+// putCompressed gzips data and stores it under key
+func (r *Replicator) putCompressed(ctx context.Context, key string, data []byte) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return r.storage.Put(ctx, key, &buf)
+}
+
+// putCompressedFile streams a gzipped copy of the file at path to key
+func (r *Replicator) putCompressedFile(ctx context.Context, key, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		gz := gzip.NewWriter(pw)
+		_, err := io.Copy(gz, f)
+		if err == nil {
+			err = gz.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return r.storage.Put(ctx, key, pr)
+}
+
+// prune deletes generations that ended before the retention window
+func (r *Replicator) prune(ctx context.Context) error {
+	generations, err := listGenerations(ctx, r.storage)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-r.cfg.Retention)
+	for i := 0; i+1 < len(generations); i++ {
+		// A generation is needed until its successor covers the whole window
+		if generations[i+1].start.After(cutoff) {
+			break
+		}
+
+		keys, err := r.storage.List(ctx, generationPrefix(generations[i].name))
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := r.storage.Delete(ctx, key); err != nil {
+				return err
+			}
+		}
+		r.log.Infof("Removed expired replica generation %s", generations[i].name)
+	}
+	return nil
+}
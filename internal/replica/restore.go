@@ -0,0 +1,224 @@
+package replica
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"gopark/internal/db"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Replica layout:
+//
+//	generations/<generation>/snapshot.db.gz
+//	generations/<generation>/wal/<index>-<offset>-<unix nanos>.wal.gz
+const (
+	generationLayout = "20060102T150405.000000000Z"
+	generationsRoot  = "generations/"
+)
+
+// generation is a snapshot plus the WAL segments shipped after it
+type generation struct {
+	name  string
+	start time.Time
+}
+
+// segment is a run of complete WAL transactions shipped at one sync
+type segment struct {
+	key     string
+	index   int
+	offset  int64
+	shipped time.Time
+}
+
+func generationPrefix(name string) string { return generationsRoot + name + "/" }
+
+func snapshotKey(name string) string { return generationPrefix(name) + "snapshot.db.gz" }
+
+func walKey(name string, index int, offset int64, shipped time.Time) string {
+	return fmt.Sprintf("%swal/%08d-%012d-%d.wal.gz", generationPrefix(name), index, offset, shipped.UnixNano())
+}
+
+// listGenerations returns generations that have a snapshot, oldest first
+func listGenerations(ctx context.Context, storage Storage) ([]generation, error) {
+	keys, err := storage.List(ctx, generationsRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var generations []generation
+	for _, key := range keys {
+		name := strings.TrimSuffix(strings.TrimPrefix(key, generationsRoot), "/snapshot.db.gz")
+		if name == key || strings.Contains(name, "/") {
+			continue
+		}
+		start, err := time.Parse(generationLayout, name)
+		if err != nil {
+			continue
+		}
+		generations = append(generations, generation{name: name, start: start})
+	}
+
+	sort.Slice(generations, func(i, j int) bool { return generations[i].start.Before(generations[j].start) })
+	return generations, nil
+}
+
+// listSegments returns the WAL segments of a generation in replay order
+func listSegments(ctx context.Context, storage Storage, name string) ([]segment, error) {
+	prefix := generationPrefix(name) + "wal/"
+	keys, err := storage.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []segment
+	for _, key := range keys {
+		parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(key, prefix), ".wal.gz"), "-")
+		if len(parts) != 3 {
+			continue
+		}
+		index, err1 := strconv.Atoi(parts[0])
+		offset, err2 := strconv.ParseInt(parts[1], 10, 64)
+		nanos, err3 := strconv.ParseInt(parts[2], 10, 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			return nil, fmt.Errorf("malformed wal segment key %s", key)
+		}
+		segments = append(segments, segment{key: key, index: index, offset: offset, shipped: time.Unix(0, nanos)})
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		if segments[i].index != segments[j].index {
+			return segments[i].index < segments[j].index
+		}
+		return segments[i].offset < segments[j].offset
+	})
+	return segments, nil
+}
+
+// Restore rebuilds the database as of target (the latest state when target is
+// zero) into a new file at outPath from the newest generation that started
+// before target, replaying every WAL segment shipped up to target
+func Restore(ctx context.Context, storage Storage, target time.Time, outPath string, log *logrus.Logger) error {
+	if target.IsZero() {
+		target = time.Now()
+	}
+
+	generations, err := listGenerations(ctx, storage)
+	if err != nil {
+		return err
+	}
+
+	var gen *generation
+	for i := range generations {
+		if !generations[i].start.After(target) {
+			gen = &generations[i]
+		}
+	}
+	if gen == nil {
+		return fmt.Errorf("no replica generation covers %s", target.Format(time.RFC3339))
+	}
+
+	out, err := os.OpenFile(outPath, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0640)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := readCompressed(ctx, storage, snapshotKey(gen.name), out); err != nil {
+		return fmt.Errorf("failed to restore snapshot %s: %w", gen.name, err)
+	}
+
+	pageSize, err := databasePageSize(out)
+	if err != nil {
+		return err
+	}
+
+	segments, err := listSegments(ctx, storage, gen.name)
+	if err != nil {
+		return err
+	}
+
+	applied := 0
+	var prevIndex int
+	var prevEnd int64 = -1
+	for _, seg := range segments {
+		if seg.shipped.After(target) {
+			break
+		}
+		// Segments continue where the previous one ended, or start a restarted WAL
+		contiguous := seg.offset == prevEnd
+		if seg.index != prevIndex {
+			contiguous = seg.offset == walHeaderSize
+		}
+		if prevEnd >= 0 && !contiguous {
+			return fmt.Errorf("replica is missing wal frames before %s", seg.key)
+		}
+
+		var buf bytes.Buffer
+		if err := readCompressed(ctx, storage, seg.key, &buf); err != nil {
+			return fmt.Errorf("failed to read %s: %w", seg.key, err)
+		}
+		if err := applyFrames(out, buf.Bytes(), pageSize); err != nil {
+			return fmt.Errorf("failed to apply %s: %w", seg.key, err)
+		}
+
+		prevIndex, prevEnd = seg.index, seg.offset+int64(buf.Len())
+		applied++
+	}
+
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	// Replayed frames may carry the WAL-mode header of the source database
+	if err := db.DisableWAL(ctx, outPath); err != nil {
+		return err
+	}
+
+	log.Infof("Rebuilt database as of %s from generation %s and %d wal segments", target.Format(time.RFC3339), gen.name, applied)
+	return nil
+}
+
+// readCompressed copies the gunzipped object stored under key into w
+func readCompressed(ctx context.Context, storage Storage, key string, w io.Writer) error {
+	rc, err := storage.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	gz, err := gzip.NewReader(rc)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	_, err = io.Copy(w, gz)
+	return err
+}
+
+// databasePageSize reads the page size from the SQLite database header
+func databasePageSize(f *os.File) (int, error) {
+	header := make([]byte, 2)
+	if _, err := f.ReadAt(header, 16); err != nil {
+		return 0, fmt.Errorf("unable to read database header: %w", err)
+	}
+
+	pageSize := int(binary.BigEndian.Uint16(header))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	return pageSize, nil
+}
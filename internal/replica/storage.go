@@ -0,0 +1,118 @@
+package replica
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Storage persists replica objects under slash-separated keys
+type Storage interface {
+	// Put stores the content of r under key, replacing any existing object
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get opens the object stored under key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List returns the sorted keys that start with prefix
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Delete removes the object stored under key
+	Delete(ctx context.Context, key string) error
+}
+
+// LocalStorage stores replica objects as files below a directory
+type LocalStorage struct {
+	root string
+}
+
+// NewLocalStorage creates a Storage rooted at dir
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create replica directory: %w", err)
+	}
+	return &LocalStorage{root: filepath.Clean(dir)}, nil
+}
+
+// filePath maps a key to its location on disk
+func (s *LocalStorage) filePath(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(path.Clean("/"+key)))
+}
+
+// Put writes to a temporary file and renames it so readers never see partial objects
+func (s *LocalStorage) Put(ctx context.Context, key string, r io.Reader) error {
+	dest := s.filePath(key)
+	if err := os.MkdirAll(filepath.Dir(dest), 0750); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// Get opens the file stored under key
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(s.filePath(key))
+}
+
+// List walks the directory and returns matching keys in sorted order
+func (s *LocalStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(s.root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Delete removes the file stored under key and prunes empty parent directories
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	p := s.filePath(key)
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for dir := filepath.Dir(p); dir != s.root && strings.HasPrefix(dir, s.root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
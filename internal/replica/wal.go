@@ -0,0 +1,151 @@
+package replica
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// SQLite WAL layout, see https://www.sqlite.org/fileformat2.html#walformat
+const (
+	walHeaderSize      = 32
+	walFrameHeaderSize = 24
+	walMagicLE         = 0x377f0682 // Checksums use little-endian words
+	walMagicBE         = 0x377f0683 // Checksums use big-endian words
+)
+
+// walHeader is the decoded 32-byte WAL file header
+type walHeader struct {
+	bigEndian bool
+	pageSize  int
+	salt1     uint32
+	salt2     uint32
+	checksum  [2]uint32
+}
+
+// parseWALHeader decodes and verifies a WAL header
+func parseWALHeader(b []byte) (walHeader, error) {
+	if len(b) < walHeaderSize {
+		return walHeader{}, errors.New("wal header too short")
+	}
+
+	var h walHeader
+	switch binary.BigEndian.Uint32(b[0:4]) {
+	case walMagicLE:
+	case walMagicBE:
+		h.bigEndian = true
+	default:
+		return walHeader{}, errors.New("invalid wal magic number")
+	}
+
+	h.pageSize = int(binary.BigEndian.Uint32(b[8:12]))
+	if h.pageSize == 1 {
+		h.pageSize = 65536 // Encoded as 1 because 65536 does not fit the database header field
+	}
+	h.salt1 = binary.BigEndian.Uint32(b[16:20])
+	h.salt2 = binary.BigEndian.Uint32(b[20:24])
+	h.checksum = [2]uint32{binary.BigEndian.Uint32(b[24:28]), binary.BigEndian.Uint32(b[28:32])}
+
+	if walChecksum(h.bigEndian, [2]uint32{}, b[0:24]) != h.checksum {
+		return walHeader{}, errors.New("wal header checksum mismatch")
+	}
+	return h, nil
+}
+
+// walFrame is a decoded WAL frame header
+type walFrame struct {
+	pgno     uint32
+	commit   uint32 // Database size in pages after a commit frame, zero otherwise
+	salt1    uint32
+	salt2    uint32
+	checksum [2]uint32
+}
+
+// parseWALFrame decodes a 24-byte WAL frame header
+func parseWALFrame(b []byte) walFrame {
+	return walFrame{
+		pgno:     binary.BigEndian.Uint32(b[0:4]),
+		commit:   binary.BigEndian.Uint32(b[4:8]),
+		salt1:    binary.BigEndian.Uint32(b[8:12]),
+		salt2:    binary.BigEndian.Uint32(b[12:16]),
+		checksum: [2]uint32{binary.BigEndian.Uint32(b[16:20]), binary.BigEndian.Uint32(b[20:24])},
+	}
+}
+
+// walChecksum computes the cumulative SQLite WAL checksum over b, seeded with prev
+func walChecksum(bigEndian bool, prev [2]uint32, b []byte) [2]uint32 {
+	order := binary.ByteOrder(binary.LittleEndian)
+	if bigEndian {
+		order = binary.BigEndian
+	}
+
+	s0, s1 := prev[0], prev[1]
+	for i := 0; i+8 <= len(b); i += 8 {
+		s0 += order.Uint32(b[i:]) + s1
+		s1 += order.Uint32(b[i+4:]) + s0
+	}
+	return [2]uint32{s0, s1}
+}
+
+// scanFrames walks frames in data (which starts at a frame boundary) and returns
+// the length of the prefix ending at the last valid commit frame together with
+// the running checksum at that point. Frames from an older WAL generation,
+// torn writes and uncommitted tails are excluded
+func scanFrames(h walHeader, data []byte, checksum [2]uint32) (int, [2]uint32) {
+	frameSize := walFrameHeaderSize + h.pageSize
+	committed, committedChecksum := 0, checksum
+
+	for off := 0; off+frameSize <= len(data); off += frameSize {
+		frame := parseWALFrame(data[off : off+walFrameHeaderSize])
+		if frame.salt1 != h.salt1 || frame.salt2 != h.salt2 {
+			break
+		}
+
+		checksum = walChecksum(h.bigEndian, checksum, data[off:off+8])
+		checksum = walChecksum(h.bigEndian, checksum, data[off+walFrameHeaderSize:off+frameSize])
+		if checksum != frame.checksum {
+			break
+		}
+
+		if frame.commit != 0 {
+			committed, committedChecksum = off+frameSize, checksum
+		}
+	}
+	return committed, committedChecksum
+}
+
+// applyFrames writes the pages of complete transactions in data onto the
+// database file f, truncating it to the size recorded in each commit frame
+func applyFrames(f *os.File, data []byte, pageSize int) error {
+	frameSize := walFrameHeaderSize + pageSize
+	if len(data)%frameSize != 0 {
+		return fmt.Errorf("wal segment length %d is not a multiple of the frame size %d", len(data), frameSize)
+	}
+
+	var pending []int // Offsets of frames in the open transaction
+	for off := 0; off < len(data); off += frameSize {
+		pending = append(pending, off)
+		frame := parseWALFrame(data[off : off+walFrameHeaderSize])
+		if frame.commit == 0 {
+			continue
+		}
+
+		for _, frameOff := range pending {
+			pgno := parseWALFrame(data[frameOff : frameOff+walFrameHeaderSize]).pgno
+			page := data[frameOff+walFrameHeaderSize : frameOff+frameSize]
+			if _, err := f.WriteAt(page, int64(pgno-1)*int64(pageSize)); err != nil {
+				return err
+			}
+		}
+		if err := f.Truncate(int64(frame.commit) * int64(pageSize)); err != nil {
+			return err
+		}
+		pending = pending[:0]
+	}
+
+	if len(pending) > 0 {
+		return errors.New("wal segment ends with an uncommitted transaction")
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+// Package requestid generates request IDs and carries them in contexts, so
+// that logs, error responses, jobs and events of a request can be correlated
+package requestid
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// Header carries the request ID in requests from trusted callers and in
+// every response
+const Header = "X-Request-ID"
+
+// maxLength bounds IDs accepted from callers
+const maxLength = 128
+
+// contextKey stores the request ID in a context
+type contextKey struct{}
+
+// New returns a UUIDv7, which is unique and sorts by creation time
+func New() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.NewString() // Only fails if the random source does
+	}
+	return id.String()
+}
+
+// Valid reports whether id may be accepted from a caller: 1 to 128 letters,
+// digits and the characters - _ . : so that it is safe in logs and headers
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext returns a copy of ctx carrying id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID in ctx, or "" outside a request
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
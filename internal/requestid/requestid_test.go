@@ -0,0 +1,42 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNew verifies generated IDs are distinct, time-ordered UUIDv7s
+func TestNew(t *testing.T) {
+	seen := make(map[string]bool)
+	previous := ""
+	for i := 0; i < 1000; i++ {
+		id := New()
+		parsed, err := uuid.Parse(id)
+		require.NoError(t, err)
+		assert.Equal(t, uuid.Version(7), parsed.Version())
+		assert.False(t, seen[id])
+		assert.Greater(t, id, previous)
+		seen[id], previous = true, id
+	}
+}
+
+// TestValid covers the IDs accepted from callers
+func TestValid(t *testing.T) {
+	for _, id := range []string{New(), "abc", "req-1_2.3:4", strings.Repeat("a", 128)} {
+		assert.True(t, Valid(id), id)
+	}
+	for _, id := range []string{"", "a b", "line\nbreak", "<script>", "ä", strings.Repeat("a", 129)} {
+		assert.False(t, Valid(id), id)
+	}
+}
+
+// TestContext verifies IDs are carried in contexts
+func TestContext(t *testing.T) {
+	assert.Empty(t, FromContext(context.Background()))
+	assert.Equal(t, "abc", FromContext(NewContext(context.Background(), "abc")))
+}
@@ -1,25 +1,73 @@
 package routes
 
 import (
+	"gopark/config"
+	"gopark/internal/backup"
+	"gopark/internal/cors"
 	"gopark/internal/db"
+	"gopark/internal/events"
 	"gopark/internal/handlers"
+	"gopark/internal/health"
+	"gopark/internal/jobs"
+	"gopark/internal/metrics"
 	"gopark/internal/middleware"
+	"gopark/internal/scheduler"
+	"net/http/pprof"
+
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// SetupRoutes configures and registers all application routes
-func SetupRoutes(r *gin.Engine, log *logrus.Logger, dbConn *db.DB) {
+// Dependencies bundles the services used by route handlers
+type Dependencies struct {
+	Log        *logrus.Logger
+	RequestID  config.RequestIDConfig
+	CORS       *cors.Policies // Cross-origin policies for the public routes; no CORS headers when unset
+	DB         *db.DB
+	Users      handlers.UserStore // User storage; defaults to DB
+	Events     events.Publisher
+	Health     *health.Registry     // Checks behind the health probes; none when unset
+	Metrics    *metrics.Metrics     // HTTP metrics and the admin /metrics route; disabled when unset
+	Tracing    trace.TracerProvider // Server spans for the public routes; disabled when unset
+	Jobs       *jobs.Queue          // Admin job routes are registered only when set
+	Scheduler  *scheduler.Scheduler // Admin scheduler route is registered only when set
+	Backups    *backup.Manager      // Admin backup routes are registered only when set
+	Middleware []gin.HandlerFunc    // Extra public middleware, run after the built-in middleware
+
+	AdminMiddleware []gin.HandlerFunc // Extra admin middleware, run after the built-in middleware
+}
+
+// SetupRoutes registers the public API routes
+func SetupRoutes(r *gin.Engine, deps Dependencies) {
+	log := deps.Log
+
 	// Register global middleware
-	r.Use(middleware.RequestID())
+	r.Use(middleware.RequestID(deps.RequestID.TrustedNetworks))
+	if deps.Tracing != nil {
+		r.Use(middleware.Tracing(deps.Tracing)) // Before Logger so access logs carry the trace ID
+	}
 	r.Use(middleware.Logger(log))
-	r.Use(middleware.CORS())
+	if deps.Metrics != nil {
+		r.Use(middleware.Metrics(deps.Metrics))
+	}
+	if deps.CORS != nil {
+		r.Use(middleware.CORS(deps.CORS))
+	}
+	r.Use(deps.Middleware...)
 
 	// Create handler instances
-	userHandler := handlers.NewUserHandler(log, dbConn)
+	users := deps.Users
+	if users == nil {
+		users = deps.DB
+	}
+	userHandler := handlers.NewUserHandler(log, users, deps.Events)
 
-	// Health check route without API versioning
-	r.GET("/health", handlers.HealthCheckHandler)
+	// Health probes without API versioning; /health is kept for existing probes
+	healthHandler := handlers.NewHealthHandler(healthRegistry(deps), false)
+	r.GET("/livez", healthHandler.Livez)
+	r.GET("/readyz", healthHandler.Readyz)
+	r.GET("/health", healthHandler.Readyz)
 
 	// API v1 route group
 	v1 := r.Group("/api/v1")
@@ -43,3 +91,64 @@ func SetupRoutes(r *gin.Engine, log *logrus.Logger, dbConn *db.DB) {
 	r.PUT("/user/:id", userHandler.UpdateUser)
 	r.DELETE("/user/:id", userHandler.DeleteUser)
 }
+
+// SetupAdminRoutes registers the administrative and debugging routes, which
+// are served on the admin listener only
+func SetupAdminRoutes(r *gin.Engine, deps Dependencies) {
+	log := deps.Log
+
+	r.Use(middleware.RequestID(deps.RequestID.TrustedNetworks))
+	r.Use(middleware.Logger(log))
+	r.Use(deps.AdminMiddleware...)
+
+	// Health probes with every check result
+	healthHandler := handlers.NewHealthHandler(healthRegistry(deps), true)
+	r.GET("/livez", healthHandler.Livez)
+	r.GET("/readyz", healthHandler.Readyz)
+	r.GET("/health", healthHandler.Readyz)
+
+	if deps.Metrics != nil {
+		r.GET("/metrics", gin.WrapH(deps.Metrics.Handler())) // Prometheus scrape endpoint
+	}
+
+	// Profiling - /debug/pprof/
+	debug := r.Group("/debug/pprof")
+	{
+		debug.GET("/", gin.WrapF(pprof.Index))
+		debug.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+		debug.GET("/profile", gin.WrapF(pprof.Profile))
+		debug.GET("/symbol", gin.WrapF(pprof.Symbol))
+		debug.POST("/symbol", gin.WrapF(pprof.Symbol))
+		debug.GET("/trace", gin.WrapF(pprof.Trace))
+		debug.GET("/:profile", gin.WrapF(pprof.Index)) // Named profiles such as heap and goroutine
+	}
+
+	admin := r.Group("/api/v1/admin")
+	if deps.Jobs != nil {
+		jobHandler := handlers.NewJobHandler(log, deps.Jobs)
+		jobsGroup := admin.Group("/jobs")
+		{
+			jobsGroup.GET("", jobHandler.ListJobs)              // List jobs - /api/v1/admin/jobs?status=failed
+			jobsGroup.GET("/:id", jobHandler.GetJob)            // Get job - /api/v1/admin/jobs/1
+			jobsGroup.POST("/:id/retry", jobHandler.RetryJob)   // Retry job - /api/v1/admin/jobs/1/retry
+			jobsGroup.POST("/:id/cancel", jobHandler.CancelJob) // Cancel job - /api/v1/admin/jobs/1/cancel
+		}
+	}
+	if deps.Scheduler != nil {
+		schedulerHandler := handlers.NewSchedulerHandler(log, deps.Scheduler)
+		admin.GET("/scheduler", schedulerHandler.GetStatus) // Scheduler status - /api/v1/admin/scheduler
+	}
+	if deps.Backups != nil {
+		backupHandler := handlers.NewBackupHandler(log, deps.Backups)
+		admin.GET("/backups", backupHandler.ListBackups)   // List backups - /api/v1/admin/backups
+		admin.POST("/backups", backupHandler.CreateBackup) // Create backup - /api/v1/admin/backups
+	}
+}
+
+// healthRegistry returns the registry of deps, or an empty one
+func healthRegistry(deps Dependencies) *health.Registry {
+	if deps.Health != nil {
+		return deps.Health
+	}
+	return health.NewRegistry(config.HealthConfig{}, deps.Log)
+}
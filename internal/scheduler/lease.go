@@ -0,0 +1,32 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+)
+
+// acquireLease claims the lease for a task tick; it fails when another instance
+// already ran this tick or still holds an unexpired lease from an earlier one
+func (s *Scheduler) acquireLease(ctx context.Context, task string, tick time.Time) (bool, error) {
+	now := time.Now().UTC()
+	query := `INSERT INTO scheduler_leases (task, holder, tick, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (task) DO UPDATE SET holder = excluded.holder, tick = excluded.tick, expires_at = excluded.expires_at
+		WHERE scheduler_leases.tick < excluded.tick AND scheduler_leases.expires_at <= ?`
+	result, err := s.db.ExecContext(ctx, query, task, s.instanceID, tick.UTC(), now.Add(s.cfg.LeaseTTL), now)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+// releaseLease expires a lease held by this instance so the next tick can be claimed immediately
+func (s *Scheduler) releaseLease(ctx context.Context, task string, tick time.Time) error {
+	query := `UPDATE scheduler_leases SET expires_at = ? WHERE task = ? AND holder = ? AND tick = ?`
+	_, err := s.db.ExecContext(ctx, query, time.Now().UTC(), task, s.instanceID, tick.UTC())
+	return err
+}
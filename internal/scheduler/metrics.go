@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collector exposes the task statuses as metrics labelled by task, read at
+// scrape time so they always agree with the admin status endpoint
+type collector struct {
+	scheduler   *Scheduler
+	runs        *prometheus.Desc
+	failures    *prometheus.Desc
+	skipped     *prometheus.Desc
+	lastSuccess *prometheus.Desc
+	nextRun     *prometheus.Desc
+}
+
+// RegisterMetrics exposes the run, failure and skip counters of each task
+// and the times of its last successful and next run through reg
+func (s *Scheduler) RegisterMetrics(reg prometheus.Registerer) error {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName("gopark", "scheduler", name), help, []string{"task"}, nil)
+	}
+	return reg.Register(&collector{
+		scheduler:   s,
+		runs:        desc("runs_total", "Completed runs of a scheduled task on this instance."),
+		failures:    desc("failures_total", "Runs of a scheduled task that returned an error."),
+		skipped:     desc("skipped_total", "Ticks skipped because the previous run was in progress or another instance held the lease."),
+		lastSuccess: desc("last_success_timestamp_seconds", "Unix time the last successful run on this instance started."),
+		nextRun:     desc("next_run_timestamp_seconds", "Unix time of the next tick of a scheduled task."),
+	})
+}
+
+// Describe implements prometheus.Collector
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{c.runs, c.failures, c.skipped, c.lastSuccess, c.nextRun} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	for _, status := range c.scheduler.Status() {
+		ch <- prometheus.MustNewConstMetric(c.runs, prometheus.CounterValue, float64(status.Runs), status.Name)
+		ch <- prometheus.MustNewConstMetric(c.failures, prometheus.CounterValue, float64(status.Failures), status.Name)
+		ch <- prometheus.MustNewConstMetric(c.skipped, prometheus.CounterValue, float64(status.Skipped), status.Name)
+		if status.LastSuccessAt != nil {
+			ch <- prometheus.MustNewConstMetric(c.lastSuccess, prometheus.GaugeValue, float64(status.LastSuccessAt.Unix()), status.Name)
+		}
+		if !status.NextRunAt.IsZero() {
+			ch <- prometheus.MustNewConstMetric(c.nextRun, prometheus.GaugeValue, float64(status.NextRunAt.Unix()), status.Name)
+		}
+	}
+}
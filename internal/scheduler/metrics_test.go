@@ -0,0 +1,78 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopark/config"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMetrics verifies the task counters and timestamps are exported
+func TestMetrics(t *testing.T) {
+	dbConn, log := setupDB(t)
+	s := newTestScheduler(dbConn, log, "a", config.ScheduledTask{Name: "count", Schedule: "@hourly"})
+	other := newTestScheduler(dbConn, log, "b")
+	reg := prometheus.NewRegistry()
+	require.NoError(t, s.RegisterMetrics(reg))
+
+	var fail error
+	s.Register("count", func(ctx context.Context) error { return fail })
+
+	// Test case 1: nothing is reported before Start
+	assert.Equal(t, 0, testutil.CollectAndCount(reg))
+
+	require.NoError(t, s.Start())
+	defer s.Stop(context.Background())
+	task := s.tasks[0]
+	tick := time.Now().Truncate(time.Minute).UTC()
+
+	// Test case 2: a successful and a failing run are counted, and the last
+	// success keeps its time
+	s.wg.Add(1)
+	s.run(task, tick)
+	fail = errors.New("run failed")
+	s.wg.Add(1)
+	s.run(task, tick.Add(time.Minute))
+
+	// Test case 3: ticks skipped because of a lease held elsewhere or an
+	// overlapping run are counted
+	acquired, err := other.acquireLease(context.Background(), "count", tick.Add(2*time.Minute))
+	require.NoError(t, err)
+	require.True(t, acquired)
+	s.wg.Add(1)
+	s.run(task, tick.Add(2*time.Minute))
+	task.mu.Lock()
+	task.status.Running = true
+	task.mu.Unlock()
+	s.dispatch(task, tick.Add(3*time.Minute))
+
+	require.Eventually(t, func() bool { return !s.Status()[0].NextRunAt.IsZero() }, time.Second, 10*time.Millisecond)
+	status := s.Status()[0]
+	require.NotNil(t, status.LastSuccessAt)
+	expected := fmt.Sprintf(`
+# HELP gopark_scheduler_runs_total Completed runs of a scheduled task on this instance.
+# TYPE gopark_scheduler_runs_total counter
+gopark_scheduler_runs_total{task="count"} 2
+# HELP gopark_scheduler_failures_total Runs of a scheduled task that returned an error.
+# TYPE gopark_scheduler_failures_total counter
+gopark_scheduler_failures_total{task="count"} 1
+# HELP gopark_scheduler_skipped_total Ticks skipped because the previous run was in progress or another instance held the lease.
+# TYPE gopark_scheduler_skipped_total counter
+gopark_scheduler_skipped_total{task="count"} 2
+# HELP gopark_scheduler_last_success_timestamp_seconds Unix time the last successful run on this instance started.
+# TYPE gopark_scheduler_last_success_timestamp_seconds gauge
+gopark_scheduler_last_success_timestamp_seconds{task="count"} %d
+# HELP gopark_scheduler_next_run_timestamp_seconds Unix time of the next tick of a scheduled task.
+# TYPE gopark_scheduler_next_run_timestamp_seconds gauge
+gopark_scheduler_next_run_timestamp_seconds{task="count"} %d
+`, status.LastSuccessAt.Unix(), status.NextRunAt.Unix())
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected)))
+}
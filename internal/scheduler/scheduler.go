@@ -0,0 +1,279 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"gopark/config"
+	"gopark/internal/db"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// TaskFunc performs one run of a periodic task
+type TaskFunc func(ctx context.Context) error
+
+// TaskStatus reports the state and counters of a scheduled task
+type TaskStatus struct {
+	Name           string     `json:"name"`
+	Schedule       string     `json:"schedule"`
+	Running        bool       `json:"running"`
+	LastRunAt      *time.Time `json:"last_run_at,omitempty"`
+	LastSuccessAt  *time.Time `json:"last_success_at,omitempty"`
+	LastDurationMs int64      `json:"last_duration_ms"`
+	LastError      string     `json:"last_error,omitempty"`
+	NextRunAt      time.Time  `json:"next_run_at"`
+	Runs           int64      `json:"runs"`     // Completed runs on this instance
+	Failures       int64      `json:"failures"` // Runs that returned an error
+	Skipped        int64      `json:"skipped"`  // Ticks skipped due to overlap or a lease held elsewhere
+}
+
+// task is a registered function bound to its schedule
+type task struct {
+	name     string
+	schedule cron.Schedule
+	fn       TaskFunc
+
+	mu     sync.Mutex
+	status TaskStatus
+}
+
+// Scheduler runs registered tasks on cron schedules, coordinating ticks
+// across instances that share the database through lease rows
+type Scheduler struct {
+	db         *db.DB
+	cfg        config.SchedulerConfig
+	log        *logrus.Logger
+	instanceID string
+	funcs      map[string]TaskFunc
+
+	mu    sync.Mutex // Guards tasks, which Status reads before Start
+	tasks []*task
+
+	quit      chan struct{}
+	quitOnce  sync.Once
+	wg        sync.WaitGroup
+	runCtx    context.Context
+	cancelRun context.CancelFunc
+}
+
+// NewScheduler creates a scheduler using the lease table in dbConn
+func NewScheduler(dbConn *db.DB, cfg config.SchedulerConfig, log *logrus.Logger) *Scheduler {
+	instanceID := cfg.InstanceID
+	if instanceID == "" {
+		hostname, _ := os.Hostname()
+		instanceID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	runCtx, cancelRun := context.WithCancel(context.Background())
+	return &Scheduler{
+		db:         dbConn,
+		cfg:        cfg,
+		log:        log,
+		instanceID: instanceID,
+		funcs:      make(map[string]TaskFunc),
+		quit:       make(chan struct{}),
+		runCtx:     runCtx,
+		cancelRun:  cancelRun,
+	}
+}
+
+// Register makes a task available to be scheduled from configuration; call before Start
+func (s *Scheduler) Register(name string, fn TaskFunc) {
+	s.funcs[name] = fn
+}
+
+// Start parses the configured schedules and launches a loop per task
+func (s *Scheduler) Start() error {
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	var tasks []*task
+	for _, entry := range s.cfg.Tasks {
+		if entry.Disabled {
+			s.log.Infof("Scheduled task %s disabled", entry.Name)
+			continue
+		}
+
+		fn, ok := s.funcs[entry.Name]
+		if !ok {
+			return fmt.Errorf("unknown scheduled task %q", entry.Name)
+		}
+
+		schedule, err := parser.Parse(entry.Schedule)
+		if err != nil {
+			return fmt.Errorf("invalid schedule %q for task %s: %w", entry.Schedule, entry.Name, err)
+		}
+
+		tasks = append(tasks, &task{
+			name:     entry.Name,
+			schedule: schedule,
+			fn:       fn,
+			status:   TaskStatus{Name: entry.Name, Schedule: entry.Schedule},
+		})
+	}
+
+	s.mu.Lock()
+	s.tasks = tasks
+	s.mu.Unlock()
+	for _, t := range tasks {
+		s.wg.Add(1)
+		go s.loop(t)
+	}
+
+	s.log.Infof("Scheduler started with %d tasks (instance %s)", len(tasks), s.instanceID)
+	return nil
+}
+
+// Stop halts scheduling and waits for running tasks; when ctx expires they are canceled
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.quitOnce.Do(func() { close(s.quit) })
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.log.Info("Scheduler stopped")
+		return nil
+	case <-ctx.Done():
+		s.log.Warn("Scheduler stop timed out, canceling running tasks")
+		s.cancelRun()
+		<-done
+		return fmt.Errorf("scheduler stop incomplete: %w", ctx.Err())
+	}
+}
+
+// Status returns a snapshot of every scheduled task, ordered by name
+func (s *Scheduler) Status() []TaskStatus {
+	s.mu.Lock()
+	tasks := s.tasks
+	s.mu.Unlock()
+	statuses := make([]TaskStatus, 0, len(tasks))
+	for _, t := range tasks {
+		t.mu.Lock()
+		statuses = append(statuses, t.status)
+		t.mu.Unlock()
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// loop waits for each tick of a task's schedule and dispatches a run
+func (s *Scheduler) loop(t *task) {
+	defer s.wg.Done()
+
+	for {
+		next := t.schedule.Next(time.Now())
+		t.mu.Lock()
+		t.status.NextRunAt = next
+		t.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-s.quit:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.dispatch(t, next)
+	}
+}
+
+// dispatch starts a run for tick unless the previous run of the task is
+// still in progress
+func (s *Scheduler) dispatch(t *task, tick time.Time) {
+	t.mu.Lock()
+	if t.status.Running {
+		t.status.Skipped++
+		t.mu.Unlock()
+		s.log.Warnf("Scheduled task %s still running, skipping tick %s", t.name, tick.Format(time.RFC3339))
+		return
+	}
+	t.status.Running = true
+	t.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run(t, tick)
+}
+
+// run executes one tick of a task if this instance wins the lease
+func (s *Scheduler) run(t *task, tick time.Time) {
+	defer s.wg.Done()
+	defer func() {
+		t.mu.Lock()
+		t.status.Running = false
+		t.mu.Unlock()
+	}()
+
+	log := s.log.WithFields(logrus.Fields{"task": t.name, "tick": tick.Format(time.RFC3339)})
+
+	// Spread instances out so they do not all hit the lease row at once
+	if s.cfg.Jitter > 0 {
+		select {
+		case <-s.quit:
+			return
+		case <-time.After(time.Duration(rand.Int63n(int64(s.cfg.Jitter)))):
+		}
+	}
+
+	acquired, err := s.acquireLease(context.Background(), t.name, tick)
+	if err != nil {
+		log.Errorf("Failed to acquire scheduler lease: %v", err)
+		return
+	}
+	if !acquired {
+		t.mu.Lock()
+		t.status.Skipped++
+		t.mu.Unlock()
+		log.Debug("Scheduler lease held elsewhere, skipping tick")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.runCtx, s.cfg.LeaseTTL)
+	defer cancel()
+
+	log.Info("Running scheduled task")
+	started := time.Now()
+	err = s.invoke(ctx, t)
+	duration := time.Since(started)
+
+	if releaseErr := s.releaseLease(context.Background(), t.name, tick); releaseErr != nil {
+		log.Errorf("Failed to release scheduler lease: %v", releaseErr)
+	}
+
+	t.mu.Lock()
+	t.status.LastRunAt = &started
+	t.status.LastDurationMs = duration.Milliseconds()
+	t.status.Runs++
+	t.status.LastError = ""
+	if err != nil {
+		t.status.Failures++
+		t.status.LastError = err.Error()
+	} else {
+		t.status.LastSuccessAt = &started
+	}
+	t.mu.Unlock()
+
+	if err != nil {
+		log.Errorf("Scheduled task failed after %s: %v", duration, err)
+		return
+	}
+	log.Infof("Scheduled task completed in %s", duration)
+}
+
+// invoke calls the task function, converting panics into errors
+func (s *Scheduler) invoke(ctx context.Context, t *task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("scheduled task panicked: %v", r)
+		}
+	}()
+	return t.fn(ctx)
+}
@@ -0,0 +1,145 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"gopark/config"
+	"gopark/internal/db"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupDB creates a migrated temporary database
+func setupDB(t *testing.T) (*db.DB, *logrus.Logger) {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(bytes.NewBuffer(nil)) // Disable logging output
+
+	var cfg config.Config
+	cfg.Database.Path = filepath.Join(t.TempDir(), "scheduler.db")
+	dbConn, err := db.NewDB(cfg, log)
+	require.NoError(t, err)
+	t.Cleanup(dbConn.Close)
+
+	migrations := db.NewMigrationManager(dbConn, log)
+	require.NoError(t, migrations.RunMigrations(context.Background(), "../migrations"))
+	return dbConn, log
+}
+
+// newTestScheduler creates a scheduler for the named instance
+func newTestScheduler(dbConn *db.DB, log *logrus.Logger, instance string, tasks ...config.ScheduledTask) *Scheduler {
+	return NewScheduler(dbConn, config.SchedulerConfig{
+		InstanceID: instance,
+		LeaseTTL:   time.Minute,
+		Tasks:      tasks,
+	}, log)
+}
+
+// TestLeases verifies only one instance runs each tick
+func TestLeases(t *testing.T) {
+	dbConn, log := setupDB(t)
+	a := newTestScheduler(dbConn, log, "a")
+	b := newTestScheduler(dbConn, log, "b")
+	ctx := context.Background()
+	tick := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+
+	// Test case 1: the first instance wins the tick
+	acquired, err := a.acquireLease(ctx, "vacuum", tick)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+
+	acquired, err = b.acquireLease(ctx, "vacuum", tick)
+	require.NoError(t, err)
+	assert.False(t, acquired)
+
+	// Test case 2: a later tick cannot be taken while the lease is unexpired
+	acquired, err = b.acquireLease(ctx, "vacuum", tick.Add(time.Hour))
+	require.NoError(t, err)
+	assert.False(t, acquired)
+
+	// Test case 3: a released tick is never run twice, but the next one is available
+	require.NoError(t, a.releaseLease(ctx, "vacuum", tick))
+	acquired, err = b.acquireLease(ctx, "vacuum", tick)
+	require.NoError(t, err)
+	assert.False(t, acquired)
+
+	acquired, err = b.acquireLease(ctx, "vacuum", tick.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, acquired)
+
+	// Test case 4: leases are tracked per task
+	acquired, err = a.acquireLease(ctx, "analyze", tick)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+}
+
+// TestRun verifies run bookkeeping and cross-instance deduplication
+func TestRun(t *testing.T) {
+	dbConn, log := setupDB(t)
+	a := newTestScheduler(dbConn, log, "a", config.ScheduledTask{Name: "count", Schedule: "@hourly"})
+	b := newTestScheduler(dbConn, log, "b", config.ScheduledTask{Name: "count", Schedule: "@hourly"})
+
+	calls := 0
+	count := func(ctx context.Context) error {
+		calls++
+		if calls > 1 {
+			return errors.New("second run failed")
+		}
+		return nil
+	}
+	a.Register("count", count)
+	b.Register("count", count)
+	require.NoError(t, a.Start())
+	require.NoError(t, b.Start())
+	defer a.Stop(context.Background())
+	defer b.Stop(context.Background())
+
+	tick := time.Now().Truncate(time.Minute).UTC()
+
+	// Test case 1: only the first instance runs the tick
+	a.wg.Add(1)
+	a.run(a.tasks[0], tick)
+	b.wg.Add(1)
+	b.run(b.tasks[0], tick)
+	assert.Equal(t, 1, calls)
+
+	statusA := a.Status()[0]
+	assert.Equal(t, int64(1), statusA.Runs)
+	assert.NotNil(t, statusA.LastRunAt)
+	assert.Equal(t, int64(1), b.Status()[0].Skipped)
+
+	assert.Eventually(t, func() bool { return !a.Status()[0].NextRunAt.IsZero() }, time.Second, 10*time.Millisecond)
+
+	// Test case 2: failures are recorded in the status
+	b.wg.Add(1)
+	b.run(b.tasks[0], tick.Add(time.Minute))
+	statusB := b.Status()[0]
+	assert.Equal(t, int64(1), statusB.Failures)
+	assert.Equal(t, "second run failed", statusB.LastError)
+}
+
+// TestStartValidation verifies configuration errors are reported at startup
+func TestStartValidation(t *testing.T) {
+	dbConn, log := setupDB(t)
+
+	// Test case 1: unregistered task names are rejected
+	s := newTestScheduler(dbConn, log, "a", config.ScheduledTask{Name: "missing", Schedule: "@daily"})
+	assert.ErrorContains(t, s.Start(), "unknown scheduled task")
+
+	// Test case 2: malformed cron expressions are rejected
+	s = newTestScheduler(dbConn, log, "a", config.ScheduledTask{Name: "vacuum", Schedule: "61 * * * *"})
+	s.Register("vacuum", dbConn.Vacuum)
+	assert.ErrorContains(t, s.Start(), "invalid schedule")
+
+	// Test case 3: disabled tasks are not scheduled
+	s = newTestScheduler(dbConn, log, "a", config.ScheduledTask{Name: "vacuum", Schedule: "@daily", Disabled: true})
+	require.NoError(t, s.Start())
+	assert.Empty(t, s.Status())
+	assert.NoError(t, s.Stop(context.Background()))
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// listenFDsStart is the first file descriptor passed by socket activation
+const listenFDsStart = 3
+
+// activatedSockets holds the sockets passed by systemd, read once because
+// the environment describing them is cleared so child processes do not
+// mistake them for their own
+var activatedSockets struct {
+	once  sync.Once
+	files []*os.File
+	names []string
+	err   error
+}
+
+// activatedListener returns the socket-activated listener called name, the
+// FileDescriptorName= of its socket unit, or at index name when it is a number
+func activatedListener(name string) (net.Listener, error) {
+	sockets := &activatedSockets
+	sockets.once.Do(func() {
+		sockets.files, sockets.names, sockets.err = parseActivation(
+			os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES"), listenFDsStart)
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	})
+	if sockets.err != nil {
+		return nil, sockets.err
+	}
+	file, err := findActivated(sockets.files, sockets.names, name)
+	if err != nil {
+		return nil, err
+	}
+	return net.FileListener(file) // Duplicates the descriptor, so name may be looked up again
+}
+
+// parseActivation reads the socket activation variables: the process the
+// sockets are meant for, their count starting at descriptor first, and
+// their colon separated names
+func parseActivation(pid, fds, names string, first int) ([]*os.File, []string, error) {
+	if fds == "" {
+		return nil, nil, nil
+	}
+	if pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil, nil // Inherited from a parent that was activated
+	}
+	count, err := strconv.Atoi(fds)
+	if err != nil || count < 0 {
+		return nil, nil, fmt.Errorf("invalid LISTEN_FDS %q", fds)
+	}
+	var fdNames []string
+	if names != "" {
+		fdNames = strings.Split(names, ":")
+	}
+	files := make([]*os.File, count)
+	for i := range files {
+		fd := first + i
+		syscall.CloseOnExec(fd)
+		name := fmt.Sprintf("LISTEN_FD_%d", fd)
+		if i < len(fdNames) {
+			name = fdNames[i]
+		}
+		files[i] = os.NewFile(uintptr(fd), name)
+	}
+	return files, fdNames, nil
+}
+
+// findActivated picks the socket called name, or at index name
+func findActivated(files []*os.File, names []string, name string) (*os.File, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no sockets passed by systemd for %s", name)
+	}
+	for i, n := range names {
+		if n == name && i < len(files) {
+			return files[i], nil
+		}
+	}
+	if i, err := strconv.Atoi(name); err == nil && i >= 0 && i < len(files) {
+		return files[i], nil
+	}
+	return nil, fmt.Errorf("no socket named %s among the %d passed by systemd", name, len(files))
+}
@@ -0,0 +1,304 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Environment variables describing the sockets and readiness pipe a
+// restarting process passes to its replacement
+const (
+	envInherited     = "GOPARK_LISTENERS"
+	envReadyFD       = "GOPARK_READY_FD"
+	envPredecessorFD = "GOPARK_PREDECESSOR_FD"
+)
+
+// inheritedListener identifies a socket passed by the previous process; the
+// sockets follow in the same order from descriptor listenFDsStart
+type inheritedListener struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+// inheritedSockets holds the sockets passed by the previous process, read
+// once and cleared from the environment like the systemd variables
+var inheritedSockets struct {
+	once  sync.Once
+	mu    sync.Mutex
+	files map[inheritedListener]*os.File
+	err   error
+}
+
+// inheritedListenerFor returns the socket the previous process served the
+// listener on, if it had one with the same name and address. Each socket is
+// handed out once; those never asked for stay open until exit
+func inheritedListenerFor(name, address string) (net.Listener, error) {
+	sockets := &inheritedSockets
+	sockets.once.Do(func() {
+		sockets.files, sockets.err = parseInherited(os.Getenv(envInherited), listenFDsStart)
+		os.Unsetenv(envInherited)
+	})
+	if sockets.err != nil {
+		return nil, sockets.err
+	}
+	sockets.mu.Lock()
+	defer sockets.mu.Unlock()
+	key := inheritedListener{Name: name, Address: address}
+	file, ok := sockets.files[key]
+	if !ok {
+		return nil, nil
+	}
+	delete(sockets.files, key)
+	defer file.Close()
+	ln, err := net.FileListener(file)
+	if err != nil {
+		return nil, err
+	}
+	if unix, ok := ln.(*net.UnixListener); ok {
+		unix.SetUnlinkOnClose(true) // This process owns the socket path now
+	}
+	return ln, nil
+}
+
+// parseInherited decodes the list of inherited sockets starting at
+// descriptor first
+func parseInherited(value string, first int) (map[inheritedListener]*os.File, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var listeners []inheritedListener
+	if err := json.Unmarshal([]byte(value), &listeners); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", envInherited, err)
+	}
+	files := make(map[inheritedListener]*os.File, len(listeners))
+	for i, l := range listeners {
+		fd := first + i
+		syscall.CloseOnExec(fd)
+		files[l] = os.NewFile(uintptr(fd), "inherited "+l.Name)
+	}
+	return files, nil
+}
+
+// Handover starts a new copy of the running binary with the same arguments,
+// passing it the listening sockets, and waits until it reports ready with
+// NotifyReady. Both processes accept connections until the caller shuts this
+// server down, which then leaves Unix sockets in place for the new process.
+// Work that must not run twice waits in the new process, through
+// WaitForPredecessor, until the caller has called ReleaseSuccessor or this
+// process has exited. If the new process exits or is not ready before ctx
+// ends, it is killed and an error returned; this server keeps serving.
+// Handover returns the process ID of the new process
+func (s *Server) Handover(ctx context.Context) (int, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+
+	var inherited []inheritedListener
+	var fds []uintptr
+	defer func() {
+		for _, fd := range fds {
+			syscall.Close(int(fd))
+		}
+	}()
+	for _, l := range s.listeners {
+		if l.ln == nil {
+			return 0, errors.New("server is not running")
+		}
+		fd, err := dupListener(l.ln)
+		if err != nil {
+			return 0, fmt.Errorf("%s listener: %w", l.Name, err)
+		}
+		fds = append(fds, fd)
+		inherited = append(inherited, inheritedListener{Name: l.Name, Address: l.Address})
+	}
+	encoded, err := json.Marshal(inherited)
+	if err != nil {
+		return 0, err
+	}
+
+	ready, notify, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer ready.Close()
+	released, successor, err := os.Pipe()
+	if err != nil {
+		notify.Close()
+		return 0, err
+	}
+	env := append(os.Environ(),
+		envInherited+"="+string(encoded),
+		envReadyFD+"="+strconv.Itoa(listenFDsStart+len(fds)),
+		envPredecessorFD+"="+strconv.Itoa(listenFDsStart+len(fds)+1),
+	)
+	files := append([]uintptr{0, 1, 2}, fds...)
+	pid, _, err := syscall.StartProcess(executable, os.Args, &syscall.ProcAttr{
+		Env:   env,
+		Files: append(files, notify.Fd(), released.Fd()),
+	})
+	notify.Close()   // Only the new process writes, so its exit closes the pipe
+	released.Close() // Only the new process reads; this process holds the other end
+	if err != nil {
+		successor.Close()
+		return 0, fmt.Errorf("failed to start new process: %w", err)
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return 0, err
+	}
+	s.log.Infof("Started new process %d; waiting until it is ready", pid)
+
+	if err := waitReady(ctx, ready); err != nil {
+		successor.Close()
+		process.Kill()
+		process.Wait()
+		return 0, fmt.Errorf("new process %d: %w", pid, err)
+	}
+	process.Release() // The new process outlives this one
+	s.ReleaseSuccessor()
+	s.mu.Lock()
+	s.successor = successor
+	s.mu.Unlock()
+
+	// The new process serves the same socket paths from now on
+	for _, l := range s.listeners {
+		if unix, ok := l.ln.(*net.UnixListener); ok {
+			unix.SetUnlinkOnClose(false)
+		}
+	}
+	return pid, nil
+}
+
+// dupListener duplicates the descriptor of ln for a new process. Unlike
+// File, which goes through os.File.Fd when passed to os.StartProcess, this
+// leaves the shared socket non-blocking, so ln can still be closed while a
+// goroutine is accepting on it
+func dupListener(ln net.Listener) (uintptr, error) {
+	conn, ok := ln.(syscall.Conn)
+	if !ok {
+		return 0, errors.New("cannot be passed to another process")
+	}
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var dup int
+	var dupErr error
+	if err := raw.Control(func(fd uintptr) { dup, dupErr = syscall.Dup(int(fd)) }); err != nil {
+		return 0, err
+	}
+	if dupErr != nil {
+		return 0, dupErr
+	}
+	syscall.CloseOnExec(dup) // Only the new process gets it, at its own position
+	return uintptr(dup), nil
+}
+
+// waitReady waits for a byte on the readiness pipe
+func waitReady(ctx context.Context, ready *os.File) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := ready.Read(make([]byte, 1))
+		if err == io.EOF {
+			err = errors.New("exited before it was ready")
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		ready.SetReadDeadline(time.Now()) // Unblocks the read
+		return fmt.Errorf("not ready: %w", ctx.Err())
+	}
+}
+
+// ReleaseSuccessor tells the process started by Handover that this process
+// has stopped, so it may start the work only one process may do at a time,
+// such as replication. Exiting does the same. It does nothing without a
+// successful Handover
+func (s *Server) ReleaseSuccessor() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.successor != nil {
+		s.successor.Close()
+		s.successor = nil
+	}
+}
+
+// predecessor holds the pipe whose end is closed once the process that
+// started this one through Handover has stopped, read once from the
+// environment
+var predecessor struct {
+	once sync.Once
+	file *os.File
+}
+
+// predecessorPipe returns the pipe of the previous process, or nil
+func predecessorPipe() *os.File {
+	predecessor.once.Do(func() {
+		value := os.Getenv(envPredecessorFD)
+		os.Unsetenv(envPredecessorFD)
+		if fd, err := strconv.Atoi(value); err == nil {
+			syscall.CloseOnExec(fd)
+			predecessor.file = os.NewFile(uintptr(fd), "predecessor")
+		}
+	})
+	return predecessor.file
+}
+
+// HasPredecessor reports whether this process was started by Handover and
+// shares its listeners with a process that may still be running
+func HasPredecessor() bool {
+	return predecessorPipe() != nil
+}
+
+// WaitForPredecessor blocks until the process that started this one
+// through Handover has called ReleaseSuccessor or exited, or until ctx ends.
+// It returns at once in a process started any other way
+func WaitForPredecessor(ctx context.Context) error {
+	pipe := predecessorPipe()
+	if pipe == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		io.Copy(io.Discard, pipe) // Returns at EOF
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NotifyReady tells the process that started this one through Handover that
+// it is serving, so the old process can drain and exit. It does nothing in
+// a process started any other way
+func NotifyReady() error {
+	value := os.Getenv(envReadyFD)
+	if value == "" {
+		return nil
+	}
+	os.Unsetenv(envReadyFD)
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q", envReadyFD, value)
+	}
+	pipe := os.NewFile(uintptr(fd), "ready")
+	defer pipe.Close()
+	_, err = pipe.Write([]byte{1})
+	return err
+}
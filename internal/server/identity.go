@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+)
+
+// Identity describes the caller authenticated by a verified client certificate
+type Identity struct {
+	Name        string            // Identity chosen by the IdentityMapper
+	Certificate *x509.Certificate // Verified client certificate
+}
+
+// IdentityMapper derives an identity name from a verified client
+// certificate; returning false leaves the request without an identity
+type IdentityMapper func(cert *x509.Certificate) (string, bool)
+
+// identityKey is the request context key of the client Identity
+type identityKey struct{}
+
+// ClientIdentity returns the identity of the client certificate presented
+// with the request whose context is ctx, for use by authentication middleware
+// (e.g. ClientIdentity(c.Request.Context()))
+func ClientIdentity(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// MapIdentityFrom returns the IdentityMapper for a tls.identity_from field:
+// cn (subject common name), or the first dns, email or uri subject
+// alternative name. URIs suit SPIFFE IDs such as spiffe://cluster/ns/app
+func MapIdentityFrom(field string) IdentityMapper {
+	return func(cert *x509.Certificate) (string, bool) {
+		switch field {
+		case "dns":
+			if len(cert.DNSNames) > 0 {
+				return cert.DNSNames[0], true
+			}
+		case "email":
+			if len(cert.EmailAddresses) > 0 {
+				return cert.EmailAddresses[0], true
+			}
+		case "uri":
+			if len(cert.URIs) > 0 {
+				return cert.URIs[0].String(), true
+			}
+		default:
+			if cert.Subject.CommonName != "" {
+				return cert.Subject.CommonName, true
+			}
+		}
+		return "", false
+	}
+}
+
+// identityMapper returns the configured or default IdentityMapper
+func (s *Server) identityMapper() IdentityMapper {
+	if s.identity != nil {
+		return s.identity
+	}
+	return MapIdentityFrom(s.tls.IdentityFrom)
+}
+
+// identityHandler stores the identity of a verified client certificate in
+// the request context before calling next
+func identityHandler(next http.Handler, mapper IdentityMapper) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			cert := r.TLS.VerifiedChains[0][0]
+			if name, ok := mapper(cert); ok {
+				r = r.WithContext(context.WithValue(r.Context(), identityKey{}, Identity{Name: name, Certificate: cert}))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
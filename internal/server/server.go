@@ -2,74 +2,320 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"gopark/config"
+	"net"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
-// Server encapsulates the HTTP server and its dependencies
-type Server struct {
+// Listener describes one address the server accepts connections on and the
+// handler serving it
+type Listener struct {
+	Name    string       // Identifies the listener in logs and Addr, e.g. public or admin
+	Address string       // host:port, unix:/path/to.sock, or systemd:name for socket activation
+	Handler http.Handler // Router with the middleware stack of this listener
+
+	ReadTimeout  time.Duration // 0 means no timeout
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+
+	TLS bool // Serve HTTPS as configured by WithTLS; otherwise plain HTTP
+}
+
+// freshConnGrace bounds how long Shutdown waits for connections accepted
+// just before the listeners closed to send their first request
+const freshConnGrace = time.Second
+
+// listener is a Listener with its HTTP server, once started
+type listener struct {
+	Listener
 	httpServer *http.Server
-	log        *logrus.Logger
+	ln         net.Listener
+	accepting  *closeOnceListener // ln as served, closed to stop accepting
+
+	mu    sync.Mutex
+	fresh map[net.Conn]bool // Accepted connections that have not sent a request yet
+}
+
+// trackConn records which connections have not sent a request yet
+func (l *listener) trackConn(conn net.Conn, state http.ConnState) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if state == http.StateNew {
+		l.fresh[conn] = true
+	} else {
+		delete(l.fresh, conn)
+	}
+}
+
+// freshConns returns the number of connections that have not sent a request yet
+func (l *listener) freshConns() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.fresh)
+}
+
+// closeOnceListener lets Shutdown close a listener that already stopped
+// accepting without reporting an error
+type closeOnceListener struct {
+	net.Listener
+	once sync.Once
+	err  error
+}
+
+func (l *closeOnceListener) Close() error {
+	l.once.Do(func() { l.err = l.Listener.Close() })
+	return l.err
+}
+
+// Server serves HTTP on one or more listeners, each with its own router
+type Server struct {
+	listeners []*listener
+	log       *logrus.Logger
+	tls       config.TLSConfig
+	http2     config.HTTP2Config
+	identity  IdentityMapper
+	certs     *certReloader // Set while serving TLS from files
+	draining  atomic.Bool
+
+	mu        sync.Mutex
+	successor *os.File // Write end of the pipe the process started by Handover waits on
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithListener adds a listener. Listener names must be unique
+func WithListener(l Listener) Option {
+	return func(s *Server) { s.listeners = append(s.listeners, &listener{Listener: l}) }
+}
+
+// WithTLS serves HTTPS on the listeners marked TLS when cfg.Enabled is set
+func WithTLS(cfg config.TLSConfig) Option {
+	return func(s *Server) { s.tls = cfg }
+}
+
+// WithHTTP2 controls HTTP/2 over TLS and h2c; without it the server only
+// speaks HTTP/1.1
+func WithHTTP2(cfg config.HTTP2Config) Option {
+	return func(s *Server) { s.http2 = cfg }
+}
+
+// WithIdentityMapper replaces the mapping from client certificates to
+// identities selected by tls.identity_from
+func WithIdentityMapper(mapper IdentityMapper) Option {
+	return func(s *Server) { s.identity = mapper }
 }
 
 // NewServer creates a new Server instance
-func NewServer(router *gin.Engine, port int, log *logrus.Logger) *Server {
-	addr := fmt.Sprintf(":%d", port)
+func NewServer(log *logrus.Logger, opts ...Option) *Server {
+	s := &Server{log: log}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Start opens every listener and serves requests in the background. Listen
+// errors such as a port in use and invalid certificates are returned, after
+// closing the listeners already opened
+func (s *Server) Start() error {
+	var tlsConfig *tls.Config
+	for _, l := range s.listeners {
+		if l.TLS && s.tls.Enabled && tlsConfig == nil {
+			var err error
+			if tlsConfig, err = s.tlsConfig(); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, l := range s.listeners {
+		ln, err := listen(l.Name, l.Address)
+		if err != nil {
+			for _, opened := range s.listeners[:i] {
+				opened.ln.Close()
+				opened.ln = nil
+			}
+			s.stopCertWatch()
+			return fmt.Errorf("%s listener: %w", l.Name, err)
+		}
+		l.ln = ln
+	}
 
+	for _, l := range s.listeners {
+		l.httpServer = s.httpServer(l, tlsConfig)
+		l.accepting = &closeOnceListener{Listener: l.ln}
+		l.fresh = map[net.Conn]bool{}
+		go s.serve(l)
+	}
+	return nil
+}
+
+// httpServer builds the HTTP server of l; tlsConfig is nil without TLS
+func (s *Server) httpServer(l *listener, tlsConfig *tls.Config) *http.Server {
 	httpServer := &http.Server{
-		Addr:           addr,
-		Handler:        router,
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
-		IdleTimeout:    120 * time.Second,
+		Handler:        l.Handler,
+		ReadTimeout:    l.ReadTimeout,
+		WriteTimeout:   l.WriteTimeout,
+		IdleTimeout:    l.IdleTimeout,
 		MaxHeaderBytes: 1 << 20, // 1 MB
+		ConnState:      l.trackConn,
+	}
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	if l.TLS && tlsConfig != nil {
+		protocols.SetHTTP2(s.http2.Enabled)
+		httpServer.TLSConfig = tlsConfig
+		if tlsConfig.ClientAuth != tls.NoClientCert {
+			httpServer.Handler = identityHandler(l.Handler, s.identityMapper())
+		}
+	} else {
+		protocols.SetUnencryptedHTTP2(s.http2.Enabled && s.http2.H2C)
 	}
+	httpServer.Protocols = &protocols
+	return httpServer
+}
 
-	return &Server{
-		httpServer: httpServer,
-		log:        log,
+// serve accepts connections on l until it is shut down
+func (s *Server) serve(l *listener) {
+	var err error
+	if l.httpServer.TLSConfig != nil {
+		s.log.Infof("HTTPS %s listener on %s", l.Name, l.ln.Addr())
+		err = l.httpServer.ServeTLS(l.accepting, "", "") // Certificates come from TLSConfig
+	} else {
+		s.log.Infof("HTTP %s listener on %s", l.Name, l.ln.Addr())
+		err = l.httpServer.Serve(l.accepting)
+	}
+	if err != nil && err != http.ErrServerClosed && !s.draining.Load() {
+		s.log.Errorf("HTTP %s listener stopped: %v", l.Name, err)
 	}
 }
 
-// Run starts the HTTP server and supports graceful shutdown
-func (s *Server) Run() error {
-	// Start the server in a dedicated goroutine
-	go func() {
-		s.log.Infof("HTTP server listening on %s", s.httpServer.Addr)
-		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			s.log.Fatalf("Failed to start server: %v", err)
-		}
-	}()
-
-	// Wait for interrupt signals to shut down gracefully
-	quit := make(chan os.Signal, 1)
-	// kill (no args) sends syscall.SIGTERM
-	// kill -2 sends syscall.SIGINT
-	// kill -9 sends syscall.SIGKILL, which cannot be caught
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	s.log.Info("Shutting down server...")
+// listen opens address, which is host:port, unix:/path or systemd:name,
+// unless the process that started this one through Handover passed the
+// listener on
+func listen(name, address string) (net.Listener, error) {
+	if ln, err := inheritedListenerFor(name, address); ln != nil || err != nil {
+		return ln, err
+	}
+	switch {
+	case strings.HasPrefix(address, "unix:"):
+		return listenUnix(strings.TrimPrefix(address, "unix:"))
+	case strings.HasPrefix(address, "systemd:"):
+		return activatedListener(strings.TrimPrefix(address, "systemd:"))
+	default:
+		return net.Listen("tcp", address)
+	}
+}
 
-	// Allow up to 5 seconds to complete shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := s.Shutdown(ctx); err != nil {
-		s.log.Fatalf("Server forced to shutdown: %v", err)
+// listenUnix listens on a Unix domain socket at path, replacing a socket left
+// behind by a process that exited without removing it. The socket is
+// readable and writable by its owner and group only
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("socket %s is in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
 	}
+	if err := os.Chmod(path, 0660); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
 
-	s.log.Info("Server exiting")
+// Addr returns the address the named listener listens on, or nil before
+// Start and for unknown names
+func (s *Server) Addr(name string) net.Addr {
+	for _, l := range s.listeners {
+		if l.Name == name && l.ln != nil {
+			return l.ln.Addr()
+		}
+	}
 	return nil
 }
 
-// Shutdown stops the server gracefully
+// Shutdown stops accepting connections on every listener and waits for
+// in-flight requests until ctx expires. Connections accepted just before
+// are given a moment to send their request, which http.Server.Shutdown
+// would otherwise drop; this matters when another process took over the
+// listeners through Handover and keeps accepting on them
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.log.Info("Shutting down server...")
-	return s.httpServer.Shutdown(ctx)
+	defer s.stopCertWatch()
+
+	s.draining.Store(true)
+	for _, l := range s.listeners {
+		if l.accepting != nil {
+			l.accepting.Close()
+		}
+	}
+	s.waitFreshConns(ctx)
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(s.listeners))
+	for i, l := range s.listeners {
+		if l.httpServer == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := l.httpServer.Shutdown(ctx); err != nil {
+				errs[i] = fmt.Errorf("%s listener: %w", l.Name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// waitFreshConns waits until every accepted connection has sent a request,
+// for at most freshConnGrace
+func (s *Server) waitFreshConns(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, freshConnGrace)
+	defer cancel()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		fresh := 0
+		for _, l := range s.listeners {
+			if l.httpServer != nil {
+				fresh += l.freshConns()
+			}
+		}
+		if fresh == 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// stopCertWatch stops watching certificate files, if it was started
+func (s *Server) stopCertWatch() {
+	if s.certs != nil {
+		s.certs.Close()
+	}
 }
@@ -0,0 +1,411 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"gopark/config"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// envHandoverTest makes the test binary act as the new process started by
+// Handover: "fail" exits at once, "serve" takes over the listeners
+const envHandoverTest = "GOPARK_TEST_HANDOVER"
+
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(envHandoverTest); mode != "" {
+		handoverChild(mode)
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// handoverChild serves "new" on the listeners of newHandoverServer until killed
+func handoverChild(mode string) {
+	if mode == "fail" {
+		os.Exit(1)
+	}
+	s := newHandoverServer(os.Getenv("GOPARK_TEST_SOCKET"), "new")
+	if err := s.Start(); err != nil {
+		os.Exit(1)
+	}
+	if err := NotifyReady(); err != nil {
+		os.Exit(1)
+	}
+	time.Sleep(time.Minute)
+	os.Exit(0)
+}
+
+// newHandoverServer creates a server answering body on TCP and on socket
+func newHandoverServer(socket, body string) *Server {
+	log := logrus.New()
+	log.SetOutput(bytes.NewBuffer(nil)) // Disable logging output
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(body)) })
+	return NewServer(log,
+		WithListener(Listener{Name: "public", Address: "127.0.0.1:0", Handler: handler}),
+		WithListener(Listener{Name: "admin", Address: "unix:" + socket, Handler: handler}),
+	)
+}
+
+// testCert is a generated certificate with its key
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert issues a certificate from template, signed by parent or self-signed
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCert{cert: cert, key: key}
+}
+
+// write stores the certificate and key as PEM files, replacing them atomically
+func (c *testCert) write(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+	for file, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: c.cert.Raw},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		require.NoError(t, os.WriteFile(file+".tmp", pem.EncodeToMemory(block), 0600))
+		require.NoError(t, os.Rename(file+".tmp", file))
+	}
+}
+
+// serverCert issues a localhost server certificate
+func serverCert(t *testing.T, name string) *testCert {
+	return newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: name},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, nil)
+}
+
+// startTestServer starts a server on a random port that reports the protocol
+// and client identity of each request
+func startTestServer(t *testing.T, opts ...Option) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", func(c *gin.Context) {
+		id, _ := ClientIdentity(c.Request.Context())
+		c.JSON(http.StatusOK, gin.H{"proto": c.Request.Proto, "identity": id.Name})
+	})
+	log := logrus.New()
+	log.SetOutput(bytes.NewBuffer(nil)) // Disable logging output
+
+	listener := Listener{Name: "public", Address: "127.0.0.1:0", Handler: r, TLS: true}
+	s := NewServer(log, append([]Option{WithListener(listener)}, opts...)...)
+	require.NoError(t, s.Start())
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	return s
+}
+
+// baseURL returns the base URL of s
+func baseURL(s *Server, scheme string) string {
+	return scheme + "://" + s.Addr("public").String() + "/"
+}
+
+// TestProtocols covers HTTP/2 over TLS and h2c
+func TestProtocols(t *testing.T) {
+	// Test case 1: a self-signed certificate negotiates HTTP/2
+	s := startTestServer(t, WithTLS(config.TLSConfig{Enabled: true, SelfSigned: true}), WithHTTP2(config.HTTP2Config{Enabled: true}))
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+	resp, err := client.Get(baseURL(s, "https"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 2, resp.ProtoMajor)
+	assert.Equal(t, "localhost", resp.TLS.PeerCertificates[0].Subject.CommonName)
+
+	// Test case 2: h2c serves HTTP/2 without TLS to clients with prior knowledge
+	s = startTestServer(t, WithHTTP2(config.HTTP2Config{Enabled: true, H2C: true}))
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	client = &http.Client{Transport: &http.Transport{Protocols: &protocols}}
+	resp, err = client.Get(baseURL(s, "http"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 2, resp.ProtoMajor)
+
+	// Test case 3: plain HTTP/1.1 keeps working without HTTP/2
+	s = startTestServer(t)
+	resp, err = http.Get(baseURL(s, "http"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 1, resp.ProtoMajor)
+}
+
+// TestCertificateReload verifies rotated certificate files are served
+func TestCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	serverCert(t, "first").write(t, certFile, keyFile)
+
+	s := startTestServer(t, WithTLS(config.TLSConfig{Enabled: true, CertFile: certFile, KeyFile: keyFile, MinVersion: "1.3"}))
+	served := func() string {
+		conn, err := tls.Dial("tcp", s.Addr("public").String(), &tls.Config{InsecureSkipVerify: true})
+		require.NoError(t, err)
+		defer conn.Close()
+		assert.Equal(t, uint16(tls.VersionTLS13), conn.ConnectionState().Version)
+		return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}
+
+	// Test case 1: the initial certificate is served
+	assert.Equal(t, "first", served())
+
+	// Test case 2: an invalid file keeps the current certificate
+	require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0600))
+	time.Sleep(3 * certReloadDebounce)
+	assert.Equal(t, "first", served())
+
+	// Test case 3: a rotated certificate is picked up
+	serverCert(t, "second").write(t, certFile, keyFile)
+	assert.Eventually(t, func() bool { return served() == "second" }, 5*time.Second, 50*time.Millisecond)
+}
+
+// TestClientCertificates covers mutual TLS and identity mapping
+func TestClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0600))
+
+	clientCert := newTestCert(t, &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "billing"},
+		EmailAddresses: []string{"billing@example.com"},
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+	keyPair := tls.Certificate{Certificate: [][]byte{clientCert.cert.Raw}, PrivateKey: clientCert.key}
+
+	get := func(s *Server, certs ...tls.Certificate) (string, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			Certificates:       certs,
+		}}}
+		resp, err := client.Get(baseURL(s, "https"))
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		var body struct{ Identity string }
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return body.Identity, nil
+	}
+
+	tlsCfg := config.TLSConfig{Enabled: true, SelfSigned: true, ClientAuth: "require", ClientCAFile: caFile}
+
+	// Test case 1: the common name is the default identity
+	s := startTestServer(t, WithTLS(tlsCfg))
+	identity, err := get(s, keyPair)
+	require.NoError(t, err)
+	assert.Equal(t, "billing", identity)
+
+	// Test case 2: a required certificate must be presented
+	_, err = get(s)
+	assert.Error(t, err)
+
+	// Test case 3: identity_from selects another field
+	tlsCfg.IdentityFrom = "email"
+	s = startTestServer(t, WithTLS(tlsCfg))
+	identity, err = get(s, keyPair)
+	require.NoError(t, err)
+	assert.Equal(t, "billing@example.com", identity)
+
+	// Test case 4: request mode admits anonymous clients without an identity
+	tlsCfg.ClientAuth = "request"
+	s = startTestServer(t, WithTLS(tlsCfg), WithIdentityMapper(func(cert *x509.Certificate) (string, bool) {
+		return "svc:" + cert.Subject.CommonName, true
+	}))
+	identity, err = get(s)
+	require.NoError(t, err)
+	assert.Empty(t, identity)
+	identity, err = get(s, keyPair)
+	require.NoError(t, err)
+	assert.Equal(t, "svc:billing", identity)
+}
+
+// TestListeners covers several listeners with their own handlers, including a
+// Unix domain socket
+func TestListeners(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(bytes.NewBuffer(nil)) // Disable logging output
+	handler := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(body)) })
+	}
+	socket := filepath.Join(t.TempDir(), "admin.sock")
+	newServer := func() *Server {
+		return NewServer(log,
+			WithListener(Listener{Name: "public", Address: "127.0.0.1:0", Handler: handler("public"), TLS: true}),
+			WithListener(Listener{Name: "admin", Address: "unix:" + socket, Handler: handler("admin")}),
+			WithTLS(config.TLSConfig{Enabled: true, SelfSigned: true}),
+		)
+	}
+	get := func(client *http.Client, url string) string {
+		resp, err := client.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	tlsClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+
+	// Test case 1: each listener serves its own handler, TLS only where asked
+	s := newServer()
+	require.NoError(t, s.Start())
+	assert.Equal(t, "public", get(tlsClient, baseURL(s, "https")))
+	assert.Equal(t, "admin", get(unixClient, "http://admin/"))
+	info, err := os.Stat(socket)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0660), info.Mode().Perm())
+	assert.Nil(t, s.Addr("missing"))
+
+	// Test case 2: a socket in use fails the start and closes the other listeners
+	second := newServer()
+	err = second.Start()
+	assert.ErrorContains(t, err, "admin listener")
+	assert.Nil(t, second.Addr("public"))
+
+	// Test case 3: shutdown removes the socket
+	require.NoError(t, s.Shutdown(context.Background()))
+	_, err = os.Stat(socket)
+	assert.True(t, os.IsNotExist(err))
+
+	// Test case 4: a stale socket left by a crashed process is replaced
+	ln, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	s = newServer()
+	require.NoError(t, s.Start())
+	defer s.Shutdown(context.Background())
+	assert.Equal(t, "admin", get(unixClient, "http://admin/"))
+}
+
+// TestActivation covers reading sockets passed by systemd
+func TestActivation(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	file, err := ln.(*net.TCPListener).File()
+	require.NoError(t, err)
+	defer file.Close()
+	fd := int(file.Fd())
+	pid := strconv.Itoa(os.Getpid())
+
+	// Test case 1: sockets meant for another process are ignored
+	files, _, err := parseActivation("1", "1", "", fd)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	// Test case 2: an invalid count is an error
+	_, _, err = parseActivation(pid, "x", "", fd)
+	assert.Error(t, err)
+
+	// Test case 3: sockets are found by name or index
+	files, names, err := parseActivation(pid, "1", "admin", fd)
+	require.NoError(t, err)
+	for _, name := range []string{"admin", "0"} {
+		found, err := findActivated(files, names, name)
+		require.NoError(t, err)
+		activated, err := net.FileListener(found)
+		require.NoError(t, err)
+		assert.Equal(t, ln.Addr().String(), activated.Addr().String())
+		activated.Close()
+	}
+
+	// Test case 4: unknown names are an error
+	_, err = findActivated(files, names, "public")
+	assert.ErrorContains(t, err, "no socket named public")
+	_, err = findActivated(nil, nil, "admin")
+	assert.Error(t, err)
+}
+
+// TestHandover passes the listeners to a new process
+func TestHandover(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "admin.sock")
+	t.Setenv("GOPARK_TEST_SOCKET", socket)
+	s := newHandoverServer(socket, "old")
+	require.NoError(t, s.Start())
+	defer s.Shutdown(context.Background())
+	get := func() string {
+		resp, err := http.Get(baseURL(s, "http"))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	// Test case 1: a new process that exits before it is ready is reported
+	// and this process keeps serving
+	t.Setenv(envHandoverTest, "fail")
+	_, err := s.Handover(context.Background())
+	assert.ErrorContains(t, err, "exited before it was ready")
+	assert.Equal(t, "old", get())
+
+	// Test case 2: the new process serves the same sockets once this one shuts down
+	t.Setenv(envHandoverTest, "serve")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	pid, err := s.Handover(ctx)
+	require.NoError(t, err)
+	defer func() {
+		if process, err := os.FindProcess(pid); err == nil {
+			process.Kill()
+		}
+	}()
+	require.NoError(t, s.Shutdown(ctx))
+	assert.Equal(t, "new", get())
+	conn, err := net.Dial("unix", socket)
+	require.NoError(t, err)
+	conn.Close()
+}
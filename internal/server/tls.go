@@ -0,0 +1,249 @@
+package server
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"gopark/config"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+// certReloadDebounce coalesces the several events of one certificate rotation
+const certReloadDebounce = 100 * time.Millisecond
+
+// selfSignedValidity is how long a generated development certificate is valid
+const selfSignedValidity = 7 * 24 * time.Hour
+
+// tlsConfig builds the TLS settings, loading the certificate and starting to
+// watch its files for changes
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	cfg := s.tls
+	minVersion, err := config.TLSVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	suites, err := config.CipherSuiteIDs(cfg.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:   minVersion,
+		CipherSuites: suites, // Ignored for TLS 1.3, whose suites are all secure
+	}
+
+	if cfg.SelfSigned {
+		cert, err := selfSignedCertificate()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate self-signed certificate: %w", err)
+		}
+		s.log.Warn("Serving a generated self-signed certificate; use only for development")
+		tlsConfig.Certificates = []tls.Certificate{*cert}
+	} else {
+		certs, err := newCertReloader(cfg.CertFile, cfg.KeyFile, s.log)
+		if err != nil {
+			return nil, err
+		}
+		if err := certs.watch(); err != nil {
+			return nil, err
+		}
+		s.certs = certs
+		tlsConfig.GetCertificate = certs.GetCertificate
+	}
+
+	switch cfg.ClientAuth {
+	case "", "none":
+		return tlsConfig, nil
+	case "request":
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		s.stopCertWatch()
+		return nil, fmt.Errorf("unknown client auth mode %q", cfg.ClientAuth)
+	}
+	pool, err := loadCertPool(cfg.ClientCAFile)
+	if err != nil {
+		s.stopCertWatch()
+		return nil, err
+	}
+	tlsConfig.ClientCAs = pool
+	return tlsConfig, nil
+}
+
+// loadCertPool reads a PEM bundle of CA certificates
+func loadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in client CA file %s", file)
+	}
+	return pool, nil
+}
+
+// certReloader serves a certificate from files and reloads it when they
+// change, so rotated certificates apply without a restart
+type certReloader struct {
+	certFile string
+	keyFile  string
+	log      *logrus.Logger
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	pemCert []byte // Contents last loaded, to skip reloads when nothing changed
+	pemKey  []byte
+
+	done chan struct{}
+	once sync.Once
+}
+
+// newCertReloader loads the certificate and key
+func newCertReloader(certFile, keyFile string, log *logrus.Logger) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, log: log, done: make(chan struct{})}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// reload loads the files again and reports whether the certificate changed;
+// on error the previous certificate stays in use
+func (r *certReloader) reload() (bool, error) {
+	certPEM, err := os.ReadFile(r.certFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to read TLS certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to read TLS key: %w", err)
+	}
+
+	r.mu.RLock()
+	unchanged := bytes.Equal(certPEM, r.pemCert) && bytes.Equal(keyPEM, r.pemKey)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, fmt.Errorf("invalid TLS certificate or key: %w", err)
+	}
+
+	r.mu.Lock()
+	r.cert, r.pemCert, r.pemKey = &cert, certPEM, keyPEM
+	r.mu.Unlock()
+	if leaf := cert.Leaf; leaf != nil {
+		r.log.Infof("Loaded TLS certificate for %s (expires %s)", leaf.Subject.CommonName, leaf.NotAfter.Format(time.RFC3339))
+	}
+	return true, nil
+}
+
+// watch reloads the certificate when its files change. Directories are
+// watched rather than files so atomic renames and Kubernetes secret updates,
+// which swap a symlink, are seen
+func (r *certReloader) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create certificate watcher: %w", err)
+	}
+	dirs := map[string]bool{filepath.Dir(r.certFile): true, filepath.Dir(r.keyFile): true}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+	go func() {
+		defer watcher.Close()
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-r.done:
+				return
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				debounce = time.After(certReloadDebounce)
+			case <-debounce:
+				debounce = nil
+				if _, err := r.reload(); err != nil {
+					r.log.Errorf("Keeping the current TLS certificate: %v", err)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				r.log.Errorf("Certificate watcher error: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Close stops watching the certificate files
+func (r *certReloader) Close() {
+	r.once.Do(func() { close(r.done) })
+}
+
+// selfSignedCertificate generates a certificate for this host and localhost
+func selfSignedCertificate() (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{"localhost"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "localhost" {
+		names = append(names, hostname)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "localhost", Organization: []string{"GoPark development"}},
+		NotBefore:             now.Add(-time.Hour), // Tolerate clock skew
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              names,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
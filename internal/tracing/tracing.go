@@ -0,0 +1,109 @@
+// Package tracing exports OpenTelemetry traces of HTTP requests and the
+// database queries they run
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"gopark/config"
+	"os"
+	"runtime/debug"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Provider creates the tracers of a service instance. Spans of a disabled
+// provider are discarded
+type Provider struct {
+	trace.TracerProvider
+	sdk *sdktrace.TracerProvider // nil when tracing is disabled
+}
+
+// New creates a provider that samples new traces at cfg.SampleRatio,
+// follows the sampling decision of an incoming parent, and exports spans in
+// batches to the configured exporter. service names the traces' resource
+func New(cfg config.TracingConfig, service string) (*Provider, error) {
+	if !cfg.Enabled {
+		return &Provider{TracerProvider: noop.NewTracerProvider()}, nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case "otlp":
+		// No connection is made until the first batch is exported
+		exporter, err = otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(cfg.Endpoint+"/v1/traces"))
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		err = fmt.Errorf("unsupported exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(service),
+		semconv.ServiceVersion(version()),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe trace resource: %w", err)
+	}
+
+	sdk := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	return &Provider{TracerProvider: sdk, sdk: sdk}, nil
+}
+
+// Enabled reports whether spans are recorded and exported
+func (p *Provider) Enabled() bool {
+	return p.sdk != nil
+}
+
+// Shutdown exports the spans still buffered and stops the exporter
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p.sdk == nil {
+		return nil
+	}
+	return p.sdk.Shutdown(ctx)
+}
+
+// version returns the module version of the binary
+func version() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Version
+	}
+	return "unknown"
+}
+
+// LogHook adds the trace_id and span_id fields to entries logged with the
+// context of a traced request, e.g. log.WithContext(c.Request.Context())
+type LogHook struct{}
+
+// Levels returns every level
+func (LogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire adds the span fields to entry
+func (LogHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	sc := trace.SpanContextFromContext(entry.Context)
+	if sc.IsValid() {
+		entry.Data["trace_id"] = sc.TraceID().String()
+		entry.Data["span_id"] = sc.SpanID().String()
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"gopark/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNew covers disabled tracing and export over OTLP/HTTP
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	// Test case 1: a disabled provider records nothing
+	provider, err := New(config.TracingConfig{}, "gopark")
+	require.NoError(t, err)
+	assert.False(t, provider.Enabled())
+	_, span := provider.Tracer("test").Start(ctx, "request")
+	assert.False(t, span.IsRecording())
+	assert.NoError(t, provider.Shutdown(ctx))
+
+	// Test case 2: spans are exported to the collector on shutdown
+	var mu sync.Mutex
+	var paths []string
+	var body []byte
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		paths, body = append(paths, r.URL.Path), append(body, data...)
+		mu.Unlock()
+	}))
+	defer collector.Close()
+	provider, err = New(config.TracingConfig{Enabled: true, Exporter: "otlp", Endpoint: collector.URL, SampleRatio: 1}, "gopark-test")
+	require.NoError(t, err)
+	assert.True(t, provider.Enabled())
+	_, span = provider.Tracer("test").Start(ctx, "GET /api/v1/users/list")
+	assert.True(t, span.IsRecording())
+	span.End()
+	require.NoError(t, provider.Shutdown(ctx))
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"/v1/traces"}, paths)
+	assert.Contains(t, string(body), "GET /api/v1/users/list")
+	assert.Contains(t, string(body), "gopark-test") // service.name
+
+	// Test case 3: a zero ratio drops new traces
+	provider, err = New(config.TracingConfig{Enabled: true, Exporter: "otlp", Endpoint: collector.URL}, "gopark")
+	require.NoError(t, err)
+	_, span = provider.Tracer("test").Start(ctx, "request")
+	assert.False(t, span.IsRecording())
+	assert.NoError(t, provider.Shutdown(ctx))
+}
+
+// TestLogHook verifies entries logged with a traced context carry its IDs
+func TestLogHook(t *testing.T) {
+	var out bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&out)
+	log.SetFormatter(&logrus.JSONFormatter{})
+	log.AddHook(LogHook{})
+
+	provider, err := New(config.TracingConfig{Enabled: true, Exporter: "stdout", SampleRatio: 1}, "gopark")
+	require.NoError(t, err)
+	ctx, span := provider.Tracer("test").Start(context.Background(), "request")
+
+	// Test case 1: the trace and span IDs are added
+	log.WithContext(ctx).Info("traced")
+	assert.Contains(t, out.String(), `"trace_id":"`+span.SpanContext().TraceID().String()+`"`)
+	assert.Contains(t, out.String(), `"span_id":"`+span.SpanContext().SpanID().String()+`"`)
+
+	// Test case 2: entries without a traced context are unchanged
+	out.Reset()
+	log.WithContext(context.Background()).Info("untraced")
+	log.Info("plain")
+	assert.NotContains(t, out.String(), "trace_id")
+}
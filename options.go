@@ -0,0 +1,82 @@
+package gopark
+
+import (
+	"crypto/x509"
+	"gopark/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Option configures an App
+type Option func(*App)
+
+// WithConfig replaces the built-in defaults (config.Default) with cfg, e.g.
+// as returned by config.NewLoader(...).Load()
+func WithConfig(cfg config.Config) Option {
+	return func(a *App) { a.cfg = cfg }
+}
+
+// WithLogger sets the logger; by default the App logs to a new logrus logger
+func WithLogger(log *logrus.Logger) Option {
+	return func(a *App) { a.log = log }
+}
+
+// WithStore serves the user API from store instead of the configured SQLite
+// database. No database is opened, so the job queue, scheduler, backups,
+// replication and their admin routes are disabled
+func WithStore(store UserStore) Option {
+	return func(a *App) { a.store = store }
+}
+
+// WithMigrations applies the pending SQL migrations in dir when New opens
+// the database
+func WithMigrations(dir string) Option {
+	return func(a *App) { a.migrations, a.migrate = dir, true }
+}
+
+// WithMigrationCheck only logs a warning for each migration in dir that has
+// not been applied, for deployments that migrate separately
+func WithMigrationCheck(dir string) Option {
+	return func(a *App) { a.migrations, a.migrate = dir, false }
+}
+
+// WithMiddleware adds middleware to every public route. It runs after the built-in
+// request ID, logging and CORS middleware
+func WithMiddleware(middleware ...gin.HandlerFunc) Option {
+	return func(a *App) { a.middleware = append(a.middleware, middleware...) }
+}
+
+// WithAdminMiddleware adds middleware to the admin listener, e.g. to
+// authenticate operators. It runs after the built-in request ID and
+// logging middleware
+func WithAdminMiddleware(middleware ...gin.HandlerFunc) Option {
+	return func(a *App) { a.adminMiddleware = append(a.adminMiddleware, middleware...) }
+}
+
+// WithRouteGroup mounts extra routes under prefix, next to the built-in API.
+// The group's middleware runs after the App-wide middleware
+func WithRouteGroup(prefix string, register func(g *gin.RouterGroup), middleware ...gin.HandlerFunc) Option {
+	return func(a *App) {
+		a.groups = append(a.groups, routeGroup{prefix: prefix, register: register, middleware: middleware})
+	}
+}
+
+// WithComponent adds a component, such as an event relay, to the App's
+// lifecycle. It starts after its dependencies and stops before them
+func WithComponent(c Component) Option {
+	return func(a *App) { a.components = append(a.components, c) }
+}
+
+// WithIdentityMapper replaces the tls.identity_from mapping from verified
+// client certificates to identity names; returning false leaves the request
+// without an identity
+func WithIdentityMapper(mapper func(cert *x509.Certificate) (string, bool)) Option {
+	return func(a *App) { a.identity = mapper }
+}
+
+// WithHealthCheck adds a check to the readiness probe, and to the liveness
+// probe when check.Liveness is set
+func WithHealthCheck(check HealthCheck) Option {
+	return func(a *App) { a.checks = append(a.checks, check) }
+}
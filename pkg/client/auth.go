@@ -0,0 +1,36 @@
+package client
+
+import "net/http"
+
+// Authenticator adds credentials to each outgoing request. It is called once
+// per attempt, so implementations may refresh short-lived tokens
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthenticatorFunc adapts a function to the Authenticator interface
+type AuthenticatorFunc func(req *http.Request) error
+
+// Authenticate implements Authenticator
+func (f AuthenticatorFunc) Authenticate(req *http.Request) error { return f(req) }
+
+// BearerToken authenticates requests with a static bearer token
+type BearerToken string
+
+// Authenticate implements Authenticator
+func (t BearerToken) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+string(t))
+	return nil
+}
+
+// BasicAuth authenticates requests with HTTP basic authentication
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Authenticate implements Authenticator
+func (b BasicAuth) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(b.Username, b.Password)
+	return nil
+}
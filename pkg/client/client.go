@@ -0,0 +1,157 @@
+// Package client is a typed Go client for the GoPark /api/v1 HTTP API
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultTimeout bounds each request when no HTTP client is supplied
+const defaultTimeout = 30 * time.Second
+
+// Client calls the GoPark API. It is safe for concurrent use
+type Client struct {
+	baseURL   *url.URL
+	http      *http.Client
+	userAgent string
+	auth      Authenticator
+	retry     RetryPolicy
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient replaces the default HTTP client, e.g. to set transports or timeouts
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithUserAgent sets the User-Agent header sent with every request
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// WithAuth authenticates every request with auth
+func WithAuth(auth Authenticator) Option {
+	return func(c *Client) { c.auth = auth }
+}
+
+// WithToken authenticates requests with a bearer token
+func WithToken(token string) Option {
+	return WithAuth(BearerToken(token))
+}
+
+// WithBasicAuth authenticates requests with HTTP basic authentication
+func WithBasicAuth(username, password string) Option {
+	return WithAuth(BasicAuth{Username: username, Password: password})
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) { c.retry = policy }
+}
+
+// New creates a client for the server at baseURL, e.g. https://gopark.example.com
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: scheme must be http or https", baseURL)
+	}
+
+	c := &Client{
+		baseURL:   u,
+		http:      &http.Client{Timeout: defaultTimeout},
+		userAgent: "gopark-client",
+		retry:     DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Users returns the user endpoints
+func (c *Client) Users() *UsersService {
+	return &UsersService{client: c}
+}
+
+// do sends a request to path under /api/v1 and decodes a JSON response into
+// out, retrying idempotent requests according to the retry policy
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	u := *c.baseURL
+	u.Path += "/api/v1" + path
+	u.RawQuery = query.Encode()
+
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	attempts := 1
+	if idempotent(method) && c.retry.MaxAttempts > 1 {
+		attempts = c.retry.MaxAttempts
+	}
+	for attempt := 1; ; attempt++ {
+		err := c.send(ctx, method, u.String(), data, out)
+		if err == nil || attempt >= attempts || !retryable(ctx, err) {
+			return err
+		}
+		if err := sleep(ctx, c.retry.backoff(attempt, err)); err != nil {
+			return err
+		}
+	}
+}
+
+// send performs a single attempt of a request
+func (c *Client) send(ctx context.Context, method, target string, data []byte, out any) error {
+	var reader io.Reader
+	if data != nil {
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.auth != nil {
+		if err := c.auth.Authenticate(req); err != nil {
+			return fmt.Errorf("failed to authenticate request: %w", err)
+		}
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(resp)
+	}
+
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
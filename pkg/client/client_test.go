@@ -0,0 +1,211 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"gopark/config"
+	"gopark/internal/db"
+	"gopark/internal/events"
+	"gopark/internal/routes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fastRetry keeps retry tests quick
+var fastRetry = RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+// newTestServer serves the real router backed by a migrated SQLite database
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(bytes.NewBuffer(nil)) // Disable logging output
+
+	var cfg config.Config
+	cfg.Database.Path = filepath.Join(t.TempDir(), "gopark.db")
+	dbConn, err := db.NewDB(cfg, log)
+	require.NoError(t, err)
+	t.Cleanup(dbConn.Close)
+	require.NoError(t, db.NewMigrationManager(dbConn, log).RunMigrations(context.Background(), "../../internal/migrations"))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	routes.SetupRoutes(r, routes.Dependencies{Log: log, DB: dbConn, Events: events.NopPublisher{}})
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestUsers exercises every user endpoint against the real router
+func TestUsers(t *testing.T) {
+	srv := newTestServer(t)
+	c, err := New(srv.URL)
+	require.NoError(t, err)
+	users := c.Users()
+	ctx := context.Background()
+
+	// Test case 1: Create and fetch a user
+	created, err := users.Create(ctx, UserInput{Name: "Ada Lovelace", Mail: "ada@example.com"})
+	require.NoError(t, err)
+	assert.NotZero(t, created.ID)
+	got, err := users.Get(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, created, got)
+
+	// Test case 2: Invalid input decodes the error payload
+	_, err = users.Create(ctx, UserInput{Name: "No Mail"})
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.True(t, IsBadRequest(err))
+	assert.Equal(t, http.StatusBadRequest, apiErr.Code)
+	assert.NotEmpty(t, apiErr.Message)
+	assert.NotEmpty(t, apiErr.RequestID)
+	assert.Contains(t, err.Error(), apiErr.RequestID)
+
+	// Test case 3: Update and search
+	updated, err := users.Update(ctx, created.ID, UserInput{Name: "Ada King", Mail: "ada@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "Ada King", updated.Name)
+	found, err := users.Search(ctx, "king")
+	require.NoError(t, err)
+	assert.Equal(t, []User{*updated}, found)
+
+	// Test case 4: Empty results are empty slices rather than nil
+	found, err = users.Search(ctx, "nobody")
+	require.NoError(t, err)
+	assert.NotNil(t, found)
+	assert.Empty(t, found)
+
+	// Test case 5: Delete, after which the user is not found
+	require.NoError(t, users.Delete(ctx, created.ID))
+	_, err = users.Get(ctx, created.ID)
+	assert.True(t, IsNotFound(err))
+}
+
+// TestAll pages through users with the iterator
+func TestAll(t *testing.T) {
+	srv := newTestServer(t)
+	c, err := New(srv.URL)
+	require.NoError(t, err)
+	ctx := context.Background()
+	seeded, err := c.Users().List(ctx, ListOptions{Limit: 100}) // Users inserted by the migrations
+	require.NoError(t, err)
+	for i := range 7 {
+		_, err := c.Users().Create(ctx, UserInput{Name: fmt.Sprintf("User %d", i), Mail: fmt.Sprintf("user%d@example.com", i)})
+		require.NoError(t, err)
+	}
+
+	// Test case 1: Every user is visited once across pages
+	var names []string
+	for user, err := range c.Users().All(ctx, ListOptions{Limit: 3}) {
+		require.NoError(t, err)
+		names = append(names, user.Name)
+	}
+	require.Len(t, names, len(seeded)+7)
+	assert.Equal(t, "User 0", names[len(seeded)])
+	assert.Equal(t, "User 6", names[len(names)-1])
+
+	// Test case 2: Breaking out stops fetching
+	count := 0
+	for range c.Users().All(ctx, ListOptions{Limit: 3, Offset: 5}) {
+		count++
+		break
+	}
+	assert.Equal(t, 1, count)
+}
+
+// TestRetry covers retries of idempotent requests
+func TestRetry(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"id":1,"name":"Ada","mail":"ada@example.com"}`))
+	}))
+	defer srv.Close()
+	c, err := New(srv.URL, WithRetryPolicy(fastRetry))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// Test case 1: GET succeeds on the third attempt
+	user, err := c.Users().Get(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Ada", user.Name)
+	assert.Equal(t, int32(3), calls.Load())
+
+	// Test case 2: POST is never retried
+	calls.Store(0)
+	_, err = c.Users().Create(ctx, UserInput{Name: "Ada", Mail: "ada@example.com"})
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	assert.Equal(t, "Service Unavailable", apiErr.Message)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Test case 3: Attempts are bounded by the policy
+	calls.Store(-10)
+	_, err = c.Users().Get(ctx, 1)
+	assert.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, int32(-7), calls.Load())
+
+	// Test case 4: Client errors are not retried
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, `{"code":404,"message":"User not found"}`, http.StatusNotFound)
+	})
+	calls.Store(0)
+	_, err = c.Users().Get(ctx, 1)
+	assert.True(t, IsNotFound(err))
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+// TestAuth covers the built-in and custom authenticators
+func TestAuth(t *testing.T) {
+	var header string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("Authorization")
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		opt  Option
+		want string
+	}{
+		{"Token", WithToken("s3cret"), "Bearer s3cret"},
+		{"Basic", WithBasicAuth("admin", "pw"), "Basic YWRtaW46cHc="},
+		{"Custom", WithAuth(AuthenticatorFunc(func(req *http.Request) error {
+			req.Header.Set("Authorization", "Custom abc")
+			return nil
+		})), "Custom abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(srv.URL, tt.opt)
+			require.NoError(t, err)
+			_, err = c.Users().List(ctx, ListOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, header)
+		})
+	}
+
+	// An authenticator error aborts the request
+	c, err := New(srv.URL, WithAuth(AuthenticatorFunc(func(*http.Request) error { return errors.New("token expired") })))
+	require.NoError(t, err)
+	_, err = c.Users().List(ctx, ListOptions{})
+	assert.ErrorContains(t, err, "token expired")
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxErrorBody limits how much of an error response is read
+const maxErrorBody = 64 << 10
+
+// APIError is returned for responses with a non-2xx status. Code, Message
+// and RequestID are decoded from the server's error payload
+// ({"code": ..., "message": ..., "request_id": ...})
+type APIError struct {
+	StatusCode int           `json:"-"`          // HTTP status code
+	Code       int           `json:"code"`       // Code from the error payload; usually equal to StatusCode
+	Message    string        `json:"message"`    // Message from the error payload, or the status text
+	RequestID  string        `json:"request_id"` // ID the server logged the request with, for support requests
+	RetryAfter time.Duration `json:"-"`          // Delay requested by a Retry-After header, if any
+}
+
+func (e *APIError) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("gopark: %d %s (request %s)", e.StatusCode, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("gopark: %d %s", e.StatusCode, e.Message)
+}
+
+// Temporary reports whether the request may succeed if retried later
+func (e *APIError) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// IsNotFound reports whether err is an APIError with status 404
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsBadRequest reports whether err is an APIError with status 400, which the
+// server returns for invalid input
+func IsBadRequest(err error) bool {
+	return hasStatus(err, http.StatusBadRequest)
+}
+
+// hasStatus reports whether err is an APIError with the given status
+func hasStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// newAPIError builds an APIError from a non-2xx response
+func newAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if json.Unmarshal(data, apiErr) != nil || apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	if apiErr.Code == 0 {
+		apiErr.Code = resp.StatusCode
+	}
+	if apiErr.RequestID == "" {
+		apiErr.RequestID = resp.Header.Get("X-Request-ID") // E.g. errors without a payload
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
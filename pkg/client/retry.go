@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy controls how idempotent requests (GET, PUT and DELETE) are
+// retried after network errors and 429, 502, 503 or 504 responses. POST is
+// never retried, since the server may have created the user before failing
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts including the first; 1 disables retries
+	MinBackoff  time.Duration // Delay before the first retry; doubled for each further retry
+	MaxBackoff  time.Duration // Upper bound for the delay and for Retry-After
+}
+
+// DefaultRetryPolicy is used unless WithRetryPolicy is given
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  100 * time.Millisecond,
+	MaxBackoff:  2 * time.Second,
+}
+
+// idempotent reports whether a request with method may be sent more than once
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryable reports whether err from an attempt is worth retrying
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+	return true // Transport errors such as connection resets
+}
+
+// backoff returns the delay before retry number attempt (starting at 1), with
+// up to half of it randomized so clients do not retry in lockstep
+func (p RetryPolicy) backoff(attempt int, err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return min(apiErr.RetryAfter, p.MaxBackoff)
+	}
+
+	delay := p.MinBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= p.MaxBackoff {
+			delay = p.MaxBackoff
+			break
+		}
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// User is a GoPark user
+type User struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Mail string `json:"mail"`
+}
+
+// UserInput holds the writable fields of a user
+type UserInput struct {
+	Name string `json:"name"`
+	Mail string `json:"mail"`
+}
+
+// maxPageSize is the largest page the server returns
+const maxPageSize = 100
+
+// ListOptions pages through list results
+type ListOptions struct {
+	Limit  int // Page size; the server defaults to 10 and caps it at 100 (All defaults to 100)
+	Offset int
+}
+
+// UsersService calls the /api/v1/users endpoints
+type UsersService struct {
+	client *Client
+}
+
+// Get returns the user with the given ID
+func (s *UsersService) Get(ctx context.Context, id uint) (*User, error) {
+	var user User
+	query := url.Values{"id": {strconv.FormatUint(uint64(id), 10)}}
+	if err := s.client.do(ctx, http.MethodGet, "/users", query, nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Create creates a user
+func (s *UsersService) Create(ctx context.Context, input UserInput) (*User, error) {
+	var user User
+	if err := s.client.do(ctx, http.MethodPost, "/users", nil, input, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Update replaces the name and mail of the user with the given ID
+func (s *UsersService) Update(ctx context.Context, id uint, input UserInput) (*User, error) {
+	var user User
+	if err := s.client.do(ctx, http.MethodPut, "/users/"+strconv.FormatUint(uint64(id), 10), nil, input, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Delete deletes the user with the given ID
+func (s *UsersService) Delete(ctx context.Context, id uint) error {
+	return s.client.do(ctx, http.MethodDelete, "/users/"+strconv.FormatUint(uint64(id), 10), nil, nil, nil)
+}
+
+// Search returns up to 100 users whose name contains name, case-insensitively
+func (s *UsersService) Search(ctx context.Context, name string) ([]User, error) {
+	var users []User
+	if err := s.client.do(ctx, http.MethodGet, "/users/search", url.Values{"name": {name}}, nil, &users); err != nil {
+		return nil, err
+	}
+	return nonNil(users), nil
+}
+
+// List returns one page of users ordered by ID
+func (s *UsersService) List(ctx context.Context, opts ListOptions) ([]User, error) {
+	query := url.Values{}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Offset > 0 {
+		query.Set("offset", strconv.Itoa(opts.Offset))
+	}
+	var users []User
+	if err := s.client.do(ctx, http.MethodGet, "/users/list", query, nil, &users); err != nil {
+		return nil, err
+	}
+	return nonNil(users), nil
+}
+
+// nonNil turns the null the server sends for empty results into an empty slice
+func nonNil(users []User) []User {
+	if users == nil {
+		return []User{}
+	}
+	return users
+}
+
+// All iterates over every user ordered by ID, requesting pages of
+// opts.Limit users starting at opts.Offset. Iteration stops after yielding
+// the first error
+func (s *UsersService) All(ctx context.Context, opts ListOptions) iter.Seq2[User, error] {
+	if opts.Limit <= 0 || opts.Limit > maxPageSize {
+		opts.Limit = maxPageSize
+	}
+	return func(yield func(User, error) bool) {
+		for {
+			users, err := s.List(ctx, opts)
+			if err != nil {
+				yield(User{}, err)
+				return
+			}
+			for _, user := range users {
+				if !yield(user, nil) {
+					return
+				}
+			}
+			if len(users) < opts.Limit {
+				return
+			}
+			opts.Offset += len(users)
+		}
+	}
+}